@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleAdminHashAction downloads the installers of a package for all architectures and prints
+// their SHA-256 hashes, for registry maintainers. With --write, the hashes are filled in the given
+// registry file instead.
+func handleAdminHashAction(c *cli.Context) {
+	if c.NArg() != 1 {
+		log.Fatalln("Usage: just-install admin hash [--write <registry.json>] <package>")
+	}
+
+	name := c.Args().First()
+	registry := loadRegistry(c)
+
+	entry, ok := registry.Packages[name]
+	if !ok {
+		log.Fatalf("%v is not in the registry", name)
+	} else if entry.IsGroup() {
+		log.Fatalf("%v is a group, it has no installers", name)
+	}
+
+	if entry.Version == "latest" || entry.Scrape != nil || entry.VersionSource != nil {
+		log.Printf("WARNING: the version of %v is not fixed, its hashes will change with the next release", name)
+	}
+
+	entry, err := entry.WithLatestVersion()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := requireVariables(name, entry); err != nil {
+		log.Fatalln(err)
+	}
+
+	hashes, err := entry.InstallerHashes()
+	if err != nil {
+		log.Fatalf("Cannot hash the installers of %v: %v", name, err)
+	}
+
+	if path := c.String("write"); path != "" {
+		if err := justinstall.WriteInstallerHashes(path, name, hashes); err != nil {
+			log.Fatalf("Cannot update %v: %v", path, err)
+		}
+
+		log.Printf("Updated %d hashes of %v in %v", len(hashes), name, path)
+		return
+	}
+
+	for _, arch := range []string{"x86", "x86_64", "arm64"} {
+		if hash, ok := hashes[arch]; ok {
+			fmt.Printf("%v\t%v\n", arch, hash)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
+	versions "github.com/just-install/just-install/pkg/version"
+)
+
+// manifest is a list of packages that should be installed on a machine, each with an optional
+// version constraint (e.g. ">=20 <21").
+type manifest struct {
+	Packages map[string]string
+}
+
+// handleApplyAction brings the machine in line with a manifest file. Like "ensure", it prints one
+// "changed" or "unchanged" line per package.
+func handleApplyAction(c *cli.Context) {
+	if c.NArg() != 1 {
+		log.Fatalln("Usage: just-install apply <manifest.json>")
+	}
+
+	data, err := ioutil.ReadFile(c.Args().First())
+	if err != nil {
+		log.Fatalln("Unable to read the manifest:", err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		log.Fatalln("Unable to parse the manifest:", err)
+	}
+
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	setInstallOptions(c)
+
+	var names []string
+	for name := range m.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hasErrors := false
+
+	for _, name := range names {
+		if err := applyPackage(registry, installState, name, m.Packages[name]); err != nil {
+			log.Printf("Cannot apply %v: %v", name, err)
+			hasErrors = true
+		}
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
+
+// applyPackage makes sure that a version of the given package satisfying the given constraint is
+// installed, picking the newest satisfying version among all release channels and the versions
+// listed in "versions" otherwise.
+func applyPackage(registry justinstall.Registry, installState *state.State, requested string, rawConstraint string) error {
+	constraint, err := versions.ParseConstraint(rawConstraint)
+	if err != nil {
+		return err
+	}
+
+	name, err := registry.Resolve(requested, installState)
+	if err != nil {
+		return err
+	}
+
+	if installed, ok := registry.InstalledVersion(name, installState); ok && constraint.Check(installed) {
+		fmt.Printf("%v: unchanged\n", requested)
+		return nil
+	}
+
+	entry := registry.Packages[name]
+	replaceDeprecated(registry, name, false)
+
+	// Without a constraint, install from the channel the package was installed from (if any).
+	// Otherwise pick the newest satisfying version offered by any channel or listed in "versions".
+	var available []string
+	bestChannel := ""
+	bestVersion := ""
+	listed := false // Whether bestVersion is one of those listed in "versions"
+	found := false
+
+	if constraint.IsAny() {
+		if recorded, ok := installState.Installed(name); ok {
+			bestChannel = recorded.Channel
+		}
+		found = true
+	} else {
+		for _, channel := range entry.ChannelNames() {
+			channelEntry, _ := entry.WithChannel(channel)
+
+			channelEntry, err := channelEntry.WithLatestVersion()
+			if err != nil {
+				log.Printf("WARNING: %v (%v): %v", name, channel, err)
+				continue
+			}
+
+			available = append(available, fmt.Sprintf("%v (%v)", channelEntry.Version, channel))
+
+			if !constraint.Check(channelEntry.Version) {
+				continue
+			}
+
+			if !found || versions.Compare(channelEntry.Version, bestVersion) > 0 {
+				bestChannel, bestVersion, listed, found = channel, channelEntry.Version, false, true
+			}
+		}
+
+		for _, version := range entry.VersionNames() {
+			// The current version is the head of the default channel, handled above
+			if versions.Equal(version, entry.Version) {
+				continue
+			}
+
+			available = append(available, version)
+
+			if !constraint.Check(version) {
+				continue
+			}
+
+			if !found || versions.Compare(version, bestVersion) > 0 {
+				bestChannel, bestVersion, listed, found = "", version, true, true
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no version satisfies %v, the registry offers: %v", constraint, strings.Join(available, ", "))
+	}
+
+	if bestChannel == justinstall.DefaultChannel {
+		bestChannel = ""
+	}
+
+	var channelEntry justinstall.RegistryEntry
+	if listed {
+		channelEntry, err = entry.WithVersion(bestVersion)
+	} else if channelEntry, err = entry.WithChannel(bestChannel); err == nil {
+		channelEntry, err = channelEntry.WithLatestVersion()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := requireVariables(name, channelEntry); err != nil {
+		return err
+	}
+
+	if err := requireLicense(installState, name, channelEntry); err != nil {
+		return err
+	}
+
+	if err := installEntry(registry, installState, name, channelEntry, false); err != nil {
+		return err
+	}
+
+	recordInstall(installState, name, channelEntry, bestChannel)
+	fmt.Printf("%v: changed\n", requested)
+
+	return nil
+}
@@ -68,7 +68,12 @@ func handleAuditAction(c *cli.Context) {
 
 	registry := loadRegistry(c)
 
-	checkLink := func(rawurl string) error {
+	checkLink := func(source string) error {
+		rawurl, err := justinstall.ResolveURL(source)
+		if err != nil {
+			return fmt.Errorf("%s: %v", source, err)
+		}
+
 		return retry(func() (bool, error) {
 			// Policy: retry on server or transport error, fail immediately otherwise.
 			response, err := justinstall.CustomGet(rawurl, fetch.ConnectionPhaseTimeout)
@@ -143,20 +148,49 @@ func handleAuditAction(c *cli.Context) {
 
 	// Push jobs to workers
 	for _, name := range registry.SortedPackageNames() {
-		entry := registry.Packages[name]
+		entry, err := registry.Packages[name].WithLatestVersion()
+		if err != nil {
+			collectedErrors = append(collectedErrors, fmt.Errorf("%s: %v", name, err))
+			continue
+		}
 
-		if entry.Installer.X86 != "" {
-			workerQueue <- workItem{name + " (x86)", entry.ExpandString(entry.Installer.X86)}
+		if entry.Installer.Arm64.URL != "" {
+			workerQueue <- workItem{name + " (arm64)", entry.ExpandString(entry.Installer.Arm64.URL)}
 		}
 
-		if entry.Installer.X86_64 != "" {
-			workerQueue <- workItem{name + " (x86_64)", entry.ExpandString(entry.Installer.X86_64)}
+		if entry.Installer.X86.URL != "" {
+			workerQueue <- workItem{name + " (x86)", entry.ExpandString(entry.Installer.X86.URL)}
+		}
+
+		if entry.Installer.X86_64.URL != "" {
+			workerQueue <- workItem{name + " (x86_64)", entry.ExpandString(entry.Installer.X86_64.URL)}
 		}
 	}
 
 	close(workerQueue)
 	workerWg.Wait()
 
+	// Deprecated packages should point to a replacement that exists and should not stay installed
+	for _, name := range registry.SortedPackageNames() {
+		entry := registry.Packages[name]
+
+		if entry.SupersededBy == "" {
+			continue
+		} else if !entry.Deprecated {
+			collectedErrors = append(collectedErrors, fmt.Errorf("%s: superseded by %s but not deprecated", name, entry.SupersededBy))
+		} else if _, ok := registry.Replacement(name); !ok {
+			collectedErrors = append(collectedErrors, fmt.Errorf("%s: superseded by unknown package %s", name, entry.SupersededBy))
+		}
+	}
+
+	for _, name := range registry.InstalledDeprecated(justinstall.LoadState()) {
+		if replacement, ok := registry.Replacement(name); ok {
+			collectedErrors = append(collectedErrors, fmt.Errorf("%s: deprecated but still installed, replace it with %s", name, replacement))
+		} else {
+			collectedErrors = append(collectedErrors, fmt.Errorf("%s: deprecated but still installed", name))
+		}
+	}
+
 	if collectedErrors != nil {
 		log.Println("Found errors:")
 
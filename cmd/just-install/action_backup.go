@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleBackupAction archives the configuration declared by the given packages.
+func handleBackupAction(c *cli.Context) {
+	if c.NArg() == 0 || c.String("to") == "" {
+		log.Fatalln("Usage: just-install backup <package>... --to <directory>")
+	}
+
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	hasErrors := false
+
+	for _, arg := range c.Args() {
+		name, err := registry.Resolve(arg, installState)
+		if err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		entry := registry.Packages[name]
+
+		archive, err := entry.BackupConfig(name, c.String("to"))
+		if err != nil {
+			log.Printf("Cannot back up %v: %v", name, err)
+			hasErrors = true
+			continue
+		}
+
+		log.Printf("Configuration of %v backed up to %v", name, archive)
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
+
+// handleRestoreAction puts back the configuration archived by the backup command.
+func handleRestoreAction(c *cli.Context) {
+	if c.NArg() == 0 {
+		log.Fatalln("Usage: just-install restore <archive>...")
+	}
+
+	hasErrors := false
+
+	for _, archive := range c.Args() {
+		if err := justinstall.RestoreConfig(archive); err != nil {
+			log.Printf("Cannot restore %v: %v", archive, err)
+			hasErrors = true
+		}
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+func handleCacheGCAction(c *cli.Context) {
+	removed, err := justinstall.CollectCacheGarbage(c.Duration("max-age"))
+	for _, path := range removed {
+		log.Println("Removed", filepath.Base(path))
+	}
+
+	if err != nil {
+		log.Fatalln("Cannot clean up the download cache:", err)
+	}
+
+	log.Printf("%d stale files removed", len(removed))
+}
+
+func handleCacheMoveAction(c *cli.Context) {
+	if c.NArg() != 1 {
+		log.Fatalln("Usage: just-install cache move <newpath>")
+	}
+
+	if err := justinstall.MoveCache(c.Args().First()); err != nil {
+		log.Fatalln("Cannot move the download cache:", err)
+	}
+
+	log.Println("Download cache moved to", justinstall.CacheDir())
+}
+
+func handleCachePathAction(c *cli.Context) {
+	fmt.Println(justinstall.CacheDir())
+}
+
+func handleCacheListAction(c *cli.Context) {
+	entries, err := justinstall.CacheEntries()
+	if err != nil {
+		log.Fatalln("Cannot read the download cache:", err)
+	}
+
+	var total int64
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%v\t%.1f MB\t%v\t%v\n", entry.Name, float64(entry.Size)/1024/1024, entry.LastUsed.Format("2006-01-02 15:04"), entry.URL)
+		total += entry.Size
+	}
+	w.Flush()
+
+	fmt.Printf("%d files, %.1f MB\n", len(entries), float64(total)/1024/1024)
+}
+
+func handleCachePruneAction(c *cli.Context) {
+	if c.Int("max-size") < 0 {
+		log.Fatalln("The maximum size must not be negative")
+	}
+
+	evicted, err := justinstall.PruneCache(int64(c.Int("max-size")) * 1024 * 1024)
+	for _, entry := range evicted {
+		log.Println("Removed", entry.Name)
+	}
+
+	if err != nil {
+		log.Fatalln("Cannot prune the download cache:", err)
+	}
+}
+
+func handleCacheVerifyAction(c *cli.Context) {
+	verified, corrupted, err := justinstall.CheckCache()
+	if err != nil {
+		log.Fatalln("Cannot verify the download cache:", err)
+	}
+
+	log.Printf("%d files verified, %d corrupted", len(verified)+len(corrupted), len(corrupted))
+
+	hasErrors := false
+
+	for _, entry := range corrupted {
+		if !c.Bool("redownload") {
+			log.Printf("Corrupted: %v (%v)", entry.Name, entry.URL)
+			hasErrors = true
+			continue
+		}
+
+		log.Printf("Downloading %v again from %v", entry.Name, entry.URL)
+		if err := justinstall.RedownloadCached(entry); err != nil {
+			log.Printf("Cannot download %v again: %v", entry.Name, err)
+			hasErrors = true
+		}
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
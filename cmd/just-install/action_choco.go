@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleChocoExportAction writes a skeleton Chocolatey package for each of the given packages.
+func handleChocoExportAction(c *cli.Context) {
+	if c.NArg() == 0 {
+		log.Fatalln("Usage: just-install choco-export <package>... [--output <directory>]")
+	}
+
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	hasErrors := false
+
+	for _, arg := range c.Args() {
+		name, err := registry.Resolve(arg, installState)
+		if err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		entry, err := registry.Packages[name].WithLatestVersion()
+		if err != nil {
+			log.Printf("Cannot export %v: %v", name, err)
+			hasErrors = true
+			continue
+		}
+
+		dir, err := entry.ExportChocolatey(name, c.String("output"))
+		if err != nil {
+			log.Printf("Cannot export %v: %v", name, err)
+			hasErrors = true
+			continue
+		}
+
+		log.Printf("Chocolatey package for %v written to %v", name, dir)
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
@@ -9,7 +9,26 @@ import (
 )
 
 func handleCleanAction(c *cli.Context) {
+	if c.Bool("verify") {
+		verifyCache()
+		return
+	}
+
 	if err := justinstall.CleanTempDir(); err != nil {
 		log.Fatalln(err)
 	}
 }
+
+// verifyCache removes the corrupted files from the download cache, keeping the intact ones.
+func verifyCache() {
+	verified, corrupted, err := justinstall.VerifyCache()
+	for _, entry := range corrupted {
+		log.Println("Removed corrupted", entry.Name)
+	}
+
+	if err != nil {
+		log.Fatalln("Cannot verify the download cache:", err)
+	}
+
+	log.Printf("%d files verified, %d corrupted", len(verified)+len(corrupted), len(corrupted))
+}
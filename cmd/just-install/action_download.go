@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	dry "github.com/ungerik/go-dry"
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleDownloadAction fills the download cache with the installers of the given packages for
+// one or more architectures, without installing anything, so that the cache can be copied to
+// offline media or baked into lab images.
+func handleDownloadAction(c *cli.Context) {
+	if c.NArg() == 0 {
+		log.Fatalln("Usage: just-install download [--arch <arch>]... <package>...")
+	}
+
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	setInstallOptions(c)
+
+	archs := c.StringSlice("arch")
+	if len(archs) == 0 {
+		archs = []string{justinstall.Arch()}
+	}
+
+	var packages []string
+	var entries []justinstall.RegistryEntry
+	hasErrors := false
+
+	for _, arg := range c.Args() {
+		expanded, err := registry.Expand(arg, installState)
+		if err != nil {
+			log.Println("WARNING:", err)
+			hasErrors = true
+			continue
+		}
+
+		for _, pkg := range expanded {
+			if dry.StringInSlice(pkg, packages) {
+				continue
+			}
+
+			entry, _, err := channelEntry(c, registry, installState, pkg)
+			if err != nil {
+				log.Println("WARNING:", err)
+				hasErrors = true
+				continue
+			}
+
+			if err := requireVariables(pkg, entry); err != nil {
+				log.Fatalln(err)
+			}
+
+			packages = append(packages, pkg)
+			entries = append(entries, entry)
+		}
+	}
+
+	for _, arch := range archs {
+		paths, errs := justinstall.PrefetchArch(entries, arch)
+		for _, err := range errs {
+			log.Printf("WARNING: %v: %v", arch, err)
+			hasErrors = true
+		}
+
+		log.Printf("%v: %d installers in %v", arch, len(paths), justinstall.CacheDir())
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
+	versions "github.com/just-install/just-install/pkg/version"
+)
+
+// handleEnsureAction installs the given packages only when they are not already installed at the
+// wanted version. It prints one "<package>: unchanged" or "<package>: changed" line per package on
+// standard output so that configuration management tools can tell whether anything happened.
+func handleEnsureAction(c *cli.Context) {
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	setInstallOptions(c)
+
+	hasErrors := false
+
+	for _, arg := range c.Args() {
+		requested, wantedVersion := parsePackageVersion(arg)
+
+		// Groups and tags are ensured member by member
+		entry, ok := registry.Packages[requested]
+		if (ok && entry.IsGroup()) || strings.HasPrefix(requested, "tag:") {
+			if wantedVersion != "" {
+				log.Printf("Cannot ensure %v: groups have no version", arg)
+				hasErrors = true
+				continue
+			}
+
+			members, err := registry.Expand(requested, installState)
+			if err != nil {
+				log.Println(err)
+				hasErrors = true
+				continue
+			}
+
+			for _, member := range members {
+				if !ensurePackage(c, registry, installState, member, "") {
+					hasErrors = true
+				}
+			}
+
+			continue
+		}
+
+		if !ensurePackage(c, registry, installState, requested, wantedVersion) {
+			hasErrors = true
+		}
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
+
+// ensurePackage ensures that a single package, or a capability, is installed at the wanted version
+// and reports whether it succeeded. An empty version means the one offered by the registry.
+func ensurePackage(c *cli.Context, registry justinstall.Registry, installState *state.State, requested string, wantedVersion string) bool {
+	name, err := registry.Resolve(requested, installState)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	entry, channel, err := channelEntry(c, registry, installState, name)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	// A capability without an explicit version is satisfied by any installed provider
+	anyVersion := name != requested && wantedVersion == ""
+
+	if wantedVersion == "" {
+		wantedVersion = entry.Version
+	} else if entry, err = entry.WithVersion(wantedVersion); err != nil {
+		log.Printf("Cannot ensure %v@%v: %v", name, wantedVersion, err)
+		return false
+	}
+
+	// Switching channel is a change even if the version happens to be the same
+	installed, ok := registry.InstalledVersion(name, installState)
+	recorded, _ := installState.Installed(name)
+	if ok && recorded.Channel == channel && (anyVersion || versions.Equal(installed, wantedVersion)) {
+		fmt.Printf("%v: unchanged\n", requested)
+		return true
+	}
+
+	replaceDeprecated(registry, name, false)
+
+	if err := requireVariables(name, entry); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	if err := requireLicense(installState, name, entry); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	if err := installEntry(registry, installState, name, entry, false); err != nil {
+		log.Printf("Error installing %v: %v", name, err)
+		return false
+	}
+
+	recordInstall(installState, name, entry, channel)
+	fmt.Printf("%v: changed\n", requested)
+
+	return true
+}
+
+// parsePackageVersion splits a "<package>[@version]" argument in its two components. The version
+// is the empty string when not specified.
+func parsePackageVersion(arg string) (string, string) {
+	if i := strings.LastIndex(arg, "@"); i > 0 {
+		return arg[:i], arg[i+1:]
+	}
+
+	return arg, ""
+}
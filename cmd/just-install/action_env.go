@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/environ"
+)
+
+// refreshHelpers are scripts that apply the output of "env --export" to the calling shell, like
+// Chocolatey's refreshenv.
+var refreshHelpers = map[string]string{
+	"refreshenv.cmd": "@echo off\r\nfor /f \"delims=\" %%i in ('just-install env --export --shell cmd') do %%i\r\n",
+	"refreshenv.ps1": "just-install env --export --shell powershell | Out-String | Invoke-Expression\r\n",
+}
+
+// handleEnvAction prints the persistent environment variables changed since the calling shell was
+// started, either as NAME=VALUE lines or as commands for the shell to evaluate.
+func handleEnvAction(c *cli.Context) {
+	if c.String("helper") != "" {
+		for name, content := range refreshHelpers {
+			path := filepath.Join(c.String("helper"), name)
+
+			if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+				log.Fatalln("Cannot write the helper:", err)
+			}
+
+			log.Println("Wrote", path)
+		}
+
+		return
+	}
+
+	changed, err := environ.Changed()
+	if err != nil {
+		log.Fatalln("Cannot read the environment:", err)
+	}
+
+	for _, v := range changed {
+		if !c.Bool("export") {
+			fmt.Printf("%v=%v\n", v.Name, v.Value)
+			continue
+		}
+
+		switch c.String("shell") {
+		case "cmd":
+			fmt.Printf("set \"%v=%v\"\n", v.Name, v.Value)
+		case "powershell":
+			fmt.Printf("${env:%v} = '%v'\n", v.Name, strings.Replace(v.Value, "'", "''", -1))
+		default:
+			log.Fatalf("Unknown shell: %v", c.String("shell"))
+		}
+	}
+}
+
+// envHint tells the user how to pick up the changes made to the persistent environment since the
+// given snapshot, if any.
+func envHint(before []environ.Variable) {
+	after, err := environ.Persistent()
+	if err != nil || environ.Equal(before, after) {
+		return
+	}
+
+	log.Println("The installed packages changed environment variables, to use them in this terminal run:")
+	log.Println("    just-install env --export | Out-String | Invoke-Expression    (PowerShell)")
+	log.Println("    for /f \"delims=\" %i in ('just-install env --export --shell cmd') do %i    (cmd)")
+}
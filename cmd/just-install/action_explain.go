@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleExplainAction prints every operation that installing the given packages would perform,
+// like a verbose dry run.
+func handleExplainAction(c *cli.Context) {
+	if c.NArg() == 0 {
+		log.Fatalln("Usage: just-install explain <package>...")
+	}
+
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	setInstallOptions(c)
+
+	hasErrors := false
+
+	for _, arg := range c.Args() {
+		name, err := registry.Resolve(arg, installState)
+		if err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		entry, channel, err := channelEntry(c, registry, installState, name)
+		if err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		installed, upgrade := registry.InstalledVersion(name, installState)
+
+		operations, err := entry.Explain(upgrade, c.GlobalBool("force"))
+		if err != nil {
+			log.Printf("Cannot explain %v: %v", name, err)
+			hasErrors = true
+			continue
+		}
+
+		if entry.EULA != "" && !installState.AcceptedLicense(name, entry.EULA) {
+			operations = append([]string{"Ask to accept the license agreement: " + entry.EULA}, operations...)
+		}
+
+		if channel == "" {
+			channel = justinstall.DefaultChannel
+		}
+
+		if upgrade {
+			fmt.Printf("%v %v (%v), upgrading from %v:\n", name, entry.Version, channel, installed)
+		} else {
+			fmt.Printf("%v %v (%v):\n", name, entry.Version, channel)
+		}
+
+		if entry.License != "" {
+			fmt.Printf("     License: %v\n", entry.License)
+		}
+
+		for i, operation := range operations {
+			fmt.Printf("%3d. %v\n", i+1, operation)
+		}
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
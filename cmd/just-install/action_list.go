@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
 )
 
 func handleListAction(c *cli.Context) {
@@ -11,6 +14,29 @@ func handleListAction(c *cli.Context) {
 	packageNames := registry.SortedPackageNames()
 
 	for _, name := range packageNames {
-		fmt.Printf("%35v - %v\n", name, registry.Packages[name].Version)
+		entry := registry.Packages[name]
+		if !matchesFilters(c, &entry) {
+			continue
+		}
+
+		if entry.IsGroup() {
+			fmt.Printf("%35v - group: %v\n", name, strings.Join(entry.Group, ", "))
+		} else {
+			fmt.Printf("%35v - %v\n", name, entry.Version)
+		}
+	}
+}
+
+// matchesFilters reports whether the entry has the tag and belongs to the category given with the
+// --tag and --category flags, if any.
+func matchesFilters(c *cli.Context, entry *justinstall.RegistryEntry) bool {
+	if tag := c.String("tag"); tag != "" && !entry.HasTag(tag) {
+		return false
+	}
+
+	if category := c.String("category"); category != "" && !entry.InCategory(category) {
+		return false
 	}
+
+	return true
 }
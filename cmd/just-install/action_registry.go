@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	dry "github.com/ungerik/go-dry"
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleRegistryDiffAction reports the packages added, removed and changed between two registries.
+// With a single registry, it is compared to the official one. Without any, the official registry
+// is compared to the copy it replaced the last time it changed.
+func handleRegistryDiffAction(c *cli.Context) {
+	var old, new justinstall.Registry
+
+	switch c.NArg() {
+	case 0:
+		new = justinstall.LoadRegistrySource(justinstall.DefaultRegistry)
+
+		var ok bool
+		if old, ok = justinstall.PreviousRegistry(); !ok {
+			log.Fatalln("There is no previous registry yet, it is kept when a new one is downloaded")
+		}
+	case 1:
+		old = justinstall.LoadRegistrySource(justinstall.DefaultRegistry)
+		new = justinstall.LoadRegistrySource(c.Args().Get(0))
+	case 2:
+		old = justinstall.LoadRegistrySource(c.Args().Get(0))
+		new = justinstall.LoadRegistrySource(c.Args().Get(1))
+	default:
+		log.Fatalln("Usage: just-install registry diff [[<old>] <new>]")
+	}
+
+	changes := justinstall.DiffRegistries(old, new)
+
+	if c.GlobalBool("json") {
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			log.Fatalln("Cannot encode the changes:", err)
+		}
+
+		os.Stdout.Write(data)
+		return
+	}
+
+	for _, change := range changes {
+		switch change.Kind {
+		case justinstall.EntryAdded:
+			fmt.Printf("+ %v %v\n", change.Package, change.Version)
+		case justinstall.EntryRemoved:
+			fmt.Printf("- %v %v\n", change.Package, change.Version)
+		default:
+			fmt.Printf("~ %v: %v\n", change.Package, strings.Join(change.Details, ", "))
+		}
+	}
+}
+
+// handleRegistryExportAction writes the given packages, with everything they need, to a standalone
+// registry file.
+func handleRegistryExportAction(c *cli.Context) {
+	if c.NArg() == 0 || c.String("output") == "" {
+		log.Fatalln("Usage: just-install registry export <package>... --output <registry.json>")
+	}
+
+	registry := loadRegistry(c)
+
+	exported, err := registry.Export(c.Args())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := justinstall.WriteRegistry(c.String("output"), exported); err != nil {
+		log.Fatalln("Cannot write the registry:", err)
+	}
+
+	log.Printf("Exported %v packages to %v: %v", len(exported.Packages), c.String("output"), strings.Join(exported.SortedPackageNames(), ", "))
+}
+
+// handleRegistryImportWingetAction converts winget packages into registry entries. They are added
+// to the registry file given with --output, replacing the entries with the same name, or printed
+// as a registry otherwise.
+func handleRegistryImportWingetAction(c *cli.Context) {
+	if c.NArg() == 0 {
+		log.Fatalln("Usage: just-install registry import-winget <manifest directory|package identifier>...")
+	}
+
+	output := c.String("output")
+
+	registry := justinstall.NewRegistry()
+	if output != "" && dry.FileExists(output) {
+		var err error
+		if registry, err = justinstall.ReadRegistryFile(output); err != nil {
+			log.Fatalln("Cannot read the registry:", err)
+		}
+	}
+
+	hasErrors := false
+	imported := 0
+
+	for _, source := range c.Args() {
+		name, entry, err := justinstall.ImportWinget(source)
+		if err != nil {
+			log.Printf("Cannot import %v: %v", source, err)
+			hasErrors = true
+			continue
+		}
+
+		if _, ok := registry.Packages[name]; ok {
+			log.Printf("Replacing %v with %v %v", name, source, entry.Version)
+			err = registry.Update(name, entry)
+		} else {
+			log.Printf("Imported %v %v as %v", source, entry.Version, name)
+			err = registry.Add(name, entry)
+		}
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		imported++
+	}
+
+	if imported == 0 {
+		os.Exit(1)
+	}
+
+	if output == "" {
+		data, err := registry.Serialize()
+		if err != nil {
+			log.Fatalln("Cannot encode the registry:", err)
+		}
+
+		os.Stdout.Write(data)
+	} else if err := justinstall.WriteRegistry(output, registry); err != nil {
+		log.Fatalln("Cannot write the registry:", err)
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
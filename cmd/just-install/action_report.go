@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/csv"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	versions "github.com/just-install/just-install/pkg/version"
+)
+
+// reportRow is a single line of the inventory report.
+type reportRow struct {
+	Name             string
+	Channel          string
+	InstalledVersion string
+	RegistryVersion  string
+	UpdateAvailable  string
+	InstalledAt      string
+}
+
+var reportHTMLTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>just-install inventory</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>just-install inventory</h1>
+<p>Generated on {{.Generated}}</p>
+<table>
+<tr><th>Package</th><th>Channel</th><th>Installed version</th><th>Registry version</th><th>Update available</th><th>Installed at</th></tr>
+{{- range .Rows}}
+<tr><td>{{.Name}}</td><td>{{.Channel}}</td><td>{{.InstalledVersion}}</td><td>{{.RegistryVersion}}</td><td>{{.UpdateAvailable}}</td><td>{{.InstalledAt}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+func handleReportAction(c *cli.Context) {
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	var rows []reportRow
+
+	for _, name := range registry.SortedPackageNames() {
+		entry := registry.Packages[name]
+		if entry.IsGroup() {
+			continue
+		}
+
+		row := reportRow{Name: name, Channel: justinstall.DefaultChannel}
+
+		recorded, isRecorded := installState.Installed(name)
+		if isRecorded && recorded.Channel != "" {
+			if channelEntry, err := entry.WithChannel(recorded.Channel); err == nil {
+				entry = channelEntry
+				row.Channel = recorded.Channel
+			}
+		}
+
+		row.RegistryVersion = entry.Version
+
+		if version, ok := registry.InstalledVersion(name, installState); ok {
+			row.InstalledVersion = version
+
+			if latestEntry, err := entry.WithLatestVersion(); err == nil {
+				row.RegistryVersion = latestEntry.Version
+			}
+
+			if isRecorded {
+				row.InstalledAt = recorded.InstalledAt.Format(time.RFC3339)
+			}
+
+			if row.RegistryVersion == "latest" {
+				row.UpdateAvailable = "unknown"
+			} else if versions.Compare(version, row.RegistryVersion) < 0 {
+				row.UpdateAvailable = "yes"
+			} else {
+				row.UpdateAvailable = "no"
+			}
+		} else if !c.Bool("all") {
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	var out io.Writer = os.Stdout
+	if c.String("output") != "" {
+		f, err := os.Create(c.String("output"))
+		if err != nil {
+			log.Fatalln("Cannot create report file:", err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	var err error
+	switch c.String("format") {
+	case "csv":
+		err = writeCSVReport(out, rows)
+	case "html":
+		err = reportHTMLTemplate.Execute(out, map[string]interface{}{
+			"Generated": time.Now().Format(time.RFC1123),
+			"Rows":      rows,
+		})
+	default:
+		log.Fatalf("Unknown report format: %v", c.String("format"))
+	}
+
+	if err != nil {
+		log.Fatalln("Cannot write report:", err)
+	}
+}
+
+func writeCSVReport(w io.Writer, rows []reportRow) error {
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"package", "channel", "installed_version", "registry_version", "update_available", "installed_at"})
+	for _, row := range rows {
+		writer.Write([]string{row.Name, row.Channel, row.InstalledVersion, row.RegistryVersion, row.UpdateAvailable, row.InstalledAt})
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
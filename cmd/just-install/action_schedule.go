@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kardianos/osext"
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleScheduleAddAction creates a Scheduled Task that upgrades the given packages, or all
+// installed ones, on a regular basis.
+func handleScheduleAddAction(c *cli.Context) {
+	var packages []string
+	for _, name := range strings.Split(c.String("packages"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			packages = append(packages, name)
+		}
+	}
+
+	frequency := "daily"
+	if c.Bool("weekly") {
+		frequency = "weekly"
+	}
+
+	if c.Bool("daily") && c.Bool("weekly") {
+		log.Fatalln("Only one of --daily and --weekly can be given")
+	}
+
+	name := c.String("name")
+	if name == "" && len(packages) > 0 {
+		name = "upgrade-" + strings.Join(packages, "-")
+	} else if name == "" {
+		name = "upgrade-all"
+	}
+
+	executable, err := osext.Executable()
+	if err != nil {
+		log.Fatalln("Cannot find the just-install executable:", err)
+	}
+
+	// Scheduled runs use the same registry, locations and network settings as this one
+	args := []string{"--unattended"}
+	if c.GlobalBool("portable") {
+		args = append(args, "--portable")
+	}
+	for _, flag := range []string{"ca-bundle", "cache-dir", "limit-rate", "proxy"} {
+		if c.GlobalIsSet(flag) {
+			args = append(args, "--"+flag, c.GlobalString(flag))
+		}
+	}
+	for _, registry := range c.GlobalStringSlice("registry") {
+		args = append(args, "--registry", registry)
+	}
+	args = append(append(args, "upgrade"), packages...)
+
+	if err := justinstall.Schedule(name, executable, args, frequency, c.String("time")); err != nil {
+		log.Fatalln("Cannot create the scheduled task:", err)
+	}
+
+	log.Printf("Scheduled %v, running %v at %v", name, frequency, c.String("time"))
+}
+
+func handleScheduleListAction(c *cli.Context) {
+	tasks, err := justinstall.ScheduledTasks()
+	if err != nil {
+		log.Fatalln("Cannot list the scheduled tasks:", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tNEXT RUN\tSTATUS\tCOMMAND")
+
+	for _, task := range tasks {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", task.Name, task.NextRun, task.Status, task.Command)
+	}
+
+	w.Flush()
+}
+
+func handleScheduleRemoveAction(c *cli.Context) {
+	if c.NArg() == 0 {
+		log.Fatalln("Usage: just-install schedule remove <name>...")
+	}
+
+	hasErrors := false
+
+	for _, name := range c.Args() {
+		if err := justinstall.Unschedule(name); err != nil {
+			log.Printf("Cannot remove %v: %v", name, err)
+			hasErrors = true
+		}
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleSearchAction lists the packages matching the given words, best matches first. Without
+// words, it lists all the packages with the tag or the category given on the command line.
+func handleSearchAction(c *cli.Context) {
+	if c.NArg() == 0 && c.String("tag") == "" && c.String("category") == "" {
+		log.Fatalln("Usage: just-install search [--tag <tag>] [--category <category>] <word>...")
+	}
+
+	registry := loadRegistry(c)
+
+	var results []justinstall.SearchResult
+	for _, result := range registry.Search(strings.Join(c.Args(), " ")) {
+		entry := registry.Packages[result.Name]
+		if matchesFilters(c, &entry) {
+			results = append(results, result)
+		}
+	}
+
+	if len(results) == 0 {
+		log.Println("No package matches", strings.Join(c.Args(), " "))
+		os.Exit(1)
+	}
+
+	for _, result := range results {
+		entry := registry.Packages[result.Name]
+
+		if entry.IsGroup() {
+			fmt.Printf("%35v - group: %v\n", result.Name, strings.Join(entry.Group, ", "))
+		} else if entry.Description != "" {
+			fmt.Printf("%35v - %v - %v\n", result.Name, entry.Version, entry.Description)
+		} else {
+			fmt.Printf("%35v - %v\n", result.Name, entry.Version)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+func handleSecretDeleteAction(c *cli.Context) {
+	if c.NArg() != 1 {
+		log.Fatalln("Usage: just-install secret delete <name>")
+	}
+
+	store := justinstall.LoadSecrets()
+	if !store.Delete(c.Args().First()) {
+		log.Fatalln("No such secret:", c.Args().First())
+	}
+
+	if err := store.Save(); err != nil {
+		log.Fatalln("Cannot save the secret store:", err)
+	}
+}
+
+func handleSecretListAction(c *cli.Context) {
+	for _, name := range justinstall.LoadSecrets().Names() {
+		fmt.Println(name)
+	}
+}
+
+// handleSecretSetAction stores a secret read from standard input, so that its value doesn't end up
+// in the shell history.
+func handleSecretSetAction(c *cli.Context) {
+	if c.NArg() != 1 {
+		log.Fatalln("Usage: just-install secret set <name>")
+	}
+
+	name := c.Args().First()
+
+	if isTerminal() {
+		fmt.Printf("Value of %v: ", name)
+	}
+
+	line, err := stdin.ReadString('\n')
+	value := strings.TrimRight(line, "\r\n")
+	if value == "" && err != nil {
+		log.Fatalln("Cannot read the value of the secret:", err)
+	} else if value == "" {
+		log.Fatalln("The value of the secret cannot be empty")
+	}
+
+	store := justinstall.LoadSecrets()
+	if err := store.Set(name, value); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := store.Save(); err != nil {
+		log.Fatalln("Cannot save the secret store:", err)
+	}
+}
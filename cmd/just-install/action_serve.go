@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// handleServeAction serves a mirror of the registry and its installers, synchronizing it with the
+// upstream registry periodically. Clients use it with --registry <url>/just-install-v4.json.
+func handleServeAction(c *cli.Context) {
+	root := c.String("root")
+	if root == "" {
+		log.Fatalln("Usage: just-install serve --root <directory> [--listen <address>]")
+	}
+
+	baseURL := c.String("url")
+	if baseURL == "" {
+		baseURL = defaultMirrorURL(c.String("listen"))
+	}
+
+	source := justinstall.MirrorSource
+	if registries := c.GlobalStringSlice("registry"); len(registries) > 1 {
+		log.Fatalln("A mirror can only be made of a single registry")
+	} else if len(registries) == 1 {
+		source = registries[0]
+	}
+
+	go func() {
+		for {
+			log.Println("Synchronizing the mirror with", source)
+
+			if err := justinstall.Mirror(source, root, baseURL); err != nil {
+				log.Println("WARNING: cannot synchronize the mirror:", err)
+			} else {
+				log.Println("Mirror synchronized, the registry is at", baseURL+"/"+justinstall.MirrorRegistryName)
+			}
+
+			time.Sleep(c.Duration("interval"))
+		}
+	}()
+
+	log.Println("Serving", root, "on", c.String("listen"))
+	log.Fatalln(http.ListenAndServe(c.String("listen"), http.FileServer(http.Dir(root))))
+}
+
+// defaultMirrorURL returns the URL clients on the LAN can use to reach a server listening on the
+// given address.
+func defaultMirrorURL(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		log.Fatalln("Invalid listen address:", err)
+	}
+
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		if host, err = os.Hostname(); err != nil {
+			log.Fatalln("Cannot determine the host name, please use --url:", err)
+		}
+	}
+
+	return "http://" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/journal"
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// packageStats aggregates the journal entries of a single package.
+type packageStats struct {
+	runs     int
+	failures int
+	fastest  time.Duration
+	slowest  time.Duration
+	sum      journal.Timings // Of successful runs only
+}
+
+// handleStatsAction prints how long the installations of each package took historically, from the
+// journal. Failed installations are counted but left out of the durations.
+func handleStatsAction(c *cli.Context) {
+	wanted := make(map[string]bool)
+	for _, name := range c.Args() {
+		wanted[name] = true
+	}
+
+	stats := make(map[string]*packageStats)
+	var names []string
+
+	for _, entry := range justinstall.LoadJournal() {
+		if len(wanted) > 0 && !wanted[entry.Package] {
+			continue
+		}
+
+		s, ok := stats[entry.Package]
+		if !ok {
+			s = &packageStats{}
+			stats[entry.Package] = s
+			names = append(names, entry.Package)
+		}
+
+		s.runs++
+		if entry.Error != "" {
+			s.failures++
+			continue
+		}
+
+		total := entry.Timings.Total()
+		if s.runs-s.failures == 1 || total < s.fastest {
+			s.fastest = total
+		}
+		if total > s.slowest {
+			s.slowest = total
+		}
+
+		s.sum.Resolve += entry.Timings.Resolve
+		s.sum.Download += entry.Timings.Download
+		s.sum.Verify += entry.Timings.Verify
+		s.sum.Install += entry.Timings.Install
+	}
+
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tRUNS\tFAILED\tAVERAGE\tFASTEST\tSLOWEST\tRESOLVE\tDOWNLOAD\tVERIFY\tINSTALL")
+
+	for _, name := range names {
+		s := stats[name]
+
+		successful := time.Duration(s.runs - s.failures)
+		if successful == 0 {
+			fmt.Fprintf(w, "%v\t%v\t%v\t-\t-\t-\t-\t-\t-\t-\n", name, s.runs, s.failures)
+			continue
+		}
+
+		average := func(d time.Duration) time.Duration {
+			return journal.Round(d / successful)
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", name, s.runs, s.failures,
+			average(s.sum.Total()), journal.Round(s.fastest), journal.Round(s.slowest),
+			average(s.sum.Resolve), average(s.sum.Download), average(s.sum.Verify), average(s.sum.Install))
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+func handleUninstallAction(c *cli.Context) {
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	setInstallOptions(c)
+
+	hasErrors := false
+
+	for _, arg := range c.Args() {
+		name, err := registry.Resolve(arg, installState)
+		if err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		entry := registry.Packages[name]
+		if entry.IsGroup() {
+			log.Printf("Cannot uninstall %v: uninstall the members of a group one by one", name)
+			hasErrors = true
+			continue
+		}
+
+		if _, ok := registry.InstalledVersion(name, installState); !ok {
+			log.Printf("%v is not installed", name)
+			continue
+		}
+
+		// Use the uninstall settings of the channel the package was installed from
+		if recorded, ok := installState.Installed(name); ok {
+			if channelEntry, err := entry.WithChannel(recorded.Channel); err == nil {
+				entry = channelEntry
+			}
+		}
+
+		if err := entry.Uninstall(); err != nil {
+			log.Printf("Error uninstalling %v: %v", name, err)
+			hasErrors = true
+			continue
+		}
+
+		if err := justinstall.RevertEnvironment(installState, name); err != nil {
+			log.Printf("Error restoring the environment after uninstalling %v: %v", name, err)
+			hasErrors = true
+		}
+
+		installState.RecordUninstall(name)
+		if err := installState.Save(); err != nil {
+			log.Println("WARNING: could not save the state database:", err)
+		}
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	versions "github.com/just-install/just-install/pkg/version"
+)
+
+// handleUpgradeAction upgrades the given packages, or all installed ones, to the version offered by
+// the registry when it is newer than the installed one.
+func handleUpgradeAction(c *cli.Context) {
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	setInstallOptions(c)
+
+	requested := []string(c.Args())
+	if len(requested) == 0 {
+		for _, name := range registry.SortedPackageNames() {
+			entry := registry.Packages[name]
+			if _, ok := registry.InstalledVersion(name, installState); ok && !entry.IsGroup() {
+				requested = append(requested, name)
+			}
+		}
+	}
+
+	hasErrors := false
+
+	for _, arg := range requested {
+		name, err := registry.Resolve(arg, installState)
+		if err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		installed, ok := registry.InstalledVersion(name, installState)
+		if !ok {
+			log.Printf("%v is not installed", name)
+			hasErrors = true
+			continue
+		}
+
+		entry, channel, err := channelEntry(c, registry, installState, name)
+		if err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		if entry.Version == "latest" || versions.Compare(installed, entry.Version) >= 0 {
+			fmt.Printf("%v: up to date (%v)\n", name, installed)
+			continue
+		}
+
+		if err := requireVariables(name, entry); err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		if err := requireLicense(installState, name, entry); err != nil {
+			log.Println(err)
+			hasErrors = true
+			continue
+		}
+
+		if c.String("backup") != "" && len(entry.Config) > 0 {
+			archive, err := entry.BackupConfig(name, c.String("backup"))
+			if err != nil {
+				log.Printf("Cannot back up %v, not upgrading it: %v", name, err)
+				hasErrors = true
+				continue
+			}
+
+			log.Printf("Configuration of %v backed up to %v", name, archive)
+		}
+
+		if err := installEntry(registry, installState, name, entry, c.GlobalBool("force")); err != nil {
+			log.Printf("Error upgrading %v: %v", name, err)
+			hasErrors = true
+			continue
+		}
+
+		recordInstall(installState, name, entry, channel)
+		fmt.Printf("%v: %v -> %v\n", name, installed, entry.Version)
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/signature"
+)
+
+// wdacFile is a file installed, or run, by just-install that application control policies must
+// allow.
+type wdacFile struct {
+	Package string
+	Version string
+	Role    string // "installer" or "binary"
+	signature.Info
+}
+
+// handleWDACExportAction exports the hashes and signers of the installers and binaries of installed
+// packages as JSON, for use with Windows Defender Application Control policy tooling.
+func handleWDACExportAction(c *cli.Context) {
+	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
+
+	var files []wdacFile
+	var paths []string
+
+	add := func(name string, version string, role string, path string) {
+		files = append(files, wdacFile{Package: name, Version: version, Role: role})
+		paths = append(paths, path)
+	}
+
+	for _, name := range registry.SortedPackageNames() {
+		entry := registry.Packages[name]
+		if entry.IsGroup() {
+			continue
+		}
+
+		version, ok := registry.InstalledVersion(name, installState)
+		if !ok {
+			continue
+		}
+
+		if recorded, ok := installState.Installed(name); ok {
+			if channelEntry, err := entry.WithChannel(recorded.Channel); err == nil {
+				entry = channelEntry
+			}
+		}
+
+		if installer, ok := entry.CachedInstaller(); ok {
+			add(name, version, "installer", installer)
+		}
+
+		for _, binary := range entry.Binaries() {
+			add(name, version, "binary", binary)
+		}
+	}
+
+	infos, err := signature.Inspect(paths)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for i := range files {
+		files[i].Info = infos[i]
+	}
+
+	var out io.Writer = os.Stdout
+	if c.String("output") != "" {
+		f, err := os.Create(c.String("output"))
+		if err != nil {
+			log.Fatalln("Cannot create export file:", err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(files); err != nil {
+		log.Fatalln("Cannot write export:", err)
+	}
+}
@@ -21,13 +21,18 @@ package main
 import (
 	"debug/pe"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/just-install/just-install/pkg/environ"
+	"github.com/just-install/just-install/pkg/journal"
 	"github.com/just-install/just-install/pkg/justinstall"
 	"github.com/kardianos/osext"
+	dry "github.com/ungerik/go-dry"
 	"github.com/urfave/cli"
 )
 
@@ -40,64 +45,463 @@ func main() {
 	app.Name = "just-install"
 	app.Usage = "The simple package installer for Windows"
 	app.Version = version
+	app.Before = func(c *cli.Context) error {
+		if c.GlobalBool("unattended") {
+			unattended = true
+			justinstall.SetUnattended(true)
+		}
+
+		acceptLicenses = c.GlobalBool("accept-licenses")
+
+		if err := setupPortable(c); err != nil {
+			log.Fatalln("Cannot enable portable mode:", err)
+		}
+
+		cfg := justinstall.LoadConfig()
+		if c.GlobalIsSet("ca-bundle") {
+			cfg.CABundle = c.GlobalString("ca-bundle")
+		}
+		if c.GlobalIsSet("cache-dir") {
+			cfg.CacheDir = c.GlobalString("cache-dir")
+		}
+		if c.GlobalIsSet("downloader") {
+			cfg.Downloader = c.GlobalString("downloader")
+		}
+		if c.GlobalIsSet("progress") {
+			cfg.Progress = c.GlobalString("progress")
+		}
+		if c.GlobalIsSet("limit-rate") {
+			cfg.LimitRate = c.GlobalString("limit-rate")
+		}
+		if c.GlobalIsSet("proxy") {
+			cfg.Proxy = c.GlobalString("proxy")
+		}
+		// GlobalIsSet doesn't see the flag when it is given as -r
+		if registries := c.GlobalStringSlice("registry"); len(registries) > 0 {
+			cfg.Registries = registries
+		}
+
+		justinstall.Configure(cfg)
+
+		justinstall.SetInsecureRegistry(c.GlobalBool("insecure-registry"))
+		justinstall.SetRefreshRegistry(c.GlobalBool("refresh"))
+
+		if c.GlobalBool("offline") || c.GlobalIsSet("offline-mirror") {
+			justinstall.SetOffline(c.GlobalString("offline-mirror"))
+		}
+
+		return nil
+	}
 
 	app.Commands = []cli.Command{{
+		Name:  "admin",
+		Usage: "Tools for registry maintainers",
+		Subcommands: []cli.Command{{
+			Name:      "hash",
+			Usage:     "Download the installers of a package and print their SHA-256 hashes",
+			ArgsUsage: "<package>",
+			Action:    handleAdminHashAction,
+			Flags: []cli.Flag{cli.StringFlag{
+				Name:  "write, w",
+				Usage: "Fill in the hashes in the given registry file instead",
+			}},
+		}},
+	}, {
+		Name:      "apply",
+		Usage:     "Install the packages listed in a manifest file, honoring version constraints",
+		ArgsUsage: "<manifest.json>",
+		Action:    handleApplyAction,
+	}, {
 		Name:   "audit",
 		Usage:  "Audit the registry",
 		Action: handleAuditAction,
+	}, {
+		Name:      "backup",
+		Usage:     "Archive the configuration of packages",
+		ArgsUsage: "<package>...",
+		Action:    handleBackupAction,
+		Flags: []cli.Flag{cli.StringFlag{
+			Name:  "to",
+			Usage: "Directory where the archives are written",
+		}},
+	}, {
+		Name:  "cache",
+		Usage: "Manage the download cache",
+		Subcommands: []cli.Command{{
+			Name:   "gc",
+			Usage:  "Remove the partial downloads left behind by crashed or interrupted downloads",
+			Action: handleCacheGCAction,
+			Flags: []cli.Flag{cli.DurationFlag{
+				Name:  "max-age",
+				Usage: "Age after which interrupted downloads are no longer kept to be resumed",
+				Value: 7 * 24 * time.Hour,
+			}},
+		}, {
+			Name:      "move",
+			Usage:     "Move the download cache and its contents to another directory",
+			ArgsUsage: "<newpath>",
+			Action:    handleCacheMoveAction,
+		}, {
+			Name:   "list",
+			Usage:  "List the files in the download cache, least recently used first",
+			Action: handleCacheListAction,
+		}, {
+			Name:   "path",
+			Usage:  "Print the directory of the download cache",
+			Action: handleCachePathAction,
+		}, {
+			Name:   "prune",
+			Usage:  "Remove the least recently used files from the download cache",
+			Action: handleCachePruneAction,
+			Flags: []cli.Flag{cli.IntFlag{
+				Name:  "max-size",
+				Usage: "Size in MB the download cache is pruned to, 0 empties it",
+			}},
+		}, {
+			Name:   "verify",
+			Usage:  "Hash the files in the download cache again and report the corrupted ones",
+			Action: handleCacheVerifyAction,
+			Flags: []cli.Flag{cli.BoolFlag{
+				Name:  "redownload",
+				Usage: "Download the corrupted files again",
+			}},
+		}},
+	}, {
+		Name:      "choco-export",
+		Usage:     "Convert registry entries into skeleton Chocolatey packages",
+		ArgsUsage: "<package>...",
+		Action:    handleChocoExportAction,
+		Flags: []cli.Flag{cli.StringFlag{
+			Name:  "output, o",
+			Usage: "Directory where the packages are written",
+			Value: ".",
+		}},
 	}, {
 		Name:   "clean",
 		Usage:  "Remove caches and temporary files",
 		Action: handleCleanAction,
+		Flags: []cli.Flag{cli.BoolFlag{
+			Name:  "verify",
+			Usage: "Only remove the cached files whose contents changed since they were downloaded",
+		}},
+	}, {
+		Name:      "download",
+		Usage:     "Download the installers of packages to the cache without installing them",
+		ArgsUsage: "<package>...",
+		Action:    handleDownloadAction,
+		Flags: []cli.Flag{cli.StringSliceFlag{
+			Name:  "arch, a",
+			Usage: "Download the installers for this architecture (can be repeated)",
+		}},
+	}, {
+		Name:      "ensure",
+		Usage:     "Install packages only if they are not already at the wanted version",
+		ArgsUsage: "<package>[@version]...",
+		Action:    handleEnsureAction,
+	}, {
+		Name:   "env",
+		Usage:  "Print the environment variables changed by installers since this terminal was opened",
+		Action: handleEnvAction,
+		Flags: []cli.Flag{cli.BoolFlag{
+			Name:  "export",
+			Usage: "Print commands that apply the changes, for the calling shell to evaluate",
+		}, cli.StringFlag{
+			Name:  "helper",
+			Usage: "Write refreshenv.cmd and refreshenv.ps1 helpers applying the changes to the given directory",
+		}, cli.StringFlag{
+			Name:  "shell",
+			Usage: "Shell to print commands for, \"powershell\" or \"cmd\"",
+			Value: "powershell",
+		}},
+	}, {
+		Name:      "explain",
+		Usage:     "Print every operation that installing packages would perform, without performing them",
+		ArgsUsage: "<package>...",
+		Action:    handleExplainAction,
 	}, {
 		Name:   "list",
 		Usage:  "List all known packages",
 		Action: handleListAction,
+		Flags: []cli.Flag{cli.StringFlag{
+			Name:  "category",
+			Usage: "Only show the packages of the given category",
+		}, cli.StringFlag{
+			Name:  "tag",
+			Usage: "Only show the packages with the given tag",
+		}},
+	}, {
+		Name:  "registry",
+		Usage: "Inspect registries",
+		Subcommands: []cli.Command{{
+			Name:      "diff",
+			Usage:     "Report the packages added, removed and changed between two registries",
+			ArgsUsage: "[[<old>] <new>]",
+			Action:    handleRegistryDiffAction,
+		}, {
+			Name:      "export",
+			Usage:     "Write packages, with their dependencies, to a standalone registry file",
+			ArgsUsage: "<package>...",
+			Action:    handleRegistryExportAction,
+			Flags: []cli.Flag{cli.StringFlag{
+				Name:  "output, o",
+				Usage: "Registry file to write",
+			}},
+		}, {
+			Name:      "import-winget",
+			Usage:     "Convert winget packages, from manifest directories or winget-pkgs, to registry entries",
+			ArgsUsage: "<manifest directory|package identifier>...",
+			Action:    handleRegistryImportWingetAction,
+			Flags: []cli.Flag{cli.StringFlag{
+				Name:  "output, o",
+				Usage: "Registry file to add the entries to, instead of printing them",
+			}},
+		}},
+	}, {
+		Name:   "report",
+		Usage:  "Export an inventory of installed packages",
+		Action: handleReportAction,
+		Flags: []cli.Flag{cli.BoolFlag{
+			Name:  "all",
+			Usage: "Include packages that are not installed",
+		}, cli.StringFlag{
+			Name:  "format",
+			Usage: "Report format, either csv or html",
+			Value: "csv",
+		}, cli.StringFlag{
+			Name:  "output, o",
+			Usage: "Write the report to the given file instead of standard output",
+		}},
+	}, {
+		Name:      "restore",
+		Usage:     "Put back the configuration archived by backup",
+		ArgsUsage: "<archive>...",
+		Action:    handleRestoreAction,
+	}, {
+		Name:  "schedule",
+		Usage: "Manage Scheduled Tasks that upgrade packages automatically",
+		Subcommands: []cli.Command{{
+			Name:   "add",
+			Usage:  "Create a Scheduled Task upgrading the given packages, or all installed ones",
+			Action: handleScheduleAddAction,
+			Flags: []cli.Flag{cli.BoolFlag{
+				Name:  "daily",
+				Usage: "Run every day (the default)",
+			}, cli.StringFlag{
+				Name:  "name",
+				Usage: "Name of the task (default: derived from the packages)",
+			}, cli.StringFlag{
+				Name:  "packages",
+				Usage: "Comma-separated list of packages to upgrade",
+			}, cli.StringFlag{
+				Name:  "time",
+				Usage: "Time of the day to run at (HH:MM)",
+				Value: "03:00",
+			}, cli.BoolFlag{
+				Name:  "weekly",
+				Usage: "Run every Sunday",
+			}},
+		}, {
+			Name:   "list",
+			Usage:  "List the Scheduled Tasks created by just-install",
+			Action: handleScheduleListAction,
+		}, {
+			Name:      "remove",
+			Usage:     "Remove Scheduled Tasks",
+			ArgsUsage: "<name>...",
+			Action:    handleScheduleRemoveAction,
+		}},
+	}, {
+		Name:      "search",
+		Usage:     "Find packages by name, tag or description, best matches first",
+		ArgsUsage: "[<word>...]",
+		Action:    handleSearchAction,
+		Flags: []cli.Flag{cli.StringFlag{
+			Name:  "category",
+			Usage: "Only show the packages of the given category",
+		}, cli.StringFlag{
+			Name:  "tag",
+			Usage: "Only show the packages with the given tag",
+		}},
+	}, {
+		Name:  "secret",
+		Usage: "Manage the license keys and tokens stored on this machine",
+		Subcommands: []cli.Command{{
+			Name:      "delete",
+			Usage:     "Delete a secret",
+			ArgsUsage: "<name>",
+			Action:    handleSecretDeleteAction,
+		}, {
+			Name:   "list",
+			Usage:  "List the names of the stored secrets",
+			Action: handleSecretListAction,
+		}, {
+			Name:      "set",
+			Usage:     "Store a secret, reading its value from standard input",
+			ArgsUsage: "<name>",
+			Action:    handleSecretSetAction,
+		}},
+	}, {
+		Name:   "serve",
+		Usage:  "Serve a mirror of the registry and its installers over HTTP",
+		Action: handleServeAction,
+		Flags: []cli.Flag{cli.DurationFlag{
+			Name:  "interval",
+			Usage: "Time between two synchronizations with the upstream registry",
+			Value: 24 * time.Hour,
+		}, cli.StringFlag{
+			Name:  "listen",
+			Usage: "Address to listen on",
+			Value: ":8080",
+		}, cli.StringFlag{
+			Name:  "root",
+			Usage: "Directory where the mirror is stored",
+		}, cli.StringFlag{
+			Name:  "url",
+			Usage: "Base URL clients use to reach this server (default: http://<hostname>:<port>)",
+		}},
+	}, {
+		Name:      "stats",
+		Usage:     "Show how long past installations took, phase by phase",
+		ArgsUsage: "[<package>...]",
+		Action:    handleStatsAction,
+	}, {
+		Name:      "uninstall",
+		Usage:     "Uninstall packages",
+		ArgsUsage: "<package>...",
+		Action:    handleUninstallAction,
 	}, {
 		Name:   "update",
 		Usage:  "Update the registry",
 		Action: handleUpdateAction,
+	}, {
+		Name:      "upgrade",
+		Usage:     "Upgrade installed packages to the version offered by the registry",
+		ArgsUsage: "[<package>...]",
+		Action:    handleUpgradeAction,
+		Flags: []cli.Flag{cli.StringFlag{
+			Name:  "backup",
+			Usage: "Archive the configuration of each package to the given directory before upgrading it",
+		}},
+	}, {
+		Name:   "wdac-export",
+		Usage:  "Export the hashes and signers of installed binaries for application control policies",
+		Action: handleWDACExportAction,
+		Flags: []cli.Flag{cli.StringFlag{
+			Name:  "output, o",
+			Usage: "Write the export to the given file instead of standard output",
+		}},
 	}}
 
-	app.Flags = []cli.Flag{cli.StringFlag{
+	app.Flags = []cli.Flag{cli.BoolFlag{
+		Name:  "accept-licenses",
+		Usage: "Accept the license agreements of packages without asking",
+	}, cli.StringFlag{
 		Name:  "arch, a",
 		Usage: "Force installation for a specific architecture (if supported by the host).",
+	}, cli.StringFlag{
+		Name:  "ca-bundle",
+		Usage: "Trust the certificate authorities of the given PEM file, in addition to the system ones",
+	}, cli.StringFlag{
+		Name:  "cache-dir",
+		Usage: "Keep downloaded installers in the specified directory",
 	}, cli.BoolFlag{
 		Name:  "download-only, d",
 		Usage: "Only download packages, do not install them",
+	}, cli.StringFlag{
+		Name:  "downloader",
+		Usage: "Download with \"http\" (the default) or through persistent \"bits\" jobs that survive reboots",
+	}, cli.StringFlag{
+		Name:  "channel, c",
+		Usage: "Install packages from the given release channel (e.g. beta), remembered for future installs",
+	}, cli.StringSliceFlag{
+		Name:  "env, e",
+		Usage: "Set an environment variable (KEY=VALUE) for installer processes, can be repeated",
 	}, cli.BoolFlag{
 		Name:  "force, f",
 		Usage: "Force package re-download",
+	}, cli.BoolFlag{
+		Name:  "insecure-registry",
+		Usage: "Use the official registry even if its signature is missing or invalid",
+	}, cli.BoolFlag{
+		Name:  "interactive",
+		Usage: "Run the user interface of installers, to customize the installation, instead of installing silently",
+	}, cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the summary of installs, with download statistics, as JSON on standard output",
+	}, cli.StringFlag{
+		Name:  "limit-rate",
+		Usage: "Limit the download rate to the given bytes per second, with an optional k, M or G suffix (e.g. 500k)",
+	}, cli.BoolFlag{
+		Name:  "no-deps",
+		Usage: "Do not install the missing dependencies of packages",
+	}, cli.BoolFlag{
+		Name:  "offline",
+		Usage: "Never access the network, take installers from the download cache (or --offline-mirror) only",
+	}, cli.StringFlag{
+		Name:  "offline-mirror",
+		Usage: "Take the installers missing from the download cache from the given mirror directory, implies --offline",
+	}, cli.BoolFlag{
+		Name:  "portable",
+		Usage: "Keep cache, configuration, state and logs next to the executable (also enabled by a portable.flag file there)",
+	}, cli.StringFlag{
+		Name:  "progress",
+		Usage: "Show download progress as a \"bar\", as periodic \"lines\" or \"none\", \"auto\" picks lines when not on a terminal",
 	}, cli.StringFlag{
+		Name:  "proxy",
+		Usage: "Download through the given HTTP or SOCKS5 proxy (e.g. socks5://127.0.0.1:1080)",
+	}, cli.BoolFlag{
+		Name:  "refresh",
+		Usage: "Check for a new registry even if the downloaded one is recent",
+	}, cli.StringSliceFlag{
 		Name:  "registry, r",
-		Usage: "Use the specified registry file",
+		Usage: "Use the specified registry file or URL, repeat to merge several (\"default\" is the official one)",
+	}, cli.BoolFlag{
+		Name:  "restore-point",
+		Usage: "Create a System Restore point before installing system-level packages",
+	}, cli.StringFlag{
+		Name:  "scope",
+		Usage: "Install packages for the whole \"machine\" or the current \"user\" (if supported by the installer)",
+	}, cli.StringSliceFlag{
+		Name:  "set",
+		Usage: "Set a variable (KEY=VALUE) for the templates of registry entries, can be repeated",
 	}, cli.BoolFlag{
 		Name:  "shim, s",
 		Usage: "Create shims only (if exeproxy is installed)",
+	}, cli.BoolFlag{
+		Name:   "unattended",
+		Usage:  "Run without progress bars or prompts, fail on any skipped package and install for the whole machine",
+		EnvVar: "JUST_INSTALL_UNATTENDED",
 	}}
 
 	// Extract arguments embedded in the executable (if any)
 	pathname, err := osext.Executable()
 	if err != nil {
-		app.Run(os.Args)
+		run(app, os.Args)
 		return
 	}
 
 	rawOverlayData, err := getPeOverlayData(pathname)
 	if err != nil {
-		app.Run(os.Args)
+		run(app, os.Args)
 		return
 	}
 
 	stringOverlayData := string(rawOverlayData)
 	trimmedStringOverlayData := strings.Trim(stringOverlayData, "\r\n ")
 	if len(trimmedStringOverlayData) == 0 {
-		app.Run(os.Args)
+		run(app, os.Args)
 		return
 	}
 
 	log.Println("Using embedded arguments: " + trimmedStringOverlayData)
-	app.Run(append([]string{os.Args[0]}, strings.Split(trimmedStringOverlayData, " ")...))
+	run(app, append([]string{os.Args[0]}, strings.Split(trimmedStringOverlayData, " ")...))
+}
+
+// run runs the application, exiting with a non-zero status if the command line could not be parsed.
+func run(app *cli.App, args []string) {
+	if err := app.Run(args); err != nil {
+		os.Exit(1)
+	}
 }
 
 func handleArguments(c *cli.Context) {
@@ -106,28 +510,117 @@ func handleArguments(c *cli.Context) {
 	onlyShims := c.Bool("shim")
 
 	registry := loadRegistry(c)
+	installState := justinstall.LoadState()
 
-	if c.String("arch") != "" {
-		if err := justinstall.SetArchitecture(c.String("arch")); err != nil {
-			log.Fatalln(err.Error())
+	setInstallOptions(c)
+
+	// Map capabilities to the packages providing them and expand groups
+	var packages []string
+	entries := make(map[string]justinstall.RegistryEntry)
+	channels := make(map[string]string)
+
+	// Unattended runs must not succeed if anything was left out
+	hasErrors := false
+
+	for _, arg := range c.Args() {
+		name, wantedVersion := parsePackageVersion(arg)
+
+		expanded, err := registry.Expand(name, installState)
+		if err != nil {
+			log.Println("WARNING:", err)
+			hasErrors = hasErrors || unattended
+			continue
+		}
+
+		if len(expanded) != 1 || expanded[0] != name {
+			log.Printf("Using %v for %v", strings.Join(expanded, ", "), name)
+		}
+
+		if wantedVersion != "" && len(expanded) != 1 {
+			log.Printf("WARNING: cannot install %v: groups have no version", arg)
+			hasErrors = hasErrors || unattended
+			continue
+		}
+
+		for _, pkg := range expanded {
+			// A specific version of a deprecated package is wanted on purpose
+			pkg = replaceDeprecated(registry, pkg, wantedVersion == "")
+
+			if dry.StringInSlice(pkg, packages) {
+				continue
+			}
+
+			entry, channel, err := channelEntry(c, registry, installState, pkg)
+			if err == nil && wantedVersion != "" {
+				if entry, err = entry.WithVersion(wantedVersion); err != nil {
+					err = fmt.Errorf("%v: %v", pkg, err)
+				}
+			}
+			if err != nil {
+				log.Println("WARNING:", err)
+				hasErrors = hasErrors || unattended
+				continue
+			}
+
+			packages = append(packages, pkg)
+			entries[pkg] = entry
+			channels[pkg] = channel
 		}
 	}
 
-	// Check which packages might require an interactive installation
-	var interactive []string
+	// Install the missing dependencies first, from their default channel
+	if !c.GlobalBool("no-deps") {
+		withDependencies, err := registry.WithDependencies(packages, installState)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		for _, pkg := range withDependencies {
+			if _, ok := entries[pkg]; ok {
+				continue
+			}
+
+			entry, err := registry.Packages[pkg].WithLatestVersion()
+			if err != nil {
+				log.Fatalf("%v: %v", pkg, err)
+			}
+
+			log.Printf("Adding %v, which is a dependency", pkg)
+			replaceDeprecated(registry, pkg, false)
+			entries[pkg] = entry
+		}
 
-	for _, pkg := range c.Args() {
-		entry, ok := registry.Packages[pkg]
-		if !ok {
+		packages = withDependencies
+	}
+
+	// Ask for the variables of all packages, and for their license agreements, before starting to
+	// install any of them
+	for _, pkg := range packages {
+		if err := requireVariables(pkg, entries[pkg]); err != nil {
+			log.Fatalln(err)
+		}
+
+		if onlyDownload || onlyShims {
 			continue
 		}
 
-		if entry.Installer.Interactive {
+		if err := requireLicense(installState, pkg, entries[pkg]); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	// Check which packages might require an interactive installation
+	var interactive []string
+
+	for _, pkg := range packages {
+		if entries[pkg].Installer.Interactive {
 			interactive = append(interactive, pkg)
 		}
 	}
 
-	if len(interactive) > 0 {
+	if len(interactive) > 0 && unattended && !onlyDownload && !onlyShims {
+		log.Fatalln("These packages require user interaction and cannot be installed unattended:", strings.Join(interactive, ", "))
+	} else if len(interactive) > 0 {
 		log.Println("These packages might require user interaction to complete their installation")
 
 		for _, pkg := range interactive {
@@ -137,28 +630,66 @@ func handleArguments(c *cli.Context) {
 		log.Println("")
 	}
 
+	// Offline, report everything that is missing at once rather than failing on the first package
+	if justinstall.IsOffline() && !onlyShims {
+		requireOfflineFiles(packages, entries)
+	}
+
+	// Download the missing installers together, forced downloads are left to each package
+	if !onlyShims && !force {
+		var toDownload []justinstall.RegistryEntry
+		for _, pkg := range packages {
+			toDownload = append(toDownload, entries[pkg])
+		}
+
+		justinstall.Prefetch(toDownload)
+	}
+
 	// Install packages
-	hasErrors := false
+	failed := make(map[string]bool)
+	environment, _ := environ.Persistent()
 
-	for _, pkg := range c.Args() {
-		entry, ok := registry.Packages[pkg]
+	for _, pkg := range packages {
+		entry := entries[pkg]
 
-		if ok {
-			if onlyShims {
-				entry.CreateShims()
-			} else if onlyDownload {
-				entry.DownloadInstaller(force)
+		if onlyShims {
+			entry.CreateShims()
+		} else if onlyDownload {
+			entry.DownloadInstaller(force)
+		} else {
+			if err := installEntry(registry, installState, pkg, entry, force); err != nil {
+				log.Printf("Error installing %v: %v", pkg, err)
+				failed[pkg] = true
+				hasErrors = true
 			} else {
-				if err := entry.JustInstall(force); err != nil {
-					log.Printf("Error installing %v: %v", pkg, err)
-					hasErrors = true
-				}
+				recordInstall(installState, pkg, entry, channels[pkg])
 			}
-		} else {
-			log.Println("WARNING: Unknown package", pkg)
 		}
 	}
 
+	if len(packages) > 0 && !onlyShims && !onlyDownload && c.GlobalBool("json") {
+		printJSONSummary(packages, entries, failed)
+		envHint(environment)
+	} else if len(packages) > 0 && !onlyShims && !onlyDownload {
+		log.Println("Summary:")
+
+		for _, pkg := range packages {
+			outcome := "installed"
+			if failed[pkg] {
+				outcome = "failed"
+			}
+
+			timings := entries[pkg].Timings()
+			log.Printf("    %v: %v in %v (%v)", pkg, outcome, journal.Round(timings.Total()), timings)
+		}
+
+		if len(packages) > 1 {
+			printDownloadStats()
+		}
+
+		envHint(environment)
+	}
+
 	if hasErrors {
 		log.Fatalln("Encountered errors installing packages")
 	}
@@ -1,24 +1,430 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/kardianos/osext"
 	"github.com/ungerik/go-dry"
 	"github.com/urfave/cli"
 
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/journal"
 	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
 )
 
 func loadRegistry(c *cli.Context) justinstall.Registry {
-	if !c.GlobalIsSet("registry") {
+	if len(justinstall.Registries()) == 0 {
 		return justinstall.SmartLoadRegistry(false)
 	}
 
-	registryPath := c.GlobalString("registry")
-	if !dry.FileExists(registryPath) {
-		log.Fatalf("%v: no such file.\n", registryPath)
+	return justinstall.LoadRegistries(justinstall.Registries())
+}
+
+// requireOfflineFiles exits listing the files that the given packages need and that cannot be
+// found offline, if any.
+func requireOfflineFiles(packages []string, entries map[string]justinstall.RegistryEntry) {
+	var missing []string
+
+	for _, pkg := range packages {
+		entry := entries[pkg]
+
+		urls, err := entry.OfflineMissing()
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("%v: %v", pkg, err))
+			continue
+		}
+
+		for _, url := range urls {
+			missing = append(missing, fmt.Sprintf("%v: %v", pkg, url))
+		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	log.Println("These files are not available offline:")
+	for _, line := range missing {
+		log.Println("    " + line)
+	}
+
+	os.Exit(1)
+}
+
+// installEntry installs a package, as an upgrade if some version of it is already installed, and
+// records the outcome in the journal.
+func installEntry(registry justinstall.Registry, installState *state.State, name string, entry justinstall.RegistryEntry, force bool) error {
+	record := journal.Entry{
+		Time:      time.Now(),
+		Package:   name,
+		Version:   entry.Version,
+		Arch:      entry.InstallerArch(),
+		Operation: "install",
+	}
+
+	var err error
+	if _, ok := registry.InstalledVersion(name, installState); ok {
+		record.Operation = "upgrade"
+		err = entry.JustUpgrade(force)
+	} else {
+		err = entry.JustInstall(force)
+	}
+
+	if err == nil {
+		if err = entry.ApplyEnvironment(installState, name); err != nil {
+			err = fmt.Errorf("cannot change the environment: %v", err)
+
+			// Keep the record of the changes made until then, recordInstall saves it otherwise
+			if err := installState.Save(); err != nil {
+				log.Println("WARNING: could not save the state database:", err)
+			}
+		}
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	record.Timings = entry.Timings()
+	if err := justinstall.AppendJournal(record); err != nil {
+		log.Println("WARNING: could not update the journal:", err)
+	}
+
+	return err
+}
+
+// recordInstall records a successful installation in the state database, saving it immediately so
+// that an interrupted batch of installations still leaves an accurate record behind.
+func recordInstall(installState *state.State, name string, entry justinstall.RegistryEntry, channel string) {
+	installState.RecordInstall(name, entry.Version, entry.InstallerArch(), channel)
+
+	if err := installState.Save(); err != nil {
+		log.Println("WARNING: could not save the state database:", err)
+	}
+}
+
+// channelEntry returns the registry entry of a package for the release channel given on the
+// command line or, failing that, for the channel it was last installed from. The name of the chosen
+// channel is returned as well, the empty string meaning the default one.
+func channelEntry(c *cli.Context, registry justinstall.Registry, installState *state.State, name string) (justinstall.RegistryEntry, string, error) {
+	channel := c.GlobalString("channel")
+	if channel == "" {
+		if installed, ok := installState.Installed(name); ok {
+			channel = installed.Channel
+		}
+	}
+
+	if channel == justinstall.DefaultChannel {
+		channel = ""
+	}
+
+	entry, err := registry.Packages[name].WithChannel(channel)
+	if err != nil {
+		return entry, "", fmt.Errorf("%v: %v", name, err)
+	}
+
+	entry, err = entry.WithLatestVersion()
+	if err != nil {
+		return entry, "", fmt.Errorf("%v: %v", name, err)
+	}
+
+	return entry, channel, nil
+}
+
+// portableFlagFile enables portable mode when found next to the executable.
+const portableFlagFile = "portable.flag"
+
+// setupPortable enables portable mode when requested, either with the --portable flag or with a
+// portable.flag file next to the executable. In portable mode all the files of just-install,
+// including a log of each run, are kept in the directory of the executable.
+func setupPortable(c *cli.Context) error {
+	dir, err := osext.ExecutableFolder()
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalBool("portable") && !dry.FileExists(filepath.Join(dir, portableFlagFile)) {
+		return nil
+	}
+
+	justinstall.SetPortable(dir)
+
+	logFile, err := os.OpenFile(filepath.Join(dir, "just-install.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+
+	return nil
+}
+
+// setInstallOptions applies the global flags that affect how packages are installed.
+func setInstallOptions(c *cli.Context) {
+	if c.GlobalString("arch") != "" {
+		if err := justinstall.SetArchitecture(c.GlobalString("arch")); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+
+	if c.GlobalBool("interactive") && unattended {
+		log.Fatalln("--interactive cannot be used with --unattended")
+	}
+
+	justinstall.SetInteractive(c.GlobalBool("interactive"))
+	justinstall.SetRestorePoints(c.GlobalBool("restore-point"))
+
+	scope := c.GlobalString("scope")
+	if scope == "" && unattended {
+		scope = "machine"
+	}
+
+	if err := justinstall.SetScope(scope); err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	if err := justinstall.SetInstallerEnv(c.GlobalStringSlice("env")); err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	if err := justinstall.SetVariables(c.GlobalStringSlice("set")); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// unattended is set by the --unattended flag, in which case nothing is ever asked to the user.
+var unattended = false
+
+// requireVariables makes sure that the variables declared by an entry have a value, then that all
+// the templates of the entry can be expanded.
+func requireVariables(name string, entry justinstall.RegistryEntry) error {
+	if err := promptVariables(name, entry); err != nil {
+		return err
+	}
+
+	if err := entry.CheckTemplates(); err != nil {
+		return fmt.Errorf("%v: %v", name, err)
+	}
+
+	return nil
+}
+
+// promptVariables gives a value to the variables declared by an entry. When running in a terminal
+// the user is prompted for them, otherwise defaults are used and variables without one must have
+// been given with --set.
+func promptVariables(name string, entry justinstall.RegistryEntry) error {
+	unset := entry.UnsetVariables()
+	if len(unset) == 0 {
+		return nil
+	}
+
+	if !isTerminal() {
+		var missing []string
+
+		for _, v := range unset {
+			if entry.Variables[v].Default == "" {
+				missing = append(missing, fmt.Sprintf("%v (%v)", v, entry.Variables[v].Description))
+			}
+		}
+
+		if len(missing) > 0 {
+			return fmt.Errorf("%v requires the following variables, please supply them with --set: %v", name, strings.Join(missing, ", "))
+		}
+
+		return nil
+	}
+
+	for _, v := range unset {
+		variable := entry.Variables[v]
+
+		for {
+			if variable.Default != "" {
+				fmt.Printf("%v: %v [%v]: ", name, variable.Description, variable.Default)
+			} else {
+				fmt.Printf("%v: %v: ", name, variable.Description)
+			}
+
+			line, err := stdin.ReadString('\n')
+			value := strings.TrimSpace(line)
+			if value == "" {
+				value = variable.Default
+			}
+
+			if value != "" {
+				justinstall.SetVariable(v, value)
+				break
+			} else if err != nil {
+				return fmt.Errorf("no value given for the %v variable of %v", v, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// acceptLicenses is set by the --accept-licenses flag, in which case license agreements are accepted
+// without asking.
+var acceptLicenses = false
+
+// requireLicense makes sure that the license agreement of an entry, if it has one, has been
+// accepted, now or when the package was installed before. Acceptances are recorded in the state
+// database.
+func requireLicense(installState *state.State, name string, entry justinstall.RegistryEntry) error {
+	if entry.EULA == "" || installState.AcceptedLicense(name, entry.EULA) {
+		return nil
+	}
+
+	if entry.License != "" {
+		log.Printf("%v (%v) requires accepting its license agreement: %v", name, entry.License, entry.EULA)
+	} else {
+		log.Printf("%v requires accepting its license agreement: %v", name, entry.EULA)
+	}
+
+	if !acceptLicenses && !confirm(fmt.Sprintf("Do you accept the license agreement of %v?", name)) {
+		return fmt.Errorf("the license agreement of %v was not accepted, accept it with --accept-licenses", name)
+	}
+
+	installState.RecordLicenseAcceptance(name, entry.EULA)
+	if err := installState.Save(); err != nil {
+		log.Println("WARNING: could not save the state database:", err)
+	}
+
+	return nil
+}
+
+// confirm asks the user a yes or no question, the answer being no unless running in a terminal.
+func confirm(question string) bool {
+	if !isTerminal() {
+		return false
+	}
+
+	fmt.Printf("%v [y/N]: ", question)
+
+	line, _ := stdin.ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+
+	return answer == "y" || answer == "yes"
+}
+
+// replaceDeprecated warns when the given package is deprecated and, if offer is true and the user
+// agrees, returns the package superseding it instead.
+func replaceDeprecated(registry justinstall.Registry, name string, offer bool) string {
+	if !registry.Packages[name].Deprecated {
+		return name
+	}
+
+	replacement, ok := registry.Replacement(name)
+	if !ok {
+		log.Printf("WARNING: %v is deprecated", name)
+		return name
+	}
+
+	log.Printf("WARNING: %v is deprecated, it is superseded by %v", name, replacement)
+
+	if offer && confirm(fmt.Sprintf("Install %v instead of %v?", replacement, name)) {
+		return replacement
+	}
+
+	return name
+}
+
+// isTerminal returns whether standard input is an interactive terminal. It never is for unattended
+// runs.
+func isTerminal() bool {
+	if unattended {
+		return false
+	}
+
+	info, err := os.Stdin.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printDownloadStats logs a table of the downloads of this run, with the ones served from the
+// download cache, and their totals.
+func printDownloadStats() {
+	stats := fetch.Stats()
+	if len(stats) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSIZE\tTIME\tSPEED\tSOURCE")
+
+	var downloaded int64
+	var elapsed time.Duration
+	hits := 0
+
+	for _, stat := range stats {
+		name := stat.Resource
+		if u, err := url.Parse(stat.Resource); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			name = path.Base(u.Path)
+		}
+
+		if stat.CacheHit {
+			fmt.Fprintf(w, "%v\t-\t-\t-\tcache\n", name)
+			hits++
+			continue
+		}
+
+		fmt.Fprintf(w, "%v\t%.1f MB\t%v\t%.1f MB/s\tnetwork\n", name, float64(stat.Bytes)/1024/1024, journal.Round(stat.Duration), stat.Speed()/1024/1024)
+		downloaded += stat.Bytes
+		elapsed += stat.Duration
+	}
+	w.Flush()
+
+	log.Println("Downloads:")
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		log.Println("    " + line)
+	}
+
+	log.Printf("    %d downloaded (%.1f MB in %v), %d from the cache", len(stats)-hits, float64(downloaded)/1024/1024, journal.Round(elapsed), hits)
+}
+
+// packageSummary is the outcome of the installation of a package in the output of --json.
+type packageSummary struct {
+	Name    string
+	Outcome string // "installed" or "failed"
+	Timings journal.Timings
+}
+
+// printJSONSummary prints the outcome of the installation of the given packages, and the
+// statistics of the downloads of this run, as a JSON object on standard output.
+func printJSONSummary(packages []string, entries map[string]justinstall.RegistryEntry, failed map[string]bool) {
+	summary := struct {
+		Packages  []packageSummary
+		Downloads []fetch.Stat
+	}{Downloads: fetch.Stats()}
+
+	for _, pkg := range packages {
+		outcome := "installed"
+		if failed[pkg] {
+			outcome = "failed"
+		}
+
+		summary.Packages = append(summary.Packages, packageSummary{Name: pkg, Outcome: outcome, Timings: entries[pkg].Timings()})
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		log.Fatalln("Cannot encode the summary:", err)
 	}
 
-	log.Println("Loading custom registry at", registryPath)
-	return justinstall.LoadRegistry(registryPath)
+	fmt.Println(string(data))
 }
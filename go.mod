@@ -9,5 +9,7 @@ require (
 	github.com/mattn/go-runewidth v0.0.3 // indirect
 	github.com/ungerik/go-dry v0.0.0-20180411133923-654ae31114c8
 	github.com/urfave/cli v0.0.0-20180821064027-934abfb2f102
+	golang.org/x/crypto v0.1.0
+	golang.org/x/sys v0.1.0
 	gopkg.in/cheggaaa/pb.v1 v1.0.25
 )
@@ -2,16 +2,24 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	dry "github.com/ungerik/go-dry"
 )
 
+// registryKeyPattern finds the minisign public key of the official registry in registryKeyFile.
+var registryKeyPattern = regexp.MustCompile(`const registryPublicKey = "(.*)"`)
+
+const registryKeyFile = "pkg/justinstall/registrykey.go"
+
 func main() {
+	checkRegistryKey()
 	clean()
 	build()
 	buildMsi()
@@ -21,6 +29,19 @@ func main() {
 	}
 }
 
+// checkRegistryKey refuses to build just-install when it cannot verify the official registry.
+func checkRegistryKey() {
+	source, err := ioutil.ReadFile(registryKeyFile)
+	if err != nil {
+		log.Fatalln("cannot read the registry key:", err)
+	}
+
+	match := registryKeyPattern.FindSubmatch(source)
+	if match == nil || len(match[1]) == 0 {
+		log.Fatalf("%v has no registry key, just-install could not verify the official registry\n", registryKeyFile)
+	}
+}
+
 func clean() {
 	toRemove := []string{"just-install"}
 
@@ -45,12 +66,24 @@ func clean() {
 func build() {
 	log.Println("building version", getVersion())
 
-	cmd := exec.Command("go", "build",
-		"-ldflags", fmt.Sprintf("-s -w -X main.version=%s", getVersion()),
-		"./cmd/just-install")
-	cmd.Env = append(os.Environ(), "GOARCH=386")
-	if err := cmd.Run(); err != nil {
-		log.Fatalln("cannot build just-install:", err)
+	// The 32-bit build runs everywhere, the ARM64 one avoids emulation on Windows on ARM.
+	targets := []struct {
+		goarch string
+		output string
+	}{
+		{"386", "just-install.exe"},
+		{"arm64", "just-install-arm64.exe"},
+	}
+
+	for _, target := range targets {
+		cmd := exec.Command("go", "build",
+			"-ldflags", fmt.Sprintf("-s -w -X main.version=%s", getVersion()),
+			"-o", target.output,
+			"./cmd/just-install")
+		cmd.Env = append(os.Environ(), "GOOS=windows", "GOARCH="+target.goarch)
+		if err := cmd.Run(); err != nil {
+			log.Fatalf("cannot build just-install for %v: %v", target.goarch, err)
+		}
 	}
 }
 
@@ -85,7 +118,7 @@ func deploy() {
 		log.Fatalf("could not clone stable repository: %v", err)
 	}
 
-	for _, f := range []string{"just-install.exe", "just-install.msi"} {
+	for _, f := range []string{"just-install.exe", "just-install-arm64.exe", "just-install.msi"} {
 		if err := dry.FileCopy(f, fmt.Sprintf("stable\\%v", f)); err != nil {
 			log.Fatalf("cannot copy %v to git repo: %v", f, err)
 		}
@@ -0,0 +1,392 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the version of the layout of the cache, which lives in a directory of its own so that
+// future layouts don't have to deal with the files of older ones.
+const Version = 1
+
+const indexName = "index.json"
+
+// journalName is the name of the journal of the downloads in progress pkg/fetch keeps next to them.
+const journalName = "downloads.journal"
+
+// sideFileSuffixes are the suffixes of the files kept next to a download, like the ones pkg/fetch
+// uses to resume and revalidate it. They belong to the entry of the download.
+var sideFileSuffixes = []string{".download", ".download.validator", ".lock", ".validators"}
+
+// Entry is a file in the cache.
+type Entry struct {
+	Name     string // File name in the cache directory
+	URL      string `json:",omitempty"`
+	Size     int64  `json:"-"` // Including the side files
+	LastUsed time.Time
+	SHA256   string    `json:",omitempty"` // Of the contents when last used
+	Modified time.Time // Modification time of the file when it was hashed
+}
+
+// Cache is a download cache rooted in a directory.
+type Cache struct {
+	dir string
+}
+
+// New returns the cache kept in the versioned subdirectory of the given directory. Nothing is
+// created until the cache is used.
+func New(root string) *Cache {
+	return &Cache{dir: filepath.Join(root, fmt.Sprintf("v%d", Version))}
+}
+
+// Dir returns the directory the files of the cache are stored in.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Path returns the path of the named file in the cache.
+func (c *Cache) Path(name string) string {
+	return filepath.Join(c.dir, name)
+}
+
+// Touch records that the named file of the cache, downloaded from the given URL, was just used.
+// The file is hashed again only if it was modified since it was last hashed, so that it can be
+// found by contents with Lookup and checked with Verify.
+func (c *Cache) Touch(name string, url string) error {
+	index, err := c.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(c.Path(name))
+	if os.IsNotExist(err) {
+		delete(index, name)
+		return c.saveIndex(index)
+	} else if err != nil {
+		return err
+	}
+
+	entry := Entry{Name: name, URL: url, LastUsed: time.Now()}
+	if previous, ok := index[name]; ok && previous.SHA256 != "" && previous.Modified.Equal(info.ModTime()) {
+		entry.SHA256, entry.Modified = previous.SHA256, previous.Modified
+	} else {
+		if entry.SHA256, err = hashFile(c.Path(name)); err != nil {
+			return err
+		}
+		entry.Modified = info.ModTime()
+	}
+
+	index[name] = entry
+
+	return c.saveIndex(index)
+}
+
+// Lookup returns the name of a file of the cache with the given SHA-256 hash, if there is one that
+// wasn't modified since it was hashed. Identical files downloaded from different URLs can then be
+// reused instead of being downloaded again.
+func (c *Cache) Lookup(sha256 string) (string, bool) {
+	index, err := c.loadIndex()
+	if err != nil || sha256 == "" {
+		return "", false
+	}
+
+	var names []string
+	for name := range index {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entry := index[name]
+		if !strings.EqualFold(entry.SHA256, sha256) {
+			continue
+		}
+
+		if info, err := os.Stat(c.Path(name)); err == nil && info.ModTime().Equal(entry.Modified) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// Clone makes the named file of the cache available at the given path too, as a hard link where
+// the file system supports it and as a copy otherwise.
+func (c *Cache) Clone(name string, path string) error {
+	os.Remove(path)
+	if err := os.Link(c.Path(name), path); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(c.Path(name))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tempPath := path + ".tmp"
+	out, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tempPath)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
+
+// Verify hashes the files of the cache again and returns the entries whose contents still match
+// the hash recorded when they were last used, and the corrupted ones that don't. Files that were
+// never hashed are skipped.
+func (c *Cache) Verify() (verified []Entry, corrupted []Entry, err error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.SHA256 == "" {
+			continue
+		}
+
+		actual, err := hashFile(c.Path(entry.Name))
+		if err != nil {
+			return verified, corrupted, err
+		}
+
+		if strings.EqualFold(actual, entry.SHA256) {
+			verified = append(verified, entry)
+		} else {
+			corrupted = append(corrupted, entry)
+		}
+	}
+
+	return verified, corrupted, nil
+}
+
+// Entries returns the entries of the cache, least recently used first. Files missing from the
+// index, like the leftovers of interrupted downloads, are entries last used when they were
+// modified.
+func (c *Cache) Entries() ([]Entry, error) {
+	index, err := c.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*Entry)
+	sizes := make(map[string]int64)
+
+	for _, f := range files {
+		if f.IsDir() || f.Name() == indexName || strings.HasPrefix(f.Name(), journalName) || strings.HasSuffix(f.Name(), ".tmp") {
+			continue
+		}
+
+		sizes[f.Name()] = f.Size()
+
+		entry, ok := index[f.Name()]
+		if !ok {
+			entry = Entry{Name: f.Name(), LastUsed: f.ModTime()}
+		}
+		entries[f.Name()] = &entry
+	}
+
+	// Side files count towards the size of their download, if it is there
+	for name, size := range sizes {
+		if owner := c.owner(name, entries); owner != name {
+			entries[owner].Size += size
+			delete(entries, name)
+		} else {
+			entries[name].Size += size
+		}
+	}
+
+	var ret []Entry
+	for _, entry := range entries {
+		ret = append(ret, *entry)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].LastUsed.Before(ret[j].LastUsed) })
+
+	return ret, nil
+}
+
+// owner returns the name of the entry the named file belongs to, itself unless it is the side file
+// of an existing entry.
+func (c *Cache) owner(name string, entries map[string]*Entry) string {
+	for _, suffix := range sideFileSuffixes {
+		if base := strings.TrimSuffix(name, suffix); base != name {
+			if _, ok := entries[base]; ok {
+				return base
+			}
+		}
+	}
+
+	return name
+}
+
+// Size returns the total size of the cache, in bytes.
+func (c *Cache) Size() (int64, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	var ret int64
+	for _, entry := range entries {
+		ret += entry.Size
+	}
+
+	return ret, nil
+}
+
+// Evict removes the least recently used entries until the cache takes at most maxSize bytes, and
+// returns the removed entries. The named entries, like the one just used, are never removed.
+func (c *Cache) Evict(maxSize int64, keep ...string) ([]Entry, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	kept := make(map[string]bool)
+	for _, name := range keep {
+		kept[name] = true
+	}
+
+	var ret []Entry
+	for _, entry := range entries {
+		if total <= maxSize {
+			break
+		} else if kept[entry.Name] {
+			continue
+		}
+
+		if err := c.Remove(entry.Name); err != nil {
+			return ret, err
+		}
+
+		total -= entry.Size
+		ret = append(ret, entry)
+	}
+
+	return ret, nil
+}
+
+// Remove removes the named entry from the cache, along with its side files.
+func (c *Cache) Remove(name string) error {
+	if err := os.Remove(c.Path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, suffix := range sideFileSuffixes {
+		os.Remove(c.Path(name + suffix))
+	}
+
+	index, err := c.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := index[name]; !ok {
+		return nil
+	}
+
+	delete(index, name)
+
+	return c.saveIndex(index)
+}
+
+// hashFile returns the hex-encoded SHA-256 hash of the file at the given path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func (c *Cache) loadIndex() (map[string]Entry, error) {
+	ret := make(map[string]Entry)
+
+	data, err := ioutil.ReadFile(c.Path(indexName))
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A corrupted index only loses the last use times, files are still found on disk
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return make(map[string]Entry), nil
+	}
+
+	return ret, nil
+}
+
+func (c *Cache) saveIndex(index map[string]Entry) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	tempPath := c.Path(indexName + ".tmp")
+	if err := ioutil.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, c.Path(indexName))
+}
@@ -18,14 +18,40 @@ package cmd
 import (
 	"errors"
 	"log"
+	"os"
 	"os/exec"
 	"strings"
 	"syscall"
 )
 
+// redacted holds the strings that must not appear in logged command lines.
+var redacted []string
+
+// Redact hides the given string (e.g. a license key) from the command lines logged from now on.
+func Redact(s string) {
+	if s != "" {
+		redacted = append(redacted, s)
+	}
+}
+
+// Mask returns s with the strings given to Redact hidden.
+func Mask(s string) string {
+	for _, r := range redacted {
+		s = strings.Replace(s, r, "********", -1)
+	}
+
+	return s
+}
+
 // Run runs a command, printing the command line to standard output. Additional output is printed in
 // case we run msiexec and it returns with code 3010 (short for "reboot needed").
 func Run(args ...string) error {
+	return RunWithEnv(nil, args...)
+}
+
+// RunWithEnv is like Run but adds the given "KEY=VALUE" pairs to the environment of the spawned
+// process. Later entries take precedence over earlier ones and over the current environment.
+func RunWithEnv(env []string, args ...string) error {
 	if len(args) < 1 {
 		return errors.New("empty command line")
 	}
@@ -37,7 +63,11 @@ func Run(args ...string) error {
 		cmd = exec.Command(args[0], args[1:]...)
 	}
 
-	log.Println("Running", strings.Join(args, " "))
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	log.Println("Running", Mask(strings.Join(args, " ")))
 
 	err := cmd.Start()
 	if err != nil {
@@ -66,3 +96,34 @@ func Run(args ...string) error {
 
 	return nil
 }
+
+// Output is like RunWithEnv but captures what the command prints, on both standard output and
+// standard error, and returns it.
+func Output(env []string, args ...string) (string, error) {
+	if len(args) < 1 {
+		return "", errors.New("empty command line")
+	}
+
+	return output(exec.Command(args[0], args[1:]...), env, strings.Join(args, " "))
+}
+
+// OutputCmd is like Output but runs the given command line with cmd.exe. The command line is
+// passed to cmd.exe as is, since it doesn't understand the escaping applied to arguments.
+func OutputCmd(env []string, commandLine string) (string, error) {
+	cmd := exec.Command("cmd.exe", "/s", "/c", commandLine)
+	setCommandLine(cmd, "cmd.exe /s /c \""+commandLine+"\"")
+
+	return output(cmd, env, commandLine)
+}
+
+func output(cmd *exec.Cmd, env []string, commandLine string) (string, error) {
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	log.Println("Running", Mask(commandLine))
+
+	out, err := cmd.CombinedOutput()
+
+	return string(out), err
+}
@@ -0,0 +1,27 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setCommandLine makes cmd run with the given command line, instead of one built by escaping its
+// arguments.
+func setCommandLine(cmd *exec.Cmd, commandLine string) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: commandLine}
+}
@@ -0,0 +1,99 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Config contains the settings read from the configuration file. All settings are optional.
+type Config struct {
+	AzureStorageAccount  string                       `json:"azureStorageAccount,omitempty"`  // Storage account of azblob:// URLs
+	AzureStorageSASToken string                       `json:"azureStorageSasToken,omitempty"` // Shared access signature authorizing azblob:// requests, ${NAME} expands to environment variables
+	BITSMinSize          int                          `json:"bitsMinSize,omitempty"`          // Size in MB from which downloads go through BITS, 0 disables it
+	CABundle             string                       `json:"caBundle,omitempty"`             // PEM file with certificate authorities trusted in addition to the system ones
+	CacheDir             string                       `json:"cacheDir,omitempty"`             // Directory of the download cache
+	CacheMaxSize         int                          `json:"cacheMaxSize,omitempty"`         // Size in MB the download cache is kept under, 4096 if unset, negative for no limit
+	DownloadConnections  int                          `json:"downloadConnections,omitempty"`  // Concurrent connections per HTTP download, 1 if unset
+	DownloadRetries      int                          `json:"downloadRetries,omitempty"`      // Retries after transient download failures, 3 if unset, negative disables them
+	Downloader           string                       `json:"downloader,omitempty"`           // "http" (the default) or "bits" to download through persistent BITS jobs
+	GitHubToken          string                       `json:"githubToken,omitempty"`          // Token used to authenticate against the GitHub API
+	Headers              map[string]map[string]string `json:"headers,omitempty"`              // Headers sent to specific hosts, ${NAME} expands to environment variables
+	IdleConnTimeout      int                          `json:"idleConnTimeout,omitempty"`      // Seconds idle connections are kept open for reuse, 90 if unset, negative disables keep-alives
+	LimitRate            string                       `json:"limitRate,omitempty"`            // Maximum download rate in bytes per second, with an optional k, M or G suffix
+	MaxConnsPerHost      int                          `json:"maxConnsPerHost,omitempty"`      // Concurrent connections to a single host, unlimited if unset
+	ParallelDownloads    int                          `json:"parallelDownloads,omitempty"`    // Installers downloaded at once when installing several packages, 4 if unset, 1 disables it
+	PeerCache            bool                         `json:"peerCache,omitempty"`            // Share downloaded installers with other instances on the LAN
+	PeerPort             int                          `json:"peerPort,omitempty"`             // TCP port the download cache is shared on
+	Progress             string                       `json:"progress,omitempty"`             // "auto" (the default), "bar", "lines" or "none"
+	Proxy                string                       `json:"proxy,omitempty"`                // Proxy URL, taken from the environment or the system settings if unset
+	ProxyAuth            string                       `json:"proxyAuth,omitempty"`            // Proxy authentication, "basic" (the default) or "ntlm"
+	ProxyBypass          string                       `json:"proxyBypass,omitempty"`          // Comma-separated hosts reached without the configured proxy
+	ProxyPassword        string                       `json:"proxyPassword,omitempty"`        // Password of the proxy user
+	ProxyUser            string                       `json:"proxyUser,omitempty"`            // Proxy user, "DOMAIN\user" for NTLM
+	Registries           []string                     `json:"registries,omitempty"`           // Registries merged in order, later ones overriding earlier entries, "default" is the official one
+	RegistryMaxAge       int                          `json:"registryMaxAge,omitempty"`       // Hours the downloaded registry is used before checking for a new one, 24 if unset, negative never checks
+	RestorePoint         bool                         `json:"restorePoint,omitempty"`         // Create a System Restore point before system-level installs
+	S3AccessKeyID        string                       `json:"s3AccessKeyId,omitempty"`        // Access key of s3:// requests
+	S3Endpoint           string                       `json:"s3Endpoint,omitempty"`           // URL of an S3-compatible service, instead of Amazon S3
+	S3Profile            string                       `json:"s3Profile,omitempty"`            // Profile of the shared AWS credentials file
+	S3Region             string                       `json:"s3Region,omitempty"`             // Region of the buckets of s3:// URLs
+	S3SecretAccessKey    string                       `json:"s3SecretAccessKey,omitempty"`    // Secret of s3AccessKeyId, ${NAME} expands to environment variables
+	Tokens               map[string]string            `json:"tokens,omitempty"`               // API tokens for specific GitHub, GitLab or Gitea hosts
+	UserAgent            string                       `json:"userAgent,omitempty"`            // User-Agent header of HTTP requests
+	Variables            map[string]string            `json:"variables,omitempty"`            // Variables of the templates of registry entries, overridden by --set
+}
+
+// Load reads the configuration file at the given path. A missing file is not an error, an empty
+// configuration is returned instead.
+func Load(path string) (*Config, error) {
+	ret := &Config{}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Save writes the configuration to the given path.
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
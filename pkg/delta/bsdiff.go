@@ -0,0 +1,117 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package delta
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bsdiffMagic starts the patches of the classic bsdiff format.
+const bsdiffMagic = "BSDIFF40"
+
+// maxGrowth is how many times larger than the old file the new one can be. Patches come from the
+// network, so the size they announce is not trusted to allocate memory with.
+const maxGrowth = 8
+
+// Patch applies a patch made by bsdiff to the old contents of a file and returns the new ones.
+func Patch(old []byte, patch []byte) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != bsdiffMagic {
+		return nil, errors.New("not a bsdiff patch")
+	}
+
+	ctrlLen := offtin(patch[8:])
+	diffLen := offtin(patch[16:])
+	newSize := offtin(patch[24:])
+
+	// Checked one at a time, since their sum can overflow
+	blocks := int64(len(patch)) - 32
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || ctrlLen > blocks || diffLen > blocks-ctrlLen {
+		return nil, errors.New("corrupt bsdiff patch header")
+	}
+
+	if newSize > int64(len(old))*maxGrowth {
+		return nil, fmt.Errorf("bsdiff patch makes a file of %v bytes out of %v bytes, refusing to apply it", newSize, len(old))
+	}
+
+	ctrl := bzip2.NewReader(bytes.NewReader(patch[32 : 32+ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen : 32+ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen+diffLen:]))
+
+	ret := make([]byte, newSize)
+	var oldPos, newPos int64
+	buf := make([]byte, 8)
+
+	for newPos < newSize {
+		// Each control triple copies x bytes of the old file adjusted with the diff block, then y
+		// bytes of the extra block, then moves z bytes forward (or back) in the old file
+		var triple [3]int64
+		for i := range triple {
+			if _, err := io.ReadFull(ctrl, buf); err != nil {
+				return nil, fmt.Errorf("corrupt bsdiff control block: %v", err)
+			}
+			triple[i] = offtin(buf)
+		}
+
+		x, y, z := triple[0], triple[1], triple[2]
+		if x < 0 || y < 0 || x > newSize-newPos {
+			return nil, errors.New("corrupt bsdiff patch")
+		}
+
+		if _, err := io.ReadFull(diff, ret[newPos:newPos+x]); err != nil {
+			return nil, fmt.Errorf("corrupt bsdiff diff block: %v", err)
+		}
+
+		for i := int64(0); i < x; i++ {
+			if oldPos+i >= 0 && oldPos+i < int64(len(old)) {
+				ret[newPos+i] += old[oldPos+i]
+			}
+		}
+
+		newPos += x
+		oldPos += x
+
+		if y > newSize-newPos {
+			return nil, errors.New("corrupt bsdiff patch")
+		}
+
+		if _, err := io.ReadFull(extra, ret[newPos:newPos+y]); err != nil {
+			return nil, fmt.Errorf("corrupt bsdiff extra block: %v", err)
+		}
+
+		newPos += y
+		oldPos += z
+	}
+
+	return ret, nil
+}
+
+// offtin decodes the sign and magnitude, little endian, 64-bit integers of bsdiff patches.
+func offtin(buf []byte) int64 {
+	y := int64(buf[7] & 0x7f)
+	for i := 6; i >= 0; i-- {
+		y = y<<8 | int64(buf[i])
+	}
+
+	if buf[7]&0x80 != 0 {
+		return -y
+	}
+
+	return y
+}
@@ -0,0 +1,284 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package delta
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/md4"
+)
+
+// Control is a zsync control file, describing a file as a list of blocks with a weak rolling
+// checksum and a strong one each, so that the blocks already present in older versions of the
+// file can be found without downloading it.
+type Control struct {
+	Filename  string
+	URL       string // Of the file, relative to the control file, if given
+	Length    int64
+	BlockSize int
+	SHA1      string // Of the whole file, in hex
+
+	rsumBytes     int
+	checksumBytes int
+	blocks        []blockSum
+}
+
+// blockSum holds the checksums of a single block, truncated to the lengths of the control file.
+type blockSum struct {
+	rsum     uint32
+	checksum []byte
+}
+
+// RangeFunc returns the bytes of the file between the given offsets, end included.
+type RangeFunc func(start int64, end int64) ([]byte, error)
+
+// ParseControl parses a zsync control file.
+func ParseControl(data []byte) (*Control, error) {
+	ret := &Control{rsumBytes: 4, checksumBytes: md4.Size}
+
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return nil, errors.New("truncated zsync control file")
+		}
+
+		line := strings.TrimRight(string(data[:i]), "\r")
+		data = data[i+1:]
+
+		if line == "" {
+			break
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+
+		var err error
+		switch value := strings.TrimSpace(line[colon+1:]); line[:colon] {
+		case "Filename":
+			ret.Filename = value
+		case "URL":
+			if ret.URL == "" {
+				ret.URL = value
+			}
+		case "Length":
+			ret.Length, err = strconv.ParseInt(value, 10, 64)
+		case "Blocksize":
+			ret.BlockSize, err = strconv.Atoi(value)
+		case "Hash-Lengths":
+			var seqMatches int
+			_, err = fmt.Sscanf(value, "%d,%d,%d", &seqMatches, &ret.rsumBytes, &ret.checksumBytes)
+		case "SHA-1":
+			ret.SHA1 = strings.ToLower(value)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid zsync header %q: %v", line, err)
+		}
+	}
+
+	if ret.BlockSize <= 0 || ret.Length < 0 || ret.rsumBytes < 1 || ret.rsumBytes > 4 || ret.checksumBytes < 1 || ret.checksumBytes > md4.Size {
+		return nil, errors.New("unsupported zsync control file")
+	} else if ret.SHA1 == "" {
+		return nil, errors.New("the zsync control file has no SHA-1")
+	}
+
+	count := (ret.Length + int64(ret.BlockSize) - 1) / int64(ret.BlockSize)
+	size := int64(ret.rsumBytes + ret.checksumBytes)
+	if int64(len(data)) < count*size {
+		return nil, errors.New("truncated zsync control file")
+	}
+
+	for i := int64(0); i < count; i++ {
+		entry := data[i*size : (i+1)*size]
+
+		var rsum uint32
+		for _, b := range entry[:ret.rsumBytes] {
+			rsum = rsum<<8 | uint32(b)
+		}
+
+		ret.blocks = append(ret.blocks, blockSum{rsum: rsum, checksum: entry[ret.rsumBytes:]})
+	}
+
+	return ret, nil
+}
+
+// Reconstruct writes the file described by the control file to the given path, copying the blocks
+// found in the seed files, like older versions of the file, and fetching the others. It returns how
+// many bytes were fetched. The result is checked against the SHA-1 of the control file.
+func (c *Control) Reconstruct(path string, seeds []string, fetchRange RangeFunc) (int64, error) {
+	out, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	if err := out.Truncate(c.Length); err != nil {
+		return 0, err
+	}
+
+	index := make(map[uint32][]int)
+	for i, block := range c.blocks {
+		index[block.rsum] = append(index[block.rsum], i)
+	}
+
+	known := make([]bool, len(c.blocks))
+	for _, seed := range seeds {
+		data, err := ioutil.ReadFile(seed)
+		if err != nil {
+			continue
+		}
+
+		if err := c.scan(data, index, known, out); err != nil {
+			return 0, err
+		}
+	}
+
+	// Fetch the runs of missing blocks with one request each
+	var fetched int64
+	for i := 0; i < len(c.blocks); {
+		if known[i] {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(c.blocks) && !known[j] {
+			j++
+		}
+
+		start := int64(i) * int64(c.BlockSize)
+		end := int64(j)*int64(c.BlockSize) - 1
+		if end >= c.Length {
+			end = c.Length - 1
+		}
+
+		data, err := fetchRange(start, end)
+		if err != nil {
+			return fetched, err
+		} else if int64(len(data)) != end-start+1 {
+			return fetched, fmt.Errorf("wanted %d bytes at offset %d, got %d", end-start+1, start, len(data))
+		}
+
+		if _, err := out.WriteAt(data, start); err != nil {
+			return fetched, err
+		}
+
+		fetched += int64(len(data))
+		i = j
+	}
+
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return fetched, err
+	}
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, out); err != nil {
+		return fetched, err
+	}
+
+	if actual := fmt.Sprintf("%x", hash.Sum(nil)); actual != c.SHA1 {
+		return fetched, fmt.Errorf("the rebuilt file has SHA-1 %v instead of %v", actual, c.SHA1)
+	}
+
+	return fetched, out.Close()
+}
+
+// scan slides a window of the block size over the data of a seed file, one byte at a time, and
+// copies to the output the blocks of the control file it finds. The data is padded with zeros like
+// the last block of the file is.
+func (c *Control) scan(data []byte, index map[uint32][]int, known []bool, out *os.File) error {
+	bs := c.BlockSize
+	data = append(data, make([]byte, bs)...)
+
+	mask := uint32(uint64(1)<<(8*uint(c.rsumBytes)) - 1)
+
+	a, b := rsum(data[:bs])
+	for pos := 0; pos+bs <= len(data); {
+		matched := false
+
+		if candidates, ok := index[(a<<16|b)&mask]; ok {
+			var sum []byte
+			for _, i := range candidates {
+				if known[i] {
+					continue
+				}
+
+				if sum == nil {
+					h := md4.New()
+					h.Write(data[pos : pos+bs])
+					sum = h.Sum(nil)
+				}
+
+				if !bytes.Equal(sum[:c.checksumBytes], c.blocks[i].checksum) {
+					continue
+				}
+
+				length := int64(bs)
+				if offset := int64(i) * int64(bs); offset+length > c.Length {
+					length = c.Length - offset
+				}
+
+				if _, err := out.WriteAt(data[pos:pos+int(length)], int64(i)*int64(bs)); err != nil {
+					return err
+				}
+
+				known[i] = true
+				matched = true
+			}
+		}
+
+		// Blocks don't overlap, so the next one can only start after the matched one
+		if matched {
+			pos += bs
+			if pos+bs <= len(data) {
+				a, b = rsum(data[pos : pos+bs])
+			}
+			continue
+		}
+
+		if pos+bs == len(data) {
+			break
+		}
+
+		old, next := uint32(data[pos]), uint32(data[pos+bs])
+		a = (a - old + next) & 0xffff
+		b = (b - uint32(bs)*old + a) & 0xffff
+		pos++
+	}
+
+	return nil
+}
+
+// rsum returns the two halves of the weak rolling checksum of a block.
+func rsum(block []byte) (uint32, uint32) {
+	var a, b uint32
+	for i, c := range block {
+		a += uint32(c)
+		b += uint32(len(block)-i) * uint32(c)
+	}
+
+	return a & 0xffff, b & 0xffff
+}
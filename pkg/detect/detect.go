@@ -0,0 +1,95 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package detect
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var errUnsupported = errors.New("version detection is only supported on Windows")
+
+// Rule describes how to find the installed version of a program. Only one kind of rule needs to be
+// filled in, when more than one is they are tried in the order below until one finds a version.
+type Rule struct {
+	File      string   // Path to an executable whose version resource is read
+	Registry  string   // Registry key (e.g. HKLM\SOFTWARE\Vendor\Product) ...
+	Value     string   // ... and the name of the value within it containing the version
+	Command   []string // Command line to run ...
+	Regex     string   // ... and regular expression whose first group matches the version in its output
+	Uninstall string   // Prefix of the display name in the Uninstall registry hive
+}
+
+// Detect returns the installed version according to the rule. The second return value is false
+// when the program doesn't appear to be installed.
+func (r *Rule) Detect() (string, bool, error) {
+	if r.File != "" {
+		if version, ok, err := FileVersion(r.File); err != nil || ok {
+			return version, ok, err
+		}
+	}
+
+	if r.Registry != "" {
+		if version, ok, err := RegistryValue(r.Registry, r.Value); err != nil || ok {
+			return version, ok, err
+		}
+	}
+
+	if len(r.Command) > 0 {
+		if version, ok, err := commandVersion(r.Command, r.Regex); err != nil || ok {
+			return version, ok, err
+		}
+	}
+
+	if r.Uninstall != "" {
+		return UninstallVersion(r.Uninstall)
+	}
+
+	return "", false, nil
+}
+
+// commandVersion runs the given command line and extracts the version from its output with the
+// given regular expression. A command that cannot be started is taken as a sign that the program
+// is not installed.
+func commandVersion(args []string, expression string) (string, bool, error) {
+	if expression == "" {
+		return "", false, errors.New("command detection rule without a regular expression")
+	}
+
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return "", false, err
+	}
+
+	output, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if _, ok := err.(*exec.Error); ok {
+		return "", false, nil
+	}
+
+	match := re.FindSubmatch(output)
+	if match == nil {
+		return "", false, nil
+	}
+
+	if len(match) < 2 {
+		return "", false, fmt.Errorf("regular expression %q has no capturing group", expression)
+	}
+
+	return strings.TrimSpace(string(match[1])), true, nil
+}
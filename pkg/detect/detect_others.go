@@ -0,0 +1,42 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !windows
+// +build !windows
+
+package detect
+
+// FileVersion returns the product version stored in the version resource of the given executable.
+func FileVersion(path string) (string, bool, error) {
+	return "", false, errUnsupported
+}
+
+// RegistryValue returns the string stored in the given registry value.
+func RegistryValue(keyPath string, valueName string) (string, bool, error) {
+	return "", false, errUnsupported
+}
+
+// UninstallVersion looks for a program whose display name starts with the given string in the
+// Uninstall registry hive (both machine-wide and per-user) and returns its display version.
+func UninstallVersion(displayName string) (string, bool, error) {
+	return "", false, errUnsupported
+}
+
+// UninstallString looks for a program like UninstallVersion does and returns the command line that
+// uninstalls it. When quiet is true, only the command line that uninstalls it silently is returned,
+// which few programs provide.
+func UninstallString(displayName string, quiet bool) (string, bool, error) {
+	return "", false, errUnsupported
+}
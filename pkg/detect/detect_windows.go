@@ -0,0 +1,198 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package detect
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	versionDLL = syscall.NewLazyDLL("version.dll")
+
+	procGetFileVersionInfoSizeW = versionDLL.NewProc("GetFileVersionInfoSizeW")
+	procGetFileVersionInfoW     = versionDLL.NewProc("GetFileVersionInfoW")
+	procVerQueryValueW          = versionDLL.NewProc("VerQueryValueW")
+)
+
+// vsFixedFileInfo mirrors the VS_FIXEDFILEINFO structure.
+type vsFixedFileInfo struct {
+	Signature        uint32
+	StrucVersion     uint32
+	FileVersionMS    uint32
+	FileVersionLS    uint32
+	ProductVersionMS uint32
+	ProductVersionLS uint32
+	FileFlagsMask    uint32
+	FileFlags        uint32
+	FileOS           uint32
+	FileType         uint32
+	FileSubtype      uint32
+	FileDateMS       uint32
+	FileDateLS       uint32
+}
+
+// FileVersion returns the product version stored in the version resource of the given executable.
+func FileVersion(path string) (string, bool, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	size, _, err := procGetFileVersionInfoSizeW.Call(uintptr(unsafe.Pointer(pathPtr)), 0)
+	if size == 0 {
+		return "", false, fmt.Errorf("%v: no version information (%v)", path, err)
+	}
+
+	data := make([]byte, size)
+	ret, _, err := procGetFileVersionInfoW.Call(uintptr(unsafe.Pointer(pathPtr)), 0, size, uintptr(unsafe.Pointer(&data[0])))
+	if ret == 0 {
+		return "", false, fmt.Errorf("%v: cannot read version information (%v)", path, err)
+	}
+
+	root, _ := syscall.UTF16PtrFromString(`\`)
+	var info *vsFixedFileInfo
+	var infoLen uint32
+	ret, _, _ = procVerQueryValueW.Call(uintptr(unsafe.Pointer(&data[0])), uintptr(unsafe.Pointer(root)), uintptr(unsafe.Pointer(&info)), uintptr(unsafe.Pointer(&infoLen)))
+	if ret == 0 || infoLen == 0 {
+		return "", false, fmt.Errorf("%v: no fixed version information", path)
+	}
+
+	return fmt.Sprintf("%d.%d.%d.%d",
+		info.ProductVersionMS>>16, info.ProductVersionMS&0xFFFF,
+		info.ProductVersionLS>>16, info.ProductVersionLS&0xFFFF), true, nil
+}
+
+var registryRoots = map[string]registry.Key{
+	"HKCR":               registry.CLASSES_ROOT,
+	"HKCU":               registry.CURRENT_USER,
+	"HKLM":               registry.LOCAL_MACHINE,
+	"HKU":                registry.USERS,
+	"HKEY_CLASSES_ROOT":  registry.CLASSES_ROOT,
+	"HKEY_CURRENT_USER":  registry.CURRENT_USER,
+	"HKEY_LOCAL_MACHINE": registry.LOCAL_MACHINE,
+	"HKEY_USERS":         registry.USERS,
+}
+
+// registryViews are the registry views we look into: we are a 32-bit program, but most software is
+// 64-bit nowadays.
+var registryViews = []uint32{registry.WOW64_64KEY, registry.WOW64_32KEY}
+
+// RegistryValue returns the string stored in the given registry value.
+func RegistryValue(keyPath string, valueName string) (string, bool, error) {
+	split := strings.SplitN(keyPath, `\`, 2)
+
+	root, ok := registryRoots[strings.ToUpper(split[0])]
+	if !ok || len(split) < 2 {
+		return "", false, fmt.Errorf("invalid registry key: %v", keyPath)
+	}
+
+	for _, view := range registryViews {
+		key, err := registry.OpenKey(root, split[1], registry.QUERY_VALUE|view)
+		if err != nil {
+			continue
+		}
+
+		value, _, err := key.GetStringValue(valueName)
+		key.Close()
+		if err == nil {
+			return strings.TrimSpace(value), true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+const uninstallKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`
+
+// UninstallVersion looks for a program whose display name starts with the given string in the
+// Uninstall registry hive (both machine-wide and per-user) and returns its display version.
+func UninstallVersion(displayName string) (string, bool, error) {
+	version, ok := searchUninstallHive(displayName, "DisplayVersion")
+
+	return version, ok, nil
+}
+
+// UninstallString looks for a program like UninstallVersion does and returns the command line that
+// uninstalls it. When quiet is true, only the command line that uninstalls it silently is returned,
+// which few programs provide.
+func UninstallString(displayName string, quiet bool) (string, bool, error) {
+	valueName := "UninstallString"
+	if quiet {
+		valueName = "QuietUninstallString"
+	}
+
+	command, ok := searchUninstallHive(displayName, valueName)
+
+	return command, ok, nil
+}
+
+// searchUninstallHive returns the given value of the first program whose display name starts with
+// the given string, looking for it in both the machine-wide and per-user hives.
+func searchUninstallHive(displayName string, valueName string) (string, bool) {
+	displayName = strings.ToLower(displayName)
+
+	for _, root := range []registry.Key{registry.LOCAL_MACHINE, registry.CURRENT_USER} {
+		for _, view := range registryViews {
+			if value, ok := searchUninstallKey(root, view, displayName, valueName); ok {
+				return value, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func searchUninstallKey(root registry.Key, view uint32, displayName string, valueName string) (string, bool) {
+	key, err := registry.OpenKey(root, uninstallKey, registry.ENUMERATE_SUB_KEYS|view)
+	if err != nil {
+		return "", false
+	}
+	defer key.Close()
+
+	names, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return "", false
+	}
+
+	for _, name := range names {
+		subKey, err := registry.OpenKey(key, name, registry.QUERY_VALUE|view)
+		if err != nil {
+			continue
+		}
+
+		entryName, _, err := subKey.GetStringValue("DisplayName")
+		if err == nil && strings.HasPrefix(strings.ToLower(entryName), displayName) {
+			value, _, err := subKey.GetStringValue(valueName)
+			subKey.Close()
+
+			return strings.TrimSpace(value), err == nil
+		}
+
+		subKey.Close()
+	}
+
+	return "", false
+}
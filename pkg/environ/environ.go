@@ -0,0 +1,164 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package environ
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Variable is an environment variable.
+type Variable struct {
+	Name  string
+	Value string
+}
+
+// Persistent returns the environment variables that new processes get from the registry: the
+// machine-wide ones overridden by those of the current user, except for PATH where the user entries
+// are appended to the machine ones. Values are expanded and sorted by name.
+func Persistent() ([]Variable, error) {
+	machine, user, err := readScopes()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]Variable)
+	for _, v := range machine {
+		merged[strings.ToUpper(v.Name)] = v
+	}
+
+	for _, v := range user {
+		key := strings.ToUpper(v.Name)
+
+		if existing, ok := merged[key]; ok && key == "PATH" {
+			v = Variable{existing.Name, strings.TrimSuffix(existing.Value, ";") + ";" + v.Value}
+		}
+
+		merged[key] = v
+	}
+
+	var ret []Variable
+	for _, v := range merged {
+		ret = append(ret, v)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return strings.ToUpper(ret[i].Name) < strings.ToUpper(ret[j].Name) })
+
+	return ret, nil
+}
+
+// Changed returns the persistent variables whose value differs from the one in the environment of
+// the current process.
+func Changed() ([]Variable, error) {
+	vars, err := Persistent()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Variable
+	for _, v := range vars {
+		if os.Getenv(v.Name) != v.Value {
+			ret = append(ret, v)
+		}
+	}
+
+	return ret, nil
+}
+
+// AddToPath adds the given directory to the persistent PATH of the machine or of the current user,
+// unless it is already there.
+func AddToPath(dir string, machine bool) error {
+	return updatePath(machine, func(entries []string) []string {
+		for _, entry := range entries {
+			if samePath(entry, dir) {
+				return entries
+			}
+		}
+
+		return append(entries, dir)
+	})
+}
+
+// RemoveFromPath removes the given directory from the persistent PATH of the machine or of the
+// current user.
+func RemoveFromPath(dir string, machine bool) error {
+	return updatePath(machine, func(entries []string) []string {
+		var ret []string
+		for _, entry := range entries {
+			if !samePath(entry, dir) {
+				ret = append(ret, entry)
+			}
+		}
+
+		return ret
+	})
+}
+
+// GetVariable returns the given persistent environment variable of the machine or of the current
+// user, with its references to other variables unexpanded, and whether it is set.
+func GetVariable(name string, machine bool) (string, bool, error) {
+	return getVariable(name, machine)
+}
+
+// InPath returns whether the given directory is in the persistent PATH of the machine or of the
+// current user.
+func InPath(dir string, machine bool) (bool, error) {
+	value, _, err := getVariable("Path", machine)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range strings.Split(value, ";") {
+		if samePath(entry, dir) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SetVariable sets the given persistent environment variable of the machine or of the current
+// user. References to other variables, like %USERPROFILE%, are kept and expanded by Windows.
+func SetVariable(name string, value string, machine bool) error {
+	return setVariable(name, &value, machine)
+}
+
+// RemoveVariable removes the given persistent environment variable of the machine or of the
+// current user, if it is set.
+func RemoveVariable(name string, machine bool) error {
+	return setVariable(name, nil, machine)
+}
+
+// samePath returns whether two entries of PATH refer to the same directory.
+func samePath(a string, b string) bool {
+	return strings.EqualFold(strings.TrimRight(a, `\/`), strings.TrimRight(b, `\/`))
+}
+
+// Equal returns whether two lists of variables, as returned by Persistent, are the same.
+func Equal(a []Variable, b []Variable) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,41 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !windows
+// +build !windows
+
+package environ
+
+import "errors"
+
+// readScopes reads the machine-wide and the per-user environment variables from the registry.
+func readScopes() ([]Variable, []Variable, error) {
+	return nil, nil, errors.New("persistent environment variables are only available on Windows")
+}
+
+// updatePath rewrites the PATH of the machine or of the current user with the given function.
+func updatePath(machine bool, update func([]string) []string) error {
+	return errors.New("persistent environment variables are only available on Windows")
+}
+
+// getVariable reads a variable of the machine or of the current user.
+func getVariable(name string, machine bool) (string, bool, error) {
+	return "", false, errors.New("persistent environment variables are only available on Windows")
+}
+
+// setVariable sets or deletes a variable of the machine or of the current user.
+func setVariable(name string, value *string, machine bool) error {
+	return errors.New("persistent environment variables are only available on Windows")
+}
@@ -0,0 +1,222 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package environ
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	hwndBroadcast    = 0xFFFF
+	smtoAbortIfHung  = 0x0002
+	wmSettingChange  = 0x001A
+	broadcastTimeout = 5000 // Milliseconds
+)
+
+var (
+	user32 = syscall.NewLazyDLL("user32.dll")
+
+	procSendMessageTimeoutW = user32.NewProc("SendMessageTimeoutW")
+)
+
+const (
+	machineEnvironment = `SYSTEM\CurrentControlSet\Control\Session Manager\Environment`
+	userEnvironment    = `Environment`
+)
+
+// readScopes reads the machine-wide and the per-user environment variables from the registry.
+func readScopes() ([]Variable, []Variable, error) {
+	machine, err := readKey(registry.LOCAL_MACHINE, machineEnvironment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := readKey(registry.CURRENT_USER, userEnvironment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return machine, user, nil
+}
+
+func readKey(root registry.Key, path string) ([]Variable, error) {
+	key, err := registry.OpenKey(root, path, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+
+	names, err := key.ReadValueNames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Variable
+
+	for _, name := range names {
+		value, valueType, err := key.GetStringValue(name)
+		if err != nil {
+			// Not a string, Windows ignores those too
+			continue
+		}
+
+		if valueType == registry.EXPAND_SZ {
+			if expanded, err := registry.ExpandString(value); err == nil {
+				value = expanded
+			}
+		}
+
+		ret = append(ret, Variable{name, value})
+	}
+
+	return ret, nil
+}
+
+// environmentKey returns the registry key holding the environment variables of the machine or of
+// the current user.
+func environmentKey(machine bool) (registry.Key, string) {
+	if machine {
+		return registry.LOCAL_MACHINE, machineEnvironment
+	}
+
+	return registry.CURRENT_USER, userEnvironment
+}
+
+// openEnvironment opens the registry key holding the environment variables of the machine or of
+// the current user for writing.
+func openEnvironment(machine bool) (registry.Key, error) {
+	root, path := environmentKey(machine)
+
+	key, _, err := registry.CreateKey(root, path, registry.QUERY_VALUE|registry.SET_VALUE)
+	return key, err
+}
+
+// getVariable reads a variable of the machine or of the current user, as written in the registry.
+func getVariable(name string, machine bool) (string, bool, error) {
+	root, path := environmentKey(machine)
+
+	key, err := registry.OpenKey(root, path, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	defer key.Close()
+
+	value, _, err := key.GetStringValue(name)
+	if err == registry.ErrNotExist {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	return value, true, nil
+}
+
+// updatePath rewrites the PATH of the machine or of the current user with the given function, which
+// receives and returns its entries, and tells running programs about the change.
+func updatePath(machine bool, update func([]string) []string) error {
+	key, err := openEnvironment(machine)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	value, valueType, err := key.GetStringValue("Path")
+	if err != nil && err != registry.ErrNotExist {
+		return err
+	}
+
+	var entries []string
+	for _, entry := range strings.Split(value, ";") {
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	updated := strings.Join(update(entries), ";")
+	if updated == strings.Join(entries, ";") {
+		return nil
+	}
+
+	// Keep the references to other variables, like %SystemRoot%, unexpanded
+	if valueType == registry.EXPAND_SZ || err == registry.ErrNotExist {
+		err = key.SetExpandStringValue("Path", updated)
+	} else {
+		err = key.SetStringValue("Path", updated)
+	}
+	if err != nil {
+		return err
+	}
+
+	return broadcastChange()
+}
+
+// setVariable sets a variable of the machine or of the current user, or deletes it when value is
+// nil, and tells running programs about the change.
+func setVariable(name string, value *string, machine bool) error {
+	key, err := openEnvironment(machine)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	current, _, err := key.GetStringValue(name)
+	if err != nil && err != registry.ErrNotExist {
+		return err
+	}
+
+	exists := err == nil
+
+	switch {
+	case value == nil && !exists:
+		return nil
+	case value == nil:
+		err = key.DeleteValue(name)
+	case exists && current == *value:
+		return nil
+	case strings.Contains(*value, "%"):
+		// References to other variables are expanded by Windows
+		err = key.SetExpandStringValue(name, *value)
+	default:
+		err = key.SetStringValue(name, *value)
+	}
+	if err != nil {
+		return err
+	}
+
+	return broadcastChange()
+}
+
+// broadcastChange tells running programs that the environment variables changed.
+func broadcastChange() error {
+	environment, err := syscall.UTF16PtrFromString("Environment")
+	if err != nil {
+		return err
+	}
+
+	// New processes started by Explorer only see the change after this broadcast
+	var result uintptr
+	procSendMessageTimeoutW.Call(hwndBroadcast, wmSettingChange, 0, uintptr(unsafe.Pointer(environment)), smtoAbortIfHung, broadcastTimeout, uintptr(unsafe.Pointer(&result)))
+
+	return nil
+}
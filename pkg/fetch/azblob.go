@@ -0,0 +1,57 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// fetchAzureBlob downloads a blob from an "azblob://container/blob" URL. The storage account and
+// the shared access signature authorizing requests, if any (public containers need none), come
+// from the object storage settings or from AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_SAS_TOKEN.
+func fetchAzureBlob(ctx context.Context, u *url.URL, options *Options) (string, error) {
+	container := u.Host
+	blob := strings.TrimPrefix(u.Path, "/")
+	if container == "" || blob == "" {
+		return "", fmt.Errorf("malformed Azure Blob URL %v, wanted azblob://container/blob", u)
+	}
+
+	account := settingOrEnv(objectStorage.AzureAccount, "AZURE_STORAGE_ACCOUNT")
+	if account == "" {
+		return "", fmt.Errorf("cannot fetch %v: no storage account configured and AZURE_STORAGE_ACCOUNT is not set", u)
+	}
+
+	endpoint := &url.URL{
+		Scheme:   "https",
+		Host:     account + ".blob.core.windows.net",
+		Path:     "/" + container + "/" + blob,
+		RawQuery: strings.TrimPrefix(settingOrEnv(objectStorage.AzureSASToken, "AZURE_STORAGE_SAS_TOKEN"), "?"),
+	}
+
+	request, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	request = request.WithContext(ctx)
+
+	request.Header.Set("X-Ms-Version", "2019-12-12")
+
+	return download(request, options)
+}
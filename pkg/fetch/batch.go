@@ -0,0 +1,140 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultParallelism is how many downloads FetchAll runs at once, unless configured otherwise.
+const DefaultParallelism = 4
+
+// Request is a download of FetchAll.
+type Request struct {
+	// Resources are the sources of the file, tried in order like with FetchAny.
+	Resources []string
+
+	// Options are the options of the download, including its Destination. Their progress settings
+	// are ignored, FetchAll reports the progress of all the downloads together.
+	Options *Options
+}
+
+// Result is the outcome of a download of FetchAll: the path of the local file, or an error.
+type Result struct {
+	Path string
+	Err  error
+}
+
+// FetchAll fetches the given requests concurrently, at most options.Parallelism at once, and
+// returns their results in the same order. The progress settings of the given options report the
+// progress of all the downloads together.
+func FetchAll(requests []Request, options *Options) []Result {
+	return FetchAllContext(context.Background(), requests, options)
+}
+
+// FetchAllContext is FetchAll, aborting the downloads when the given context is done like
+// FetchContext.
+func FetchAllContext(ctx context.Context, requests []Request, options *Options) []Result {
+	if options == nil {
+		options = &Options{}
+	}
+
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+
+	ret := make([]Result, len(requests))
+	progress := newBatchProgress(options, len(requests))
+	defer progress.finish()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+
+	for i, request := range requests {
+		if request.Options == nil {
+			ret[i].Err = errors.New("no options given for the download")
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, request Request) {
+			defer wg.Done()
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			requestOptions := *request.Options
+			requestOptions.Parallelism = parallelism
+			requestOptions.Progress = false
+			requestOptions.ProgressFunc = func(written int64, total int64) { progress.update(i, written, total) }
+
+			ret[i].Path, ret[i].Err = FetchAnyContext(ctx, request.Resources, &requestOptions)
+		}(i, request)
+	}
+
+	wg.Wait()
+
+	return ret
+}
+
+// batchProgress adds up the progress of the downloads of FetchAll.
+type batchProgress struct {
+	mu       sync.Mutex
+	written  []int64
+	totals   []int64
+	progress *progress
+}
+
+func newBatchProgress(options *Options, n int) *batchProgress {
+	return &batchProgress{
+		written:  make([]int64, n),
+		totals:   make([]int64, n),
+		progress: newProgress(options, 0, 0),
+	}
+}
+
+// update records the progress of the i-th download. The total of all downloads is unknown as long
+// as the total of one of them is.
+func (p *batchProgress) update(i int, written int64, total int64) {
+	if p.progress == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.written[i], p.totals[i] = written, total
+
+	var sumWritten, sumTotal int64
+	for j := range p.written {
+		sumWritten += p.written[j]
+
+		if p.totals[j] < 0 || sumTotal < 0 {
+			sumTotal = -1
+		} else {
+			sumTotal += p.totals[j]
+		}
+	}
+
+	p.progress.set(sumWritten, sumTotal)
+}
+
+func (p *batchProgress) finish() {
+	p.progress.finish(true)
+}
@@ -0,0 +1,86 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const bitsSupported = true
+
+// bitsPersistentScript runs a BITS job that outlives the process, named after its destination so
+// that the next attempt picks up a job left behind by an interrupted one. Jobs that cannot reach
+// the server are given some time before being canceled, to fall back to a regular download.
+const bitsPersistentScript = `$ErrorActionPreference = 'Stop'
+$name = %v
+$job = Get-BitsTransfer | Where-Object { $_.DisplayName -eq $name } | Select-Object -First 1
+if ($job -eq $null) {
+	$job = Start-BitsTransfer -Source %v -Destination %v%v -DisplayName $name -Asynchronous -Priority Normal
+}
+$deadline = (Get-Date).AddMinutes(5)
+while ('Queued', 'Connecting', 'Transferring', 'TransientError', 'Suspended' -contains $job.JobState) {
+	if ($job.JobState -eq 'Suspended') {
+		Resume-BitsTransfer -BitsJob $job -Asynchronous | Out-Null
+	} elseif ($job.JobState -eq 'TransientError' -and (Get-Date) -gt $deadline) {
+		break
+	} elseif ($job.JobState -ne 'TransientError') {
+		$deadline = (Get-Date).AddMinutes(5)
+	}
+	Start-Sleep -Seconds 1
+}
+if ($job.JobState -eq 'Transferred') {
+	Complete-BitsTransfer -BitsJob $job
+} else {
+	$state, $message = $job.JobState, $job.ErrorDescription
+	Remove-BitsTransfer -BitsJob $job
+	throw "BITS job ${state}: $message"
+}`
+
+// bitsTransfer downloads the given URL to the destination with BITS. Persistent transfers run as
+// jobs at normal priority, which BITS keeps across reboots and throttles according to the network
+// cost policy of the machine, and are resumed by the next call for the same destination if this
+// one is interrupted. Other transfers run in the foreground and die with the process. The given
+// "Name: value" headers are sent along with the requests.
+func bitsTransfer(ctx context.Context, rawurl string, dest string, headers []string, persistent bool) error {
+	quote := func(s string) string {
+		return "'" + strings.Replace(s, "'", "''", -1) + "'"
+	}
+
+	customHeaders := ""
+	if len(headers) > 0 {
+		var quoted []string
+		for _, header := range headers {
+			quoted = append(quoted, quote(header))
+		}
+
+		customHeaders = " -CustomHeaders @(" + strings.Join(quoted, ", ") + ")"
+	}
+
+	script := fmt.Sprintf("$ErrorActionPreference = 'Stop'; Start-BitsTransfer -Source %v -Destination %v%v -Priority Foreground", quote(rawurl), quote(dest), customHeaders)
+	if persistent {
+		script = fmt.Sprintf(bitsPersistentScript, quote("just-install "+dest), quote(rawurl), quote(dest), customHeaders)
+	}
+
+	output, err := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// CheckDiskSpace returns a DiskSpaceError when the volume of the given path has less than the given
+// number of bytes available. The path doesn't have to exist yet, its closest existing parent
+// directory is looked at. Sizes that are unknown (negative) or cannot be checked always fit.
+func CheckDiskSpace(path string, needed int64) error {
+	if needed <= 0 {
+		return nil
+	}
+
+	dir := path
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+
+	available, err := freeSpace(dir)
+	if err != nil || available >= needed {
+		return nil
+	}
+
+	return DiskSpaceError{Path: path, Needed: needed, Available: available}
+}
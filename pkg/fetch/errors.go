@@ -0,0 +1,164 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Sentinel errors telling why a fetch failed, see Cause.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrForbidden        = errors.New("access denied")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// HTTPStatusError is returned when an HTTP server answers with an unexpected status code.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP response code from %v: wanted 200 but got %d", e.URL, e.StatusCode)
+}
+
+// ChecksumError is returned by VerifySHA256 when a file doesn't have the expected hash.
+type ChecksumError struct {
+	Path     string
+	Expected string // Lowercase hex
+	Actual   string // Lowercase hex
+}
+
+func (e ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %v: expected SHA-256 %v, got %v", e.Path, e.Expected, e.Actual)
+}
+
+// DiskSpaceError is returned when the volume of a file doesn't have the space it needs, before
+// anything is written to it.
+type DiskSpaceError struct {
+	Path      string
+	Needed    int64 // Bytes
+	Available int64 // Bytes
+}
+
+func (e DiskSpaceError) Error() string {
+	return fmt.Sprintf("not enough disk space for %v: %.1f MB needed, %.1f MB available", e.Path, float64(e.Needed)/1024/1024, float64(e.Available)/1024/1024)
+}
+
+// SourcesError is returned by FetchAny when all the resources failed, in order.
+type SourcesError struct {
+	Errors []error
+}
+
+func (e *SourcesError) Error() string {
+	var errs []string
+	for _, err := range e.Errors {
+		errs = append(errs, err.Error())
+	}
+
+	return fmt.Sprintf("all %d sources failed: %v", len(e.Errors), strings.Join(errs, "; "))
+}
+
+// openError is the failure to open a file on a network share.
+type openError struct {
+	path string
+	err  error
+}
+
+func (e openError) Error() string {
+	return fmt.Sprintf("cannot open %v, make sure the share is online and accessible: %v", e.path, e.err)
+}
+
+// Cause returns why fetching failed for an error returned by this package: ErrNotFound,
+// ErrForbidden or ErrChecksumMismatch when it comes down to one of them. Other errors are returned
+// as they are, without the wrappers internal to this package. When several sources failed, the
+// first one decides since the others are its mirrors.
+func Cause(err error) error {
+	for {
+		switch e := err.(type) {
+		case transientError:
+			err = e.error
+		case redirectError:
+			err = e.error
+		case *SourcesError:
+			if len(e.Errors) == 0 {
+				return err
+			}
+			err = e.Errors[0]
+		case HTTPStatusError:
+			switch e.StatusCode {
+			case http.StatusNotFound, http.StatusGone:
+				return ErrNotFound
+			case http.StatusUnauthorized, http.StatusForbidden:
+				return ErrForbidden
+			}
+			return err
+		case openError:
+			if os.IsNotExist(e.err) {
+				return ErrNotFound
+			} else if os.IsPermission(e.err) {
+				return ErrForbidden
+			}
+			return err
+		case ChecksumError:
+			return ErrChecksumMismatch
+		default:
+			return err
+		}
+	}
+}
+
+// VerifySHA256 checks that the SHA-256 hash of the file at the given path is the expected one,
+// given in hex, returning a ChecksumError otherwise. An empty checksum always matches.
+func VerifySHA256(path string, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+
+	actual, err := SHA256File(path)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(actual, checksum) {
+		return ChecksumError{Path: path, Expected: strings.ToLower(checksum), Actual: actual}
+	}
+
+	return nil
+}
+
+// SHA256File returns the hexadecimal SHA-256 hash of the given file.
+func SHA256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,255 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Downloaders of HTTP resources, see Options.Downloader.
+const (
+	DownloaderBITS = "bits"
+	DownloaderHTTP = "http"
+)
+
+// Options configures how a resource is fetched.
+type Options struct {
+	// Destination is where the resource is saved. When it is an existing directory, the file name
+	// is the one suggested by the Content-Disposition header of HTTP responses, if any, and is
+	// derived from the resource URL otherwise.
+	Destination string
+
+	// Pins, when set, are the SHA-256 hashes of public keys (SPKI) that HTTPS servers must have a
+	// certificate for in their verified chain, in base64 and optionally prefixed with "sha256/".
+	// They apply to every host a download goes through, including redirects.
+	Pins []string
+
+	// Progress enables a progress bar on standard output, unless ProgressFunc is set.
+	Progress bool
+
+	// ProgressFunc, when set, receives the progress of downloads instead of the terminal, for
+	// programs that render it themselves.
+	ProgressFunc ProgressFunc
+
+	// AllowInsecureRedirects allows HTTPS downloads to be redirected to plain HTTP URLs, which is
+	// refused by default since anybody on the network could then tamper with the download.
+	AllowInsecureRedirects bool
+
+	// BITSMinSize, when not zero, routes HTTP downloads of at least this many bytes through the
+	// Background Intelligent Transfer Service on Windows, so that BranchCache can serve them.
+	BITSMinSize int64
+
+	// Connections, when greater than one, splits HTTP downloads in as many concurrent Range
+	// requests, for servers that throttle each connection. Servers without Range support and small
+	// files are downloaded with a single connection.
+	Connections int
+
+	// DialTimeout, when not zero, bounds the time it takes to connect to HTTP servers, or to
+	// proxies, instead of ConnectionPhaseTimeout.
+	DialTimeout time.Duration
+
+	// Downloader, when DownloaderBITS, routes all HTTP downloads through BITS on Windows as jobs
+	// that outlive the process, so that a download interrupted by a reboot or a killed process is
+	// resumed by the next attempt for the same destination. Downloads BITS cannot perform, like the
+	// ones with custom headers, still go through the regular HTTP downloader.
+	Downloader string
+
+	// Headers are additional headers of HTTP requests, like Authorization. Go doesn't forward
+	// sensitive ones when redirected to another domain.
+	Headers map[string]string
+
+	// IdleTimeout is how long HTTP downloads wait for more data before failing, which makes them
+	// retried (see Retries). It defaults to DefaultIdleTimeout.
+	IdleTimeout time.Duration
+
+	// MaxBytesPerSecond, when not zero, limits the download rate of HTTP downloads, including the
+	// concurrent connections of a segmented one, which never go through BITS then.
+	MaxBytesPerSecond int64
+
+	// MaxRedirects is how many redirects HTTP downloads follow, DefaultMaxRedirects if zero. A
+	// negative value refuses all redirects.
+	MaxRedirects int
+
+	// Parallelism is how many downloads FetchAll runs at once, DefaultParallelism if zero.
+	Parallelism int
+
+	// Revalidate, when the destination file already exists, asks HTTP servers whether the resource
+	// changed since it was downloaded (with If-None-Match and If-Modified-Since) and keeps the
+	// existing file if it didn't.
+	Revalidate bool
+
+	// Retries is how many times HTTP downloads are retried after transient failures, such as
+	// connection resets and 5xx responses. Interrupted downloads are resumed when possible.
+	Retries int
+
+	// RetryWait is how long to wait before the first retry, doubled after each attempt. It
+	// defaults to one second.
+	RetryWait time.Duration
+
+	// SameHostRedirects refuses redirects of HTTP downloads to hosts other than the one of the
+	// resource, such as CDNs.
+	SameHostRedirects bool
+
+	// Timeout, when not zero, bounds each HTTP request of a download, including the transfer of
+	// the response body, instead of RequestTimeout.
+	Timeout time.Duration
+
+	// UserAgent, when not empty, is the User-Agent header of HTTP requests instead of the one set
+	// with SetUserAgent. Headers overrides it.
+	UserAgent string
+
+	// result, when set, receives the details of the download, see FetchWithResult.
+	result *FetchResult
+
+	// stat, when set, receives the statistics of the download, see Stats.
+	stat *Stat
+}
+
+// Fetch fetches the given resource and returns the path of the local file containing it. Local
+// resources (file:// URLs and paths like C:\dir\file.msi) are returned as-is, without being copied
+// to the destination. Files on network shares (UNC paths and file:// URLs with a host) are copied.
+func Fetch(resource string, options *Options) (string, error) {
+	return FetchContext(context.Background(), resource, options)
+}
+
+// FetchContext is Fetch, aborted when the given context is done. The error of the context is
+// returned then, and the partial download is removed instead of being kept to be resumed.
+func FetchContext(ctx context.Context, resource string, options *Options) (string, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	withStat := *options
+	withStat.stat = &Stat{Resource: resource}
+
+	start := time.Now()
+	ret, err := fetchResource(ctx, resource, &withStat)
+	if err != nil || withStat.stat.local {
+		return ret, err
+	}
+
+	withStat.stat.Duration = time.Since(start)
+
+	// Fetchers registered with RegisterScheme don't report what they transferred
+	if withStat.stat.Bytes == 0 && !withStat.stat.CacheHit {
+		if info, err := os.Stat(ret); err == nil {
+			withStat.stat.Bytes = info.Size()
+		}
+	}
+	if withStat.stat.CacheHit || offline {
+		withStat.stat.Bytes, withStat.stat.CacheHit = 0, true
+	}
+	recordStat(*withStat.stat)
+
+	return ret, nil
+}
+
+// fetchResource is FetchContext, without the statistics.
+func fetchResource(ctx context.Context, resource string, options *Options) (string, error) {
+	if strings.HasPrefix(resource, `\\`) || strings.HasPrefix(resource, "//") {
+		return fetchFile(ctx, strings.Replace(resource, "/", `\`, -1), options)
+	} else if windowsPath.MatchString(resource) {
+		options.localFile()
+		return resource, nil
+	}
+
+	parsedURL, err := url.Parse(resource)
+	if err != nil {
+		return "", err
+	}
+
+	// Network shares are still read offline
+	if offline && parsedURL.Scheme != "file" && parsedURL.Scheme != "smb" {
+		return fetchOffline(ctx, resource, options)
+	}
+
+	fetcher, ok := schemeFetcher(parsedURL.Scheme)
+	if !ok {
+		return "", fmt.Errorf("unsupported URL scheme %q in %v", parsedURL.Scheme, resource)
+	}
+
+	return fetcher.Fetch(ctx, parsedURL, options)
+}
+
+// FetchAny fetches the first resource of the given list that can be fetched, trying them in order,
+// and returns the path of the local file containing it. It is meant for mirrors of the same file.
+func FetchAny(resources []string, options *Options) (string, error) {
+	return FetchAnyContext(context.Background(), resources, options)
+}
+
+// FetchAnyContext is FetchAny, aborted when the given context is done like FetchContext.
+func FetchAnyContext(ctx context.Context, resources []string, options *Options) (string, error) {
+	return fetchAny(ctx, resources, func(resource string) (string, error) {
+		return FetchContext(ctx, resource, options)
+	})
+}
+
+// fetchAny fetches the given resources in order with the given function until one succeeds.
+func fetchAny(ctx context.Context, resources []string, fetchOne func(string) (string, error)) (string, error) {
+	if len(resources) == 0 {
+		return "", errors.New("no resource to fetch")
+	}
+
+	var errs []error
+	for i, resource := range resources {
+		ret, err := fetchOne(resource)
+		if err == nil {
+			return ret, nil
+		} else if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		errs = append(errs, err)
+		if i+1 < len(resources) {
+			log.Printf("WARNING: %v, trying %v", err, resources[i+1])
+		}
+	}
+
+	return "", &SourcesError{Errors: errs}
+}
+
+// destinationPath returns the path of the file a resource should be saved to. If the destination
+// is a directory, the file name is taken from the last element of the resource path.
+func destinationPath(resourcePath string, options *Options) (string, error) {
+	if options.Destination == "" {
+		return "", fmt.Errorf("no destination given for %v", resourcePath)
+	}
+
+	if destinationIsDir(options) {
+		base := filepath.Base(resourcePath)
+		if base == "." || base == "/" || base == string(filepath.Separator) {
+			return "", fmt.Errorf("cannot derive a file name from %v", resourcePath)
+		}
+
+		return filepath.Join(options.Destination, base), nil
+	}
+
+	return options.Destination, nil
+}
+
+// destinationIsDir returns whether the destination of the options is an existing directory.
+func destinationIsDir(options *Options) bool {
+	info, err := os.Stat(options.Destination)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,171 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// fetchSMB fetches a file from an "smb://[user[:password]@]server/share/path" URL. Credentials, if
+// present, are used to connect to the share before accessing the file.
+func fetchSMB(ctx context.Context, u *url.URL, options *Options) (string, error) {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(segments) < 2 {
+		return "", fmt.Errorf("malformed SMB URL %v, wanted smb://server/share/path", "smb://"+u.Host+u.Path)
+	}
+
+	share := `\\` + u.Host + `\` + segments[0]
+	path := share + `\` + strings.Join(segments[1:], `\`)
+
+	var connectErr error
+	if u.User != nil {
+		password, _ := u.User.Password()
+		connectErr = connectShare(share, u.User.Username(), password)
+	}
+
+	ret, err := fetchFile(ctx, path, options)
+	if err != nil && connectErr != nil {
+		return "", fmt.Errorf("%v (connecting to %v failed: %v)", err, share, connectErr)
+	}
+
+	return ret, err
+}
+
+// windowsPath matches absolute Windows paths with a drive letter, also in the legacy "C|" form of
+// file:// URLs.
+var windowsPath = regexp.MustCompile(`^[A-Za-z][:|][\\/]`)
+
+// fileURLPath returns the path of the file a file:// URL refers to, and whether it is a UNC path:
+//
+//	file:///C:/dir/file.msi, file://localhost/C:/dir/file.msi -> C:\dir\file.msi
+//	file://server/share/file.msi, file:////server/share/file.msi -> \\server\share\file.msi
+//	file:///opt/file (outside of Windows) -> /opt/file
+func fileURLPath(u *url.URL) (string, bool) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque // file:C:/dir/file.msi
+	}
+
+	host := u.Host
+	if strings.EqualFold(host, "localhost") {
+		host = ""
+	}
+
+	switch {
+	case host != "":
+		return `\\` + host + strings.Replace(path, "/", `\`, -1), true
+	case strings.HasPrefix(path, "//"):
+		return `\\` + strings.Replace(strings.TrimLeft(path, "/"), "/", `\`, -1), true
+	case windowsPath.MatchString(strings.TrimPrefix(path, "/")):
+		path = strings.TrimPrefix(path, "/")
+		return path[:1] + ":" + strings.Replace(path[2:], "/", `\`, -1), false
+	}
+
+	return filepath.FromSlash(path), false
+}
+
+// fetchFile copies a file from a UNC path (e.g. "\\server\share\installer.msi") to the
+// destination, so that installers don't depend on the share staying online while they run.
+func fetchFile(ctx context.Context, path string, options *Options) (string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return "", openError{path: path, err: err}
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return "", fmt.Errorf("cannot stat %v: %v", path, err)
+	}
+
+	dest, err := destinationPath(path, options)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	unlock, waited, err := lockDestination(ctx, dest)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if waited && downloadedSince(dest, start) {
+		options.upToDate()
+		return dest, nil
+	}
+
+	defer recordDownload(dest, path)()
+
+	tempDest := dest + ".download"
+	if err := CheckDiskSpace(tempDest, info.Size()); err != nil {
+		return "", err
+	}
+
+	destination, err := os.Create(tempDest)
+	if err != nil {
+		return "", fmt.Errorf("cannot create %v: %v", tempDest, err)
+	}
+	defer os.Remove(tempDest) // Leftover of a failed download, if any
+	defer destination.Close()
+
+	progress := newProgress(options, 0, info.Size())
+	complete := false
+	defer func() { progress.finish(complete) }()
+
+	writer := progress.writer(destination)
+
+	n, err := io.Copy(writer, &contextReader{ctx: ctx, reader: source})
+	options.transferred(n)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		return "", fmt.Errorf("error copying %v: %v", path, err)
+	}
+
+	if err := commitFile(destination, dest); err != nil {
+		return "", err
+	}
+
+	complete = true
+
+	return dest, nil
+}
+
+// contextReader is a reader that fails once its context is done, for copies that have no other way
+// to be canceled.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.reader.Read(p)
+}
@@ -0,0 +1,47 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// gitHubAssetPath matches the paths of the API URLs of GitHub release assets.
+var gitHubAssetPath = regexp.MustCompile(`^/repos/[^/]+/[^/]+/releases/assets/\d+$`)
+
+var gitHubToken string
+
+// SetGitHubToken sets the token that authenticates future downloads of GitHub release assets
+// through their API URL (https://api.github.com/repos/owner/repo/releases/assets/id), which is
+// the only way to download the assets of private repositories.
+func SetGitHubToken(token string) {
+	gitHubToken = token
+}
+
+// setGitHubAssetHeaders asks for the contents of the asset, rather than its description, when the
+// request is for the API URL of a GitHub release asset, authenticating it with the GitHub token.
+// GitHub redirects to a signed URL on another domain, where Go doesn't forward the token.
+func setGitHubAssetHeaders(request *http.Request) {
+	if request.URL.Host != "api.github.com" || !gitHubAssetPath.MatchString(request.URL.Path) {
+		return
+	}
+
+	request.Header.Set("Accept", "application/octet-stream")
+	if gitHubToken != "" {
+		request.Header.Set("Authorization", "token "+gitHubToken)
+	}
+}
@@ -0,0 +1,529 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NewRequest creates a GET request for the given URL, with the headers some vendors require before
+// serving their downloads.
+func NewRequest(rawurl string) (*http.Request, error) {
+	request, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if userAgent != "" {
+		request.Header.Set("User-Agent", userAgent)
+	}
+
+	// Codeplex
+	if strings.Contains(rawurl, "download-codeplex.sec.s-msft.com") {
+		request.Header.Set("User-Agent", "chocolatey command line")
+	}
+
+	// AMD Catalyst
+	if strings.Contains(rawurl, "ati.com") {
+		request.Header.Set("Referer", "http://support.amd.com/")
+	}
+
+	for name, value := range headersFor(request.URL.Hostname()) {
+		request.Header.Set(name, value)
+	}
+
+	return request, nil
+}
+
+// userAgent is the User-Agent header of requests, Go's default if empty (see SetUserAgent).
+var userAgent string
+
+// SetUserAgent sets the User-Agent header that future requests carry, for vendors whose servers
+// reject the default one of Go. Headers set for specific hosts take precedence over it.
+func SetUserAgent(ua string) {
+	userAgent = ua
+}
+
+// hostHeaders are the headers to send to specific hosts, like API tokens (see SetHostHeaders).
+var hostHeaders map[string]map[string]string
+
+// SetHostHeaders sets headers that future requests to the given hosts carry, like the
+// Authorization header of private artifact repositories. Hosts are matched exactly, or with all
+// their subdomains when written as "*.example.com".
+func SetHostHeaders(headers map[string]map[string]string) {
+	hostHeaders = make(map[string]map[string]string)
+	for host, h := range headers {
+		hostHeaders[strings.ToLower(host)] = h
+	}
+}
+
+// headersFor returns the headers to send to the given host. Headers of more specific hosts override
+// the ones of their parent domains.
+func headersFor(host string) map[string]string {
+	host = strings.ToLower(host)
+
+	patterns := []string{host}
+	for domain := host; strings.Contains(domain, "."); {
+		domain = domain[strings.Index(domain, ".")+1:]
+		patterns = append(patterns, "*."+domain)
+	}
+
+	ret := make(map[string]string)
+	for i := len(patterns) - 1; i >= 0; i-- {
+		for name, value := range hostHeaders[patterns[i]] {
+			ret[name] = value
+		}
+	}
+
+	return ret
+}
+
+// NewVendorClient is like NewClient, but the returned client also carries the cookies some vendors
+// require before serving their downloads.
+func NewVendorClient() *http.Client {
+	// JRE/JDK from java.oracle.com
+	oracleURL, _ := url.Parse("http://download.oracle.com")
+	oracleEdeliveryURL, _ := url.Parse("https://edelivery.oracle.com")
+	oracleCookies := []*http.Cookie{{Name: "oraclelicense", Value: "accept-securebackup-cookie"}}
+
+	jar, _ := cookiejar.New(nil)
+	jar.SetCookies(oracleURL, oracleCookies)
+	jar.SetCookies(oracleEdeliveryURL, oracleCookies)
+
+	client := NewClient()
+	client.Jar = jar
+
+	return client
+}
+
+// Get performs a GET request for the given URL with NewRequest and NewVendorClient. An optional
+// timeout overrides the default request timeout.
+func Get(rawurl string, timeout ...time.Duration) (*http.Response, error) {
+	request, err := NewRequest(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewVendorClient()
+	if len(timeout) > 0 {
+		client.Timeout = timeout[0]
+	}
+
+	return client.Do(request)
+}
+
+func fetchHTTP(ctx context.Context, resource string, options *Options) (string, error) {
+	request, err := NewRequest(resource)
+	if err != nil {
+		return "", err
+	}
+	request = request.WithContext(ctx)
+	setHeaders(request, options)
+
+	revalidating := false
+	if options.Revalidate {
+		if dest, err := destinationPath(request.URL.Path, options); err == nil {
+			revalidating = setConditional(request, dest)
+		}
+	}
+
+	// Downloads through BITS are best-effort, falling back to a regular download on failure
+	if options.Downloader == DownloaderBITS || options.BITSMinSize > 0 {
+		reason := "the cached copy is being revalidated"
+		if !revalidating {
+			reason = bitsBlocker(request, options)
+		}
+
+		if reason != "" && options.Downloader == DownloaderBITS {
+			log.Printf("WARNING: cannot download %v with BITS, downloading it directly: %v", request.URL, reason)
+		} else if reason == "" && useBITS(request, options) {
+			dest, err := bitsDownload(request, options)
+			if err == nil {
+				return dest, nil
+			} else if options.Downloader == DownloaderBITS {
+				log.Printf("WARNING: BITS could not download %v, downloading it directly: %v", request.URL, err)
+			}
+		}
+	}
+
+	return download(request, options)
+}
+
+// setHeaders sets the User-Agent and the additional headers of the options on the request, along
+// with the ones of GitHub release assets.
+func setHeaders(request *http.Request, options *Options) {
+	if options.UserAgent != "" {
+		request.Header.Set("User-Agent", options.UserAgent)
+	}
+
+	setGitHubAssetHeaders(request)
+
+	for name, value := range options.Headers {
+		request.Header.Set(name, value)
+	}
+}
+
+// bitsDownload downloads the resource with bitsTransfer, holding the lock of the destination.
+func bitsDownload(request *http.Request, options *Options) (string, error) {
+	dest, err := destinationPath(request.URL.Path, options)
+	if err != nil {
+		return "", err
+	}
+
+	unlock, _, err := lockDestination(request.Context(), dest)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	var headers []string
+	for name, values := range request.Header {
+		for _, value := range values {
+			headers = append(headers, name+": "+value)
+		}
+	}
+	sort.Strings(headers)
+
+	if err := bitsTransfer(request.Context(), request.URL.String(), dest, headers, options.Downloader == DownloaderBITS); err != nil {
+		return dest, err
+	}
+
+	if info, err := os.Stat(dest); err == nil {
+		options.transferred(info.Size())
+	}
+
+	return dest, nil
+}
+
+// bitsUnsupportedHeaders are the request headers that are not handed to BITS: credentials, which
+// BITS would send along when redirected to another host, and the ones BITS manages itself.
+var bitsUnsupportedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Range", "If-Modified-Since", "If-None-Match", "If-Range"}
+
+// bitsBlocker returns why the request cannot go through BITS, or the empty string if it can. Other
+// headers, like the User-Agent, are sent by BITS as custom headers.
+func bitsBlocker(request *http.Request, options *Options) string {
+	switch {
+	case !bitsSupported:
+		return "BITS is only available on Windows"
+	case options.MaxBytesPerSecond > 0:
+		return "BITS cannot limit the download rate"
+	case len(options.Pins) > 0:
+		return "BITS cannot check certificate pins"
+	case strings.Contains(request.URL.Host, "oracle.com"):
+		return "Oracle downloads need cookies"
+	}
+
+	for _, name := range bitsUnsupportedHeaders {
+		if request.Header.Get(name) != "" {
+			return fmt.Sprintf("BITS cannot send the %v header", name)
+		}
+	}
+
+	return ""
+}
+
+// useBITS returns whether a request that BITS can make (see bitsBlocker) should go through it,
+// which is the case for all downloads with DownloaderBITS, and for large enough ones otherwise.
+func useBITS(request *http.Request, options *Options) bool {
+	if options.Downloader == DownloaderBITS {
+		return true
+	}
+
+	head, err := http.NewRequest("HEAD", request.URL.String(), nil)
+	if err != nil {
+		return false
+	}
+
+	response, err := newDownloadClient(options, 1).Do(head.WithContext(request.Context()))
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+
+	return response.StatusCode == http.StatusOK && response.ContentLength >= options.BITSMinSize
+}
+
+// transientError marks failures worth retrying, like connection resets and server errors.
+type transientError struct {
+	error
+}
+
+// ErrTruncatedDownload is returned when an HTTP response body ends before the length announced by
+// the server, once retries are exhausted. The partial file is never renamed into place.
+type ErrTruncatedDownload struct {
+	URL      string
+	Expected int64 // Bytes
+	Received int64 // Bytes
+}
+
+func (e ErrTruncatedDownload) Error() string {
+	return fmt.Sprintf("download of %v truncated: received %d of %d bytes", e.URL, e.Received, e.Expected)
+}
+
+// download is downloadOnce, retried with exponential backoff on transient failures as configured in
+// the options. Interrupted downloads are resumed by the next attempt when possible. Processes
+// downloading to the same destination take turns, and the ones that waited use the file downloaded
+// by the others.
+func download(request *http.Request, options *Options) (string, error) {
+	wait := options.RetryWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+
+	ctx := request.Context()
+
+	dest, err := destinationPath(request.URL.Path, options)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	unlock, waited, err := lockDestination(ctx, dest)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if waited && downloadedSince(dest, start) {
+		options.upToDate()
+		return dest, nil
+	}
+
+	defer recordDownload(dest, request.URL.String())()
+
+	for attempt := 0; ; attempt++ {
+		dest, err := downloadOnce(request, options)
+		if err != nil && ctx.Err() != nil {
+			return "", ctx.Err()
+		} else if transient, ok := err.(transientError); !ok {
+			return dest, err
+		} else if attempt >= options.Retries {
+			return "", transient.error
+		}
+
+		log.Printf("WARNING: %v, retrying in %v", err, wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		}
+
+		wait *= 2
+	}
+}
+
+// downloadOnce performs the given request and saves the response body to the destination, through
+// a temporary file that is renamed into place only when the download completes. The temporary file
+// of an interrupted download is kept, along with the validator (ETag or Last-Modified) of the
+// response, so that the next attempt resumes it with a Range request, unless the download was
+// canceled through the context of the request.
+func downloadOnce(request *http.Request, options *Options) (string, error) {
+	if options.Connections > 1 {
+		if dest, err := downloadSegmented(request, options); err != errNotSegmentable {
+			return dest, err
+		}
+	}
+
+	dest, err := destinationPath(request.URL.Path, options)
+	if err != nil {
+		return "", err
+	}
+
+	tempDest := dest + ".download"
+	validatorPath := tempDest + ".validator"
+
+	// Resume only when we can tell that the resource didn't change in the meantime
+	offset := int64(0)
+	if info, err := os.Stat(tempDest); err == nil && info.Size() > 0 {
+		if validator, err := ioutil.ReadFile(validatorPath); err == nil && len(validator) > 0 {
+			offset = info.Size()
+			request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			request.Header.Set("If-Range", string(validator))
+		}
+	}
+
+	response, err := newDownloadClient(options, 1).Do(request)
+	if err != nil {
+		return "", connectionError(request, err)
+	}
+	defer response.Body.Close()
+
+	resumed := response.StatusCode == http.StatusPartialContent && contentRangeStart(response) == offset
+
+	switch {
+	case response.StatusCode == http.StatusNotModified && request.Header.Get("If-Modified-Since") != "":
+		// The existing file is up to date
+		options.recordResponse(response)
+		options.upToDate()
+		return dest, nil
+	case offset > 0 && resumed:
+		// Appending to the leftover
+	case response.StatusCode == http.StatusOK:
+		offset = 0
+	case offset > 0 && (response.StatusCode == http.StatusPartialContent || response.StatusCode == http.StatusRequestedRangeNotSatisfiable):
+		// The leftover doesn't match the resource, start over
+		response.Body.Close()
+		os.Remove(tempDest)
+		os.Remove(validatorPath)
+		request.Header.Del("Range")
+		request.Header.Del("If-Range")
+
+		return downloadOnce(request, options)
+	case response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests:
+		return "", transientError{HTTPStatusError{URL: request.URL.String(), StatusCode: response.StatusCode}}
+	default:
+		return "", HTTPStatusError{URL: request.URL.String(), StatusCode: response.StatusCode}
+	}
+
+	// Names like "download.php" or "latest" don't tell what the file is, the server does
+	if name := responseFileName(response); name != "" && destinationIsDir(options) {
+		dest = filepath.Join(options.Destination, name)
+	}
+
+	if err := CheckDiskSpace(tempDest, response.ContentLength); err != nil {
+		return "", err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	destination, err := os.OpenFile(tempDest, flags, 0644)
+	if err != nil {
+		return "", fmt.Errorf("cannot create %v: %v", tempDest, err)
+	}
+	defer destination.Close()
+
+	// Without a validator, the leftover of a failed download cannot be resumed safely
+	validator := responseValidator(response)
+	if offset == 0 {
+		os.Remove(validatorPath)
+
+		if validator != "" {
+			ioutil.WriteFile(validatorPath, []byte(validator), 0644)
+		}
+	}
+
+	complete := false
+	resumable := offset > 0 || validator != ""
+	defer func() {
+		if !complete && (!resumable || request.Context().Err() != nil) {
+			destination.Close()
+			os.Remove(tempDest)
+			os.Remove(validatorPath)
+		}
+	}()
+
+	total := int64(-1)
+	if response.ContentLength >= 0 {
+		total = offset + response.ContentLength
+	}
+
+	progress := newProgress(options, offset, total)
+	defer func() { progress.finish(complete) }()
+
+	writer := progress.writer(destination)
+
+	var limiter *rateLimiter
+	if options.MaxBytesPerSecond > 0 {
+		limiter = newRateLimiter(options.MaxBytesPerSecond)
+	}
+
+	body, stopWatchdog := watchIdle(response.Body, options)
+	defer stopWatchdog()
+
+	// A connection closed early may look like the end of the body, don't take it for the whole file
+	n, err := io.Copy(writer, limiter.reader(body))
+	options.transferred(n)
+	if (err == nil || err == io.ErrUnexpectedEOF) && response.ContentLength > 0 && n < response.ContentLength {
+		return "", transientError{ErrTruncatedDownload{URL: request.URL.String(), Expected: offset + response.ContentLength, Received: offset + n}}
+	} else if err != nil {
+		return "", transientError{fmt.Errorf("error downloading %v: %v", request.URL, err)}
+	}
+
+	if err := commitFile(destination, dest); err != nil {
+		return "", err
+	}
+
+	complete = true
+	os.Remove(validatorPath)
+	saveValidators(dest, response.Header)
+	options.recordResponse(response)
+
+	return dest, nil
+}
+
+// responseFileName returns the file name suggested by the Content-Disposition header of the
+// response, if any and safe to use as the name of a local file.
+func responseFileName(response *http.Response) string {
+	_, params, err := mime.ParseMediaType(response.Header.Get("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+
+	// Only keep the last element of names that are paths
+	name := path.Base(strings.Replace(params["filename"], `\`, "/", -1))
+	if name == "." || name == ".." || name == "/" || strings.ContainsAny(name, `<>:"|?*`) {
+		return ""
+	}
+
+	for _, r := range name {
+		if r < 32 {
+			return ""
+		}
+	}
+
+	return name
+}
+
+// responseValidator returns the value identifying the version of the resource in the response, to
+// be sent back in an If-Range header. Weak ETags cannot be used for that.
+func responseValidator(response *http.Response) string {
+	if etag := response.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return response.Header.Get("Last-Modified")
+}
+
+// contentRangeStart returns the first byte of a partial response, -1 if it cannot be parsed.
+func contentRangeStart(response *http.Response) int64 {
+	var start, end int64
+
+	if _, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-%d", &start, &end); err != nil {
+		return -1
+	}
+
+	return start
+}
@@ -0,0 +1,259 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// journalName is the name of the journal of the downloads in progress, kept in every directory
+// downloads are written to.
+const journalName = "downloads.journal"
+
+// journalEntry records a download in progress, keyed by the name of its temporary file.
+type journalEntry struct {
+	PID     int
+	Started time.Time
+	URL     string
+}
+
+// commitFile flushes the given temporary file to disk, closes it and moves it to dest, syncing the
+// directory too. Otherwise a crash right after the rename may leave a truncated file, or no file at
+// all, where a complete download was expected.
+func commitFile(f *os.File, dest string) error {
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot sync %v: %v", f.Name(), err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close %v: %v", f.Name(), err)
+	}
+
+	if err := os.Rename(f.Name(), dest); err != nil {
+		return fmt.Errorf("cannot rename %v to %v: %v", f.Name(), dest, err)
+	}
+
+	syncDir(filepath.Dir(dest))
+
+	return nil
+}
+
+// syncDir flushes the entries of the given directory to disk. Windows can't sync directories, and
+// doesn't need to since NTFS journals renames, so errors are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// recordDownload records in the journal of its directory that this process started downloading
+// the given resource to dest, and returns the function removing the record once the download is
+// over. Errors are only logged, since the journal merely helps CollectGarbage.
+func recordDownload(dest string, resource string) func() {
+	dir := filepath.Dir(dest)
+	name := filepath.Base(dest) + ".download"
+
+	err := updateJournal(dir, func(journal map[string]journalEntry) {
+		journal[name] = journalEntry{PID: os.Getpid(), Started: time.Now(), URL: resource}
+	})
+	if err != nil {
+		log.Println("WARNING: cannot update the download journal:", err)
+	}
+
+	return func() {
+		updateJournal(dir, func(journal map[string]journalEntry) { delete(journal, name) })
+	}
+}
+
+// updateJournal applies the given function to the journal of the given directory while holding
+// its lock, and writes it back.
+func updateJournal(dir string, update func(map[string]journalEntry)) error {
+	unlock, _, err := lockFile(context.Background(), filepath.Join(dir, journalName+".lock"), func() {})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	journal, err := loadJournal(dir)
+	if err != nil {
+		return err
+	}
+
+	update(journal)
+
+	return saveJournal(dir, journal)
+}
+
+// loadJournal reads the journal of the given directory. A missing or garbled journal is an empty
+// one, there is nothing better to do with it.
+func loadJournal(dir string) (map[string]journalEntry, error) {
+	journal := make(map[string]journalEntry)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, journalName))
+	if os.IsNotExist(err) {
+		return journal, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &journal); err != nil || journal == nil {
+		return make(map[string]journalEntry), nil
+	}
+
+	return journal, nil
+}
+
+// saveJournal writes the journal of the given directory, removing it when empty.
+func saveJournal(dir string, journal map[string]journalEntry) error {
+	path := filepath.Join(dir, journalName)
+
+	if len(journal) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	return commitFile(f, path)
+}
+
+// CollectGarbage removes from the given directory the partial downloads no running process works
+// on: the ones the journal records as started by a process that crashed, and the ones kept to be
+// resumed for longer than maxAge. Stale lock files are removed too. It returns the removed files.
+func CollectGarbage(dir string, maxAge time.Duration) ([]string, error) {
+	unlock, _, err := lockFile(context.Background(), filepath.Join(dir, journalName+".lock"), func() {})
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	journal, err := loadJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	remove := func(path string) error {
+		err := os.Remove(path)
+		if err == nil {
+			removed = append(removed, path)
+		} else if os.IsNotExist(err) {
+			err = nil
+		}
+
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		path := filepath.Join(dir, name)
+
+		switch {
+		case f.IsDir() || name == journalName+".lock":
+			continue
+		case strings.HasSuffix(name, ".download"):
+			if downloadRunning(strings.TrimSuffix(path, ".download")) {
+				continue
+			}
+
+			if _, crashed := journal[name]; !crashed && time.Since(f.ModTime()) < maxAge {
+				continue
+			}
+
+			if err := remove(path); err != nil {
+				return removed, err
+			}
+
+			if err := remove(path + ".validator"); err != nil {
+				return removed, err
+			}
+
+			delete(journal, name)
+		case strings.HasSuffix(name, ".download.validator"):
+			// Validators outlive their partial download when its removal was interrupted
+			if _, err := os.Stat(strings.TrimSuffix(path, ".validator")); !os.IsNotExist(err) {
+				continue
+			} else if err := remove(path); err != nil {
+				return removed, err
+			}
+		case strings.HasSuffix(name, ".lock"):
+			downloadRunning(strings.TrimSuffix(path, ".lock"))
+		}
+	}
+
+	// Downloads that crashed before creating their temporary file leave nothing else behind
+	for name := range journal {
+		if !downloadRunning(filepath.Join(dir, strings.TrimSuffix(name, ".download"))) {
+			delete(journal, name)
+		}
+	}
+
+	return removed, saveJournal(dir, journal)
+}
+
+// downloadRunning reports whether a process holds the lock of the given destination. Lock files
+// nobody holds, left behind by processes that crashed, are removed on the way.
+func downloadRunning(dest string) bool {
+	f, err := os.OpenFile(dest+".lock", os.O_RDWR, 0644)
+	if err != nil {
+		return false
+	}
+
+	locked, err := tryLockFile(f)
+	if err != nil {
+		f.Close()
+		return false
+	} else if !locked {
+		f.Close()
+		return true
+	}
+
+	releaseLock(f)
+
+	return false
+}
@@ -0,0 +1,98 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// lockPollInterval is how often a download waiting for another process checks whether it is done.
+const lockPollInterval = 250 * time.Millisecond
+
+// lockDestination takes the advisory lock of the given destination file, shared by all the
+// just-install processes downloading to it, waiting for the current holder (if any) to release it
+// or for the context to be done. It returns the function releasing the lock and whether another
+// process held it in the meantime.
+func lockDestination(ctx context.Context, dest string) (func(), bool, error) {
+	return lockFile(ctx, dest+".lock", func() { log.Printf("Waiting for another download to %v", dest) })
+}
+
+// lockFile takes the advisory lock at the given path, calling onWait once if it has to wait for
+// another holder.
+func lockFile(ctx context.Context, lockPath string, onWait func()) (func(), bool, error) {
+	waited := false
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, waited, err
+		}
+
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, waited, err
+		}
+
+		// The previous holder removes the lock file, which may have happened right before we locked it
+		if locked && sameFile(f, lockPath) {
+			return func() { releaseLock(f) }, waited, nil
+		} else if locked {
+			f.Close()
+			continue
+		}
+
+		f.Close()
+
+		if !waited {
+			onWait()
+			waited = true
+		}
+
+		timer := time.NewTimer(lockPollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, waited, ctx.Err()
+		}
+	}
+}
+
+// sameFile returns whether the open file is still the one at the given path.
+func sameFile(f *os.File, path string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(opened, current)
+}
+
+// downloadedSince returns whether the given file was written since the given time, i.e. by the
+// process we waited for.
+func downloadedSince(path string, since time.Time) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && !info.ModTime().Before(since)
+}
@@ -0,0 +1,256 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+const (
+	ntlmNegotiateUnicode          = 0x00000001
+	ntlmRequestTarget             = 0x00000004
+	ntlmNegotiateNTLM             = 0x00000200
+	ntlmNegotiateAlwaysSign       = 0x00008000
+	ntlmNegotiateExtendedSecurity = 0x00080000
+	ntlmNegotiateTargetInfo       = 0x00800000
+	ntlmNegotiate128              = 0x20000000
+	ntlmNegotiate56               = 0x80000000
+
+	ntlmFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign |
+		ntlmNegotiateExtendedSecurity | ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+)
+
+// dialNTLMTunnel opens a CONNECT tunnel to the given address through the given proxy,
+// authenticating the connection with NTLMv2.
+func dialNTLMTunnel(ctx context.Context, proxy *url.URL, addr string, username string, password string) (net.Conn, error) {
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), "8080")
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(ConnectionPhaseTimeout))
+
+	reader := bufio.NewReader(conn)
+
+	response, err := connect(conn, reader, addr, ntlmNegotiateMessage())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusProxyAuthRequired {
+		challenge, err := ntlmChallenge(response)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		authenticate, err := ntlmAuthenticateMessage(challenge, username, password)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if response, err = connect(conn, reader, addr, authenticate); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %v refused to connect to %v: %v", proxy.Host, addr, response.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// connect sends a CONNECT request with the given NTLM message and reads the response, discarding
+// its body so that the connection can be reused for the next step of the handshake.
+func connect(conn net.Conn, reader *bufio.Reader, addr string, message []byte) (*http.Response, error) {
+	request := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{
+			"Proxy-Authorization": {"NTLM " + base64.StdEncoding.EncodeToString(message)},
+			"Proxy-Connection":    {"Keep-Alive"},
+		},
+	}
+
+	if err := request.Write(conn); err != nil {
+		return nil, err
+	}
+
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}
+
+	return response, nil
+}
+
+// ntlmChallenge extracts the NTLM challenge message from a 407 response.
+func ntlmChallenge(response *http.Response) ([]byte, error) {
+	for _, value := range response.Header["Proxy-Authenticate"] {
+		if strings.HasPrefix(value, "NTLM ") {
+			return base64.StdEncoding.DecodeString(strings.TrimSpace(value[5:]))
+		}
+	}
+
+	return nil, errors.New("the proxy doesn't offer NTLM authentication")
+}
+
+// ntlmNegotiateMessage returns the first message of the NTLM handshake.
+func ntlmNegotiateMessage() []byte {
+	ret := make([]byte, 32)
+	copy(ret, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(ret[8:], 1)
+	binary.LittleEndian.PutUint32(ret[12:], ntlmFlags)
+
+	return ret
+}
+
+// ntlmAuthenticateMessage answers the given challenge message with an NTLMv2 response for the given
+// credentials, where the user name can be in the "DOMAIN\user" form.
+func ntlmAuthenticateMessage(challenge []byte, username string, password string) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.HasPrefix(challenge, []byte("NTLMSSP\x00")) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errors.New("invalid NTLM challenge from the proxy")
+	}
+
+	serverChallenge := challenge[24:32]
+
+	targetInfoLen := int(binary.LittleEndian.Uint16(challenge[40:]))
+	targetInfoOffset := int(binary.LittleEndian.Uint32(challenge[44:]))
+	if targetInfoOffset+targetInfoLen > len(challenge) {
+		return nil, errors.New("invalid NTLM challenge from the proxy")
+	}
+	targetInfo := challenge[targetInfoOffset : targetInfoOffset+targetInfoLen]
+
+	domain := ""
+	if i := strings.Index(username, `\`); i >= 0 {
+		domain, username = username[:i], username[i+1:]
+	}
+
+	hash := md4.New()
+	hash.Write(utf16LE(password))
+
+	ntowf := hmac.New(md5.New, hash.Sum(nil))
+	ntowf.Write(utf16LE(strings.ToUpper(username) + domain))
+	responseKey := ntowf.Sum(nil)
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+
+	// Windows timestamps count 100ns intervals since 1601
+	timestamp := make([]byte, 8)
+	binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+116444736000000000))
+
+	var blob bytes.Buffer
+	blob.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	blob.Write(timestamp)
+	blob.Write(clientChallenge)
+	blob.Write([]byte{0, 0, 0, 0})
+	blob.Write(targetInfo)
+	blob.Write([]byte{0, 0, 0, 0})
+
+	proof := hmac.New(md5.New, responseKey)
+	proof.Write(serverChallenge)
+	proof.Write(blob.Bytes())
+	ntResponse := append(proof.Sum(nil), blob.Bytes()...)
+
+	lm := hmac.New(md5.New, responseKey)
+	lm.Write(serverChallenge)
+	lm.Write(clientChallenge)
+	lmResponse := append(lm.Sum(nil), clientChallenge...)
+
+	fields := [][]byte{lmResponse, ntResponse, utf16LE(domain), utf16LE(username), nil, nil}
+
+	ret := make([]byte, 64)
+	copy(ret, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(ret[8:], 3)
+	binary.LittleEndian.PutUint32(ret[60:], ntlmFlags)
+
+	// Each field is described by its length, maximum length and offset in the payload
+	for i, field := range fields {
+		header := ret[12+8*i:]
+		binary.LittleEndian.PutUint16(header, uint16(len(field)))
+		binary.LittleEndian.PutUint16(header[2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(ret)))
+		ret = append(ret, field...)
+	}
+
+	return ret, nil
+}
+
+// utf16LE encodes a string as little-endian UTF-16, as NTLM expects.
+func utf16LE(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+
+	ret := make([]byte, 2*len(codes))
+	for i, code := range codes {
+		binary.LittleEndian.PutUint16(ret[2*i:], code)
+	}
+
+	return ret
+}
+
+// bufferedConn is a connection whose first bytes were already read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
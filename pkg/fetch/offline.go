@@ -0,0 +1,117 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// offline, when true, makes remote resources resolve from local copies only (see SetOffline).
+var offline bool
+
+// offlineDirs are the directories searched for the remote resources needed offline.
+var offlineDirs []string
+
+// errNetworkDisabled is the error of the connections attempted offline.
+var errNetworkDisabled = errors.New("network access is disabled")
+
+// ErrOffline is the error of fetching a remote resource offline when no local copy of it exists.
+type ErrOffline struct {
+	Resource string
+}
+
+func (e *ErrOffline) Error() string {
+	return fmt.Sprintf("%v is not available offline", e.Resource)
+}
+
+// SetOffline disables network access, or enables it again. Offline, HTTP, S3 and Azure Blob
+// resources are not downloaded but resolved from their destination, where an earlier download left
+// them, or from files of the same name in the given directories and in their "files"
+// subdirectories, like the ones of a mirror. Files on network shares are still copied.
+func SetOffline(enabled bool, dirs ...string) {
+	offline = enabled
+	offlineDirs = dirs
+}
+
+// IsOffline returns whether network access is disabled (see SetOffline).
+func IsOffline() bool {
+	return offline
+}
+
+// Local returns the path of a local copy of the given remote resource, the one FetchContext uses
+// offline: its destination according to the given options, if it exists, or a file of the same
+// name in the offline directories.
+func Local(resource string, options *Options) (string, bool) {
+	u, err := url.Parse(resource)
+	if err != nil {
+		return "", false
+	}
+
+	var names []string
+
+	if options != nil && options.Destination != "" {
+		if dest, err := destinationPath(u.Path, options); err == nil {
+			if isRegularFile(dest) {
+				return dest, true
+			}
+
+			names = append(names, filepath.Base(dest))
+		}
+	}
+
+	if base := path.Base(u.Path); base != "." && base != "/" {
+		names = append(names, base)
+	}
+
+	for _, dir := range offlineDirs {
+		for _, name := range names {
+			for _, candidate := range []string{filepath.Join(dir, name), filepath.Join(dir, "files", name)} {
+				if isRegularFile(candidate) {
+					return candidate, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// fetchOffline resolves a remote resource from its local copy, which is copied to the destination
+// unless it is the destination itself.
+func fetchOffline(ctx context.Context, resource string, options *Options) (string, error) {
+	local, ok := Local(resource, options)
+	if !ok {
+		return "", &ErrOffline{Resource: resource}
+	}
+
+	if dest, err := destinationPath(local, options); err == nil && dest == local {
+		return local, nil
+	}
+
+	return fetchFile(ctx, local, options)
+}
+
+// isRegularFile returns whether a regular file exists at the given path.
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -16,8 +16,16 @@
 package fetch
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,29 +38,207 @@ const ConnectionPhaseTimeout = 5 * time.Second
 // time needed to download the requested file.
 const RequestTimeout = 30 * time.Minute
 
-// Transport is an HTTP transport optimized to perform a sigle request to a single host, with short
-// timeouts for various connection phases.
+// DefaultIdleTimeout is how long downloads wait for data before giving up, unless configured
+// otherwise.
+const DefaultIdleTimeout = time.Minute
+
+// DefaultIdleConnTimeout is how long idle connections are kept open to be reused by later
+// requests to the same host, unless configured otherwise with SetTransportLimits.
+const DefaultIdleConnTimeout = 90 * time.Second
+
+// dialer opens the connections of Transport, including the ones to proxies. Timeouts are left to
+// the context.
+var dialer = &net.Dialer{
+	DualStack: true,
+	KeepAlive: 30 * time.Second,
+}
+
+// dial opens the connections of Transport, directly or through a proxy (see SetProxy).
+var dial = dialer.DialContext
+
+// dialWithTimeout returns a dial function that gives up after the given timeout.
+func dialWithTimeout(timeout time.Duration) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		if offline {
+			return nil, errNetworkDisabled
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return dial(ctx, network, addr)
+	}
+}
+
+// Transport is the HTTP transport shared by all requests, with short timeouts for various
+// connection phases. It speaks HTTP/2 when servers do and keeps connections open, so that the
+// files of a multi-package install coming from the same CDN don't each pay for a TLS handshake.
+// Its proxy can be configured with SetProxy and its limits with SetTransportLimits.
 var Transport = &http.Transport{
-	DialContext: (&net.Dialer{
-		DualStack: true,
-		KeepAlive: 0,
-		Timeout:   ConnectionPhaseTimeout,
-	}).DialContext,
-	DisableKeepAlives:     true,
+	DialContext:           dialWithTimeout(ConnectionPhaseTimeout),
 	ExpectContinueTimeout: ConnectionPhaseTimeout,
-	IdleConnTimeout:       ConnectionPhaseTimeout,
-	MaxConnsPerHost:       1,
-	Proxy:                 http.ProxyFromEnvironment,
+	ForceAttemptHTTP2:     true,
+	IdleConnTimeout:       DefaultIdleConnTimeout,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   8,
+	Proxy: func(request *http.Request) (*url.URL, error) {
+		return systemOrEnvironmentProxy(request.URL)
+	},
 	ResponseHeaderTimeout: ConnectionPhaseTimeout,
 	TLSHandshakeTimeout:   ConnectionPhaseTimeout,
 }
 
+// TransportLimits bounds the connections of Transport.
+type TransportLimits struct {
+	MaxConnsPerHost     int           // Optional, connections to a single host, unlimited if 0
+	MaxIdleConnsPerHost int           // Optional, idle connections kept open per host, 8 if 0
+	IdleConnTimeout     time.Duration // Optional, DefaultIdleConnTimeout if 0, negative disables keep-alives
+}
+
+// SetTransportLimits applies the given limits to the connections of future requests.
+func SetTransportLimits(limits TransportLimits) {
+	Transport.MaxConnsPerHost = limits.MaxConnsPerHost
+
+	Transport.MaxIdleConnsPerHost = limits.MaxIdleConnsPerHost
+	if Transport.MaxIdleConnsPerHost <= 0 {
+		Transport.MaxIdleConnsPerHost = 8
+	}
+
+	Transport.DisableKeepAlives = limits.IdleConnTimeout < 0
+	Transport.IdleConnTimeout = limits.IdleConnTimeout
+	if Transport.IdleConnTimeout <= 0 {
+		Transport.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+
+	resetTransports()
+}
+
+// downloadTransportKey identifies the variants of Transport needed by downloads.
+type downloadTransportKey struct {
+	dialTimeout time.Duration
+	pins        string
+	connections int
+}
+
+// downloadTransports are the variants of Transport created for downloads so far, kept so that
+// their connections are reused too.
+var (
+	downloadTransports   = make(map[downloadTransportKey]*http.Transport)
+	downloadTransportsMu sync.Mutex
+)
+
+// resetTransports closes the idle connections of Transport and forgets its variants, which must be
+// done whenever its settings change.
+func resetTransports() {
+	downloadTransportsMu.Lock()
+	defer downloadTransportsMu.Unlock()
+
+	for _, transport := range downloadTransports {
+		transport.CloseIdleConnections()
+	}
+	downloadTransports = make(map[downloadTransportKey]*http.Transport)
+
+	Transport.CloseIdleConnections()
+}
+
+// downloadTransport returns the variant of Transport with the given dial timeout and pins,
+// allowing the given number of concurrent connections per host, creating it if needed.
+func downloadTransport(dialTimeout time.Duration, pins []string, connections int) *http.Transport {
+	key := downloadTransportKey{dialTimeout: dialTimeout, pins: strings.Join(pins, ","), connections: connections}
+
+	downloadTransportsMu.Lock()
+	defer downloadTransportsMu.Unlock()
+
+	if transport, ok := downloadTransports[key]; ok {
+		return transport
+	}
+
+	transport := Transport.Clone()
+	if dialTimeout > 0 {
+		transport.DialContext = dialWithTimeout(dialTimeout)
+	}
+	if len(pins) > 0 {
+		transport.TLSClientConfig = tlsConfig(pins)
+	}
+	if connections > 1 {
+		if transport.MaxConnsPerHost > 0 && transport.MaxConnsPerHost < connections {
+			transport.MaxConnsPerHost = connections
+		}
+
+		// HTTP/2 would multiplex the segments over a single connection, which is what they avoid
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig.NextProtos = nil
+		}
+	}
+
+	downloadTransports[key] = transport
+
+	return transport
+}
+
 // NewClient creates a new HTTP client with a default request timeout (see also `RequestTimeout`)
-// that uses our `Transport`. Unlike Go stdlib's HTTP client, ours is to be closed and discarded
-// after one request.
+// that uses our `Transport`. Clients are cheap, the connections they open are kept by the shared
+// transport.
 func NewClient() *http.Client {
 	return &http.Client{
 		Timeout:   RequestTimeout,
 		Transport: Transport,
 	}
 }
+
+// newDownloadClient is NewVendorClient with the timeouts, pins and redirect policy of the given
+// options, allowing the given number of concurrent connections per host.
+func newDownloadClient(options *Options, connections int) *http.Client {
+	client := NewVendorClient()
+	client.CheckRedirect = checkRedirect(options)
+	if options.Timeout > 0 {
+		client.Timeout = options.Timeout
+	}
+
+	if options.DialTimeout > 0 || len(options.Pins) > 0 || connections > 1 {
+		client.Transport = downloadTransport(options.DialTimeout, options.Pins, connections)
+	}
+
+	return client
+}
+
+// idleReader fails reads from a response body that receives no data for the given duration,
+// closing the body to interrupt the pending read.
+type idleReader struct {
+	body     io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut int32
+}
+
+// watchIdle returns a reader of the given body that fails if no data arrives for the idle timeout
+// of the options, DefaultIdleTimeout if not set. The returned function stops the watchdog.
+func watchIdle(body io.ReadCloser, options *Options) (io.Reader, func()) {
+	timeout := options.IdleTimeout
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+
+	ret := &idleReader{body: body, timeout: timeout}
+	ret.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&ret.timedOut, 1)
+		body.Close()
+	})
+
+	return ret, func() { ret.timer.Stop() }
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if atomic.LoadInt32(&r.timedOut) != 0 {
+		return n, fmt.Errorf("no data received for %v", r.timeout)
+	}
+
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+
+	return n, err
+}
@@ -0,0 +1,202 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	pb "gopkg.in/cheggaaa/pb.v1"
+)
+
+// Styles of the progress of downloads on standard output, see SetProgressStyle.
+const (
+	ProgressAuto  = "auto"
+	ProgressBar   = "bar"
+	ProgressLines = "lines"
+	ProgressNone  = "none"
+)
+
+// progressLineInterval is how often downloads log their progress in the ProgressLines style.
+const progressLineInterval = 10 * time.Second
+
+var progressStyle = ProgressAuto
+
+// SetProgressStyle sets how downloads with Options.Progress show it: ProgressBar redraws a
+// progress bar, ProgressLines logs a line with the percentage every few seconds, for CI logs and
+// transcripts where redraws end up as garbage, and ProgressNone shows nothing. ProgressAuto, the
+// default, is ProgressBar when standard output is a terminal and ProgressLines otherwise.
+func SetProgressStyle(style string) error {
+	switch style {
+	case "":
+		style = ProgressAuto
+	case ProgressAuto, ProgressBar, ProgressLines, ProgressNone:
+	default:
+		return fmt.Errorf("unknown progress style %q, expected %v, %v, %v or %v", style, ProgressAuto, ProgressBar, ProgressLines, ProgressNone)
+	}
+
+	progressStyle = style
+
+	return nil
+}
+
+// resolvedProgressStyle returns the progress style to use, ProgressAuto resolved.
+func resolvedProgressStyle() string {
+	if progressStyle != ProgressAuto {
+		return progressStyle
+	}
+
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return ProgressBar
+	}
+
+	return ProgressLines
+}
+
+// ProgressFunc receives the progress of a download: the bytes written so far and the total size,
+// -1 if unknown. Once the download completes, it is called a last time with written equal to
+// total. It may be called from several goroutines, but never concurrently.
+type ProgressFunc func(written int64, total int64)
+
+// progress tracks the bytes written by a download, reporting them to the ProgressFunc of the
+// options or, by default, in the progress style.
+type progress struct {
+	mu      sync.Mutex
+	written int64
+	total   int64
+	report  ProgressFunc
+	bar     *pb.ProgressBar
+
+	// Progress logged as lines, when neither report nor bar are set
+	start    time.Time
+	offset   int64
+	lastLine time.Time
+}
+
+// newProgress returns the progress of a download of total bytes (-1 if unknown) resuming from the
+// given offset, or nil if the options don't ask for progress.
+func newProgress(options *Options, offset int64, total int64) *progress {
+	ret := &progress{written: offset, total: total}
+
+	switch {
+	case options.ProgressFunc != nil:
+		ret.report = options.ProgressFunc
+		ret.report(offset, total)
+	case options.Progress && resolvedProgressStyle() == ProgressLines:
+		ret.start = time.Now()
+		ret.offset = offset
+		ret.lastLine = ret.start
+	case options.Progress && resolvedProgressStyle() == ProgressBar:
+		ret.bar = pb.New64(total)
+		if total < 0 {
+			ret.bar = pb.New(0)
+		}
+
+		ret.bar.ShowSpeed = true
+		ret.bar.SetRefreshRate(time.Millisecond * 1000)
+		ret.bar.SetUnits(pb.U_BYTES)
+		ret.bar.Set64(offset)
+		ret.bar.Start()
+	default:
+		return nil
+	}
+
+	return ret
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.written += int64(len(b))
+
+	if p.report != nil {
+		p.report(p.written, p.total)
+	} else if p.bar != nil {
+		p.bar.Add(len(b))
+	} else {
+		p.logLine()
+	}
+
+	return len(b), nil
+}
+
+// set reports absolute progress, for progress computed elsewhere.
+func (p *progress) set(written int64, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.written, p.total = written, total
+
+	if p.report != nil {
+		p.report(written, total)
+	} else if p.bar == nil {
+		p.logLine()
+	} else if total < 0 {
+		p.bar.SetTotal64(0)
+		p.bar.Set64(written)
+	} else {
+		p.bar.SetTotal64(total)
+		p.bar.Set64(written)
+	}
+}
+
+// writer returns a writer to w that also reports progress.
+func (p *progress) writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+
+	return io.MultiWriter(w, p)
+}
+
+// finish ends the progress, reporting the final size if the download completed.
+func (p *progress) finish(complete bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.bar != nil {
+		p.bar.Finish()
+	} else if p.report != nil && complete {
+		p.report(p.written, p.written)
+	} else if p.report == nil && complete {
+		log.Printf("Downloaded %.1f MB in %v", float64(p.written-p.offset)/1024/1024, time.Since(p.start).Round(time.Second))
+	}
+}
+
+// logLine logs the progress so far, unless the previous line is too recent.
+func (p *progress) logLine() {
+	now := time.Now()
+	if now.Sub(p.lastLine) < progressLineInterval {
+		return
+	}
+	p.lastLine = now
+
+	rate := float64(p.written-p.offset) / now.Sub(p.start).Seconds() / 1024 / 1024
+	if p.total > 0 {
+		log.Printf("Downloading: %d%% of %.1f MB (%.1f MB/s)", p.written*100/p.total, float64(p.total)/1024/1024, rate)
+	} else {
+		log.Printf("Downloading: %.1f MB (%.1f MB/s)", float64(p.written)/1024/1024, rate)
+	}
+}
@@ -0,0 +1,236 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// ProxySettings configures the proxy HTTP requests go through. With no URL, the proxy is taken
+// from the HTTP_PROXY, HTTPS_PROXY, ALL_PROXY and NO_PROXY environment variables, or from the
+// Windows system settings (WinHTTP, then Internet Options) when those are not set.
+type ProxySettings struct {
+	URL      string // Optional, e.g. "http://proxy.example.com:8080" or "socks5://127.0.0.1:1080"
+	NoProxy  string // Optional, comma-separated hosts reached directly, wildcards allowed
+	Auth     string // "basic" (the default) or "ntlm"
+	Username string // Optional, "DOMAIN\user" for NTLM
+	Password string // Optional
+}
+
+// SetProxy makes all future requests go through the proxy described by the given settings.
+func SetProxy(settings ProxySettings) error {
+	var configured *url.URL
+	if settings.URL != "" {
+		u, err := parseProxyURL(settings.URL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL %v: %v", settings.URL, err)
+		}
+
+		configured = u
+	}
+
+	resolve := func(target *url.URL) (*url.URL, error) {
+		if configured == nil {
+			return systemOrEnvironmentProxy(target)
+		}
+
+		if bypassProxy(target.Hostname(), strings.Split(settings.NoProxy, ",")) {
+			return nil, nil
+		}
+
+		return configured, nil
+	}
+
+	withCredentials := func(proxy *url.URL) *url.URL {
+		if proxy == nil || settings.Username == "" {
+			return proxy
+		}
+
+		ret := *proxy
+		ret.User = url.UserPassword(settings.Username, settings.Password)
+		return &ret
+	}
+
+	switch strings.ToLower(settings.Auth) {
+	case "", "basic":
+		// Go sends the credentials of the proxy URL with both plain requests and CONNECT tunnels
+		Transport.Proxy = func(request *http.Request) (*url.URL, error) {
+			proxy, err := resolve(request.URL)
+			return withCredentials(proxy), err
+		}
+		dial = dialer.DialContext
+	case "ntlm":
+		if settings.Username == "" {
+			return fmt.Errorf("NTLM proxy authentication requires a user name")
+		}
+
+		// NTLM authenticates connections rather than requests, so every request goes through a
+		// CONNECT tunnel that we authenticate before handing it to the transport
+		Transport.Proxy = nil
+		dial = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			proxy, err := resolve(&url.URL{Scheme: "https", Host: addr})
+			if err != nil {
+				return nil, err
+			} else if proxy == nil {
+				return dialer.DialContext(ctx, network, addr)
+			}
+
+			if proxy.Scheme == "socks5" {
+				return nil, fmt.Errorf("NTLM authentication is not supported by SOCKS proxies like %v", proxy.Host)
+			}
+
+			return dialNTLMTunnel(ctx, proxy, addr, settings.Username, settings.Password)
+		}
+	default:
+		return fmt.Errorf("unknown proxy authentication %q, expected basic or ntlm", settings.Auth)
+	}
+
+	resetTransports()
+
+	return nil
+}
+
+// systemOrEnvironmentProxy returns the proxy for the given URL from the environment variables if
+// they are set, from the Windows system settings otherwise.
+func systemOrEnvironmentProxy(target *url.URL) (*url.URL, error) {
+	if bypassProxy(target.Hostname(), nil) {
+		return nil, nil
+	}
+
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		if os.Getenv(name) != "" {
+			return http.ProxyFromEnvironment(&http.Request{URL: target})
+		}
+	}
+
+	// ALL_PROXY is commonly used for SOCKS proxies, like the ones of SSH tunnels
+	for _, name := range []string{"ALL_PROXY", "all_proxy"} {
+		if proxy := os.Getenv(name); proxy != "" {
+			if bypassProxy(target.Hostname(), strings.Split(noProxyEnv(), ",")) {
+				return nil, nil
+			}
+
+			return parseProxyURL(proxy)
+		}
+	}
+
+	server, bypass := systemProxy()
+	if server == "" || bypassProxy(target.Hostname(), strings.Split(bypass, ";")) {
+		return nil, nil
+	}
+
+	proxy := proxyForScheme(server, target.Scheme)
+	if proxy == "" {
+		return nil, nil
+	}
+
+	return parseProxyURL(proxy)
+}
+
+// noProxyEnv returns the value of the NO_PROXY environment variable.
+func noProxyEnv() string {
+	if ret := os.Getenv("NO_PROXY"); ret != "" {
+		return ret
+	}
+
+	return os.Getenv("no_proxy")
+}
+
+// proxyForScheme picks the proxy for the given scheme from a Windows proxy list, which is either a
+// single "host:port" or a list like "http=host:port;https=host:port".
+func proxyForScheme(server string, scheme string) string {
+	fallback := ""
+
+	for _, entry := range strings.FieldsFunc(server, func(r rune) bool { return r == ';' || r == ' ' }) {
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			fallback = entry
+			continue
+		}
+
+		if strings.EqualFold(entry[:i], scheme) {
+			return entry[i+1:]
+		}
+	}
+
+	return fallback
+}
+
+// parseProxyURL parses a proxy address, which may lack the scheme. Go always lets SOCKS proxies
+// resolve host names, so "socks5h" is the same as "socks5".
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	ret, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ret.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		ret.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", ret.Scheme)
+	}
+
+	return ret, nil
+}
+
+// bypassProxy returns whether the given host is reached directly, according to a list of host
+// patterns as found in proxy settings. Loopback and private addresses, like the ones of peers, are
+// never proxied. "<local>" matches host names without dots.
+func bypassProxy(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+
+	if host == "localhost" {
+		return true
+	} else if ip := net.ParseIP(host); ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()) {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+		switch {
+		case pattern == "":
+			continue
+		case pattern == "<local>":
+			if !strings.Contains(host, ".") {
+				return true
+			}
+		case strings.HasPrefix(pattern, "."):
+			if strings.HasSuffix(host, pattern) || host == pattern[1:] {
+				return true
+			}
+		default:
+			if ok, _ := path.Match(pattern, host); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
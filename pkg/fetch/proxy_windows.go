@@ -0,0 +1,86 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// systemProxy returns the proxy list and the bypass list of the Windows system settings: the
+// WinHTTP ones used by services (set with "netsh winhttp set proxy"), or the Internet Options of
+// the current user. Automatic configuration scripts are not supported.
+func systemProxy() (string, string) {
+	if server, bypass := winHTTPProxy(); server != "" {
+		return server, bypass
+	}
+
+	key, err := registry.OpenKey(registry.CURRENT_USER, `Software\Microsoft\Windows\CurrentVersion\Internet Settings`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", ""
+	}
+	defer key.Close()
+
+	if enabled, _, err := key.GetIntegerValue("ProxyEnable"); err != nil || enabled == 0 {
+		return "", ""
+	}
+
+	server, _, _ := key.GetStringValue("ProxyServer")
+	bypass, _, _ := key.GetStringValue("ProxyOverride")
+
+	return server, bypass
+}
+
+// winHTTPProxy decodes the WinHttpSettings value, which starts with its size, a counter and the
+// access type, followed by the proxy and bypass lists as length-prefixed ANSI strings.
+func winHTTPProxy() (string, string) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows\CurrentVersion\Internet Settings\Connections`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", ""
+	}
+	defer key.Close()
+
+	data, _, err := key.GetBinaryValue("WinHttpSettings")
+	if err != nil || len(data) < 16 {
+		return "", ""
+	}
+
+	const namedProxy = 3
+	if binary.LittleEndian.Uint32(data[8:]) != namedProxy {
+		return "", ""
+	}
+
+	readString := func(offset int) (string, int) {
+		if offset+4 > len(data) {
+			return "", len(data)
+		}
+
+		length := int(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+		if offset+length > len(data) {
+			return "", len(data)
+		}
+
+		return string(data[offset : offset+length]), offset + length
+	}
+
+	server, next := readString(12)
+	bypass, _ := readString(next)
+
+	return strings.TrimSpace(server), strings.TrimSpace(bypass)
+}
@@ -0,0 +1,101 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseRate parses a transfer rate in bytes per second, with an optional "k", "m" or "g" suffix for
+// multiples of 1024 (e.g. "500k" or "2M").
+func ParseRate(s string) (int64, error) {
+	number := strings.ToLower(s)
+	multiplier := int64(1)
+
+	if i := len(number) - 1; i > 0 {
+		switch number[i] {
+		case 'k':
+			number, multiplier = number[:i], 1<<10
+		case 'm':
+			number, multiplier = number[:i], 1<<20
+		case 'g':
+			number, multiplier = number[:i], 1<<30
+		}
+	}
+
+	ret, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || ret <= 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected a positive number of bytes per second like 500k or 2M", s)
+	}
+
+	return ret * multiplier, nil
+}
+
+// rateLimiter paces readers sharing it so that, together, they don't exceed the given rate.
+type rateLimiter struct {
+	mu    sync.Mutex
+	rate  int64
+	start time.Time
+	total int64
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{rate: bytesPerSecond, start: time.Now()}
+}
+
+// wait accounts for n more bytes, sleeping until they are within the rate.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total += int64(n)
+
+	due := time.Duration(float64(l.total) / float64(l.rate) * float64(time.Second))
+	if elapsed := time.Since(l.start); due > elapsed {
+		time.Sleep(due - elapsed)
+	}
+}
+
+// reader returns a reader limited by the rate limiter. A nil limiter doesn't limit anything.
+func (l *rateLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+
+	return &limitedReader{reader: r, limiter: l}
+}
+
+type limitedReader struct {
+	reader  io.Reader
+	limiter *rateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// Small reads keep the pace smooth, instead of sleeping for seconds after each large one
+	if max := r.limiter.rate / 10; int64(len(p)) > max && max > 0 {
+		p = p[:max]
+	}
+
+	n, err := r.reader.Read(p)
+	r.limiter.wait(n)
+
+	return n, err
+}
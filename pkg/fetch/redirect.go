@@ -0,0 +1,69 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultMaxRedirects is how many redirects downloads follow, unless configured otherwise.
+const DefaultMaxRedirects = 10
+
+// redirectError is the refusal of a redirect by the policy of the options, which retrying doesn't
+// change.
+type redirectError struct {
+	error
+}
+
+// checkRedirect returns the redirect policy of the given options, for http.Client.CheckRedirect.
+func checkRedirect(options *Options) func(*http.Request, []*http.Request) error {
+	return func(request *http.Request, via []*http.Request) error {
+		max := options.MaxRedirects
+		if max == 0 {
+			max = DefaultMaxRedirects
+		} else if max < 0 {
+			max = 0
+		}
+
+		previous := via[len(via)-1]
+
+		switch {
+		case len(via) > max:
+			return redirectError{fmt.Errorf("stopped after %d redirects", max)}
+		case previous.URL.Scheme == "https" && request.URL.Scheme == "http" && !options.AllowInsecureRedirects:
+			return redirectError{fmt.Errorf("refusing the redirect from %v to %v, which downgrades HTTPS to plain HTTP", previous.URL, request.URL)}
+		case options.SameHostRedirects && !strings.EqualFold(request.URL.Hostname(), via[0].URL.Hostname()):
+			return redirectError{fmt.Errorf("refusing the redirect from %v to another host: %v", via[0].URL.Hostname(), request.URL)}
+		}
+
+		return nil
+	}
+}
+
+// connectionError returns the error of a failed attempt to send the given request, which is
+// transient unless a redirect was refused.
+func connectionError(request *http.Request, err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		if refused, ok := urlErr.Err.(redirectError); ok {
+			return redirectError{fmt.Errorf("cannot download %v: %v", request.URL, refused.error)}
+		}
+	}
+
+	return transientError{fmt.Errorf("cannot open a connection to %v: %v", request.URL, err)}
+}
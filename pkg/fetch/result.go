@@ -0,0 +1,115 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+)
+
+// FetchResult describes how a resource was fetched, for callers that log where their files come
+// from or record their checksum.
+type FetchResult struct {
+	// URL is the one the file was fetched from, after following redirects.
+	URL string
+
+	// Redirects are the URLs HTTP downloads were redirected from, in order, starting with the
+	// resource itself. It is empty when there were no redirects.
+	Redirects []string
+
+	// Header is the header of the last HTTP response, nil for other resources and for downloads
+	// through BITS.
+	Header http.Header
+
+	// Size is the size of the file in bytes.
+	Size int64
+
+	// SHA256 is the hexadecimal SHA-256 hash of the file.
+	SHA256 string
+
+	// Duration is how long fetching the resource took.
+	Duration time.Duration
+}
+
+// FetchWithResult is Fetch, also returning the details of the download.
+func FetchWithResult(resource string, options *Options) (string, *FetchResult, error) {
+	return FetchWithResultContext(context.Background(), resource, options)
+}
+
+// FetchWithResultContext is FetchWithResult, aborted when the given context is done like
+// FetchContext.
+func FetchWithResultContext(ctx context.Context, resource string, options *Options) (string, *FetchResult, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	result := &FetchResult{URL: resource}
+
+	withResult := *options
+	withResult.result = result
+
+	start := time.Now()
+	path, err := FetchContext(ctx, resource, &withResult)
+	if err != nil {
+		return "", nil, err
+	}
+	result.Duration = time.Since(start)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	result.Size = info.Size()
+
+	if result.SHA256, err = SHA256File(path); err != nil {
+		return "", nil, err
+	}
+
+	return path, result, nil
+}
+
+// FetchAnyWithResultContext is FetchAnyContext, also returning the details of the download of the
+// resource that could be fetched.
+func FetchAnyWithResultContext(ctx context.Context, resources []string, options *Options) (string, *FetchResult, error) {
+	var result *FetchResult
+
+	path, err := fetchAny(ctx, resources, func(resource string) (string, error) {
+		path, ret, err := FetchWithResultContext(ctx, resource, options)
+		result = ret
+		return path, err
+	})
+
+	return path, result, err
+}
+
+// recordResponse records the final URL, the redirects and the header of the given response in the
+// result of the options, if any.
+func (o *Options) recordResponse(response *http.Response) {
+	if o.result == nil {
+		return
+	}
+
+	o.result.URL = response.Request.URL.String()
+	o.result.Header = response.Header
+	o.result.Redirects = nil
+
+	// Each redirected request links to the response that caused it
+	for r := response.Request.Response; r != nil; r = r.Request.Response {
+		o.result.Redirects = append([]string{r.Request.URL.String()}, o.result.Redirects...)
+	}
+}
@@ -0,0 +1,77 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// cacheValidators identify the version of a downloaded file, so that the server can be asked
+// whether it changed since.
+type cacheValidators struct {
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+}
+
+// validatorsPath returns the path of the file storing the validators of a downloaded file.
+func validatorsPath(dest string) string {
+	return dest + ".validators"
+}
+
+// saveValidators stores the validators of the response a file was downloaded from.
+func saveValidators(dest string, header http.Header) {
+	validators := cacheValidators{ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}
+	if validators == (cacheValidators{}) {
+		os.Remove(validatorsPath(dest))
+		return
+	}
+
+	data, err := json.Marshal(validators)
+	if err != nil {
+		return
+	}
+
+	ioutil.WriteFile(validatorsPath(dest), data, 0644)
+}
+
+// setConditional makes the request conditional on the resource having changed since the existing
+// file at dest was downloaded, and returns whether there is such a file. Files downloaded without
+// validators, or before they were stored, are compared by modification time.
+func setConditional(request *http.Request, dest string) bool {
+	info, err := os.Stat(dest)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	var validators cacheValidators
+	if data, err := ioutil.ReadFile(validatorsPath(dest)); err == nil {
+		json.Unmarshal(data, &validators)
+	}
+
+	if validators.ETag != "" {
+		request.Header.Set("If-None-Match", validators.ETag)
+	}
+
+	if validators.LastModified == "" {
+		validators.LastModified = info.ModTime().UTC().Format(http.TimeFormat)
+	}
+	request.Header.Set("If-Modified-Since", validators.LastModified)
+
+	return true
+}
@@ -0,0 +1,238 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials are the credentials used to sign S3 requests.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// fetchS3 downloads an object from an "s3://bucket/key" URL. Requests are signed with the
+// credentials of the object storage settings, the standard AWS environment variables or the shared
+// credentials file, and are sent anonymously when none is available (i.e. for public buckets).
+func fetchS3(ctx context.Context, u *url.URL, options *Options) (string, error) {
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", fmt.Errorf("malformed S3 URL %v, wanted s3://bucket/key", u)
+	}
+
+	region := awsRegion()
+	endpoint := &url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%v.s3.%v.amazonaws.com", bucket, region),
+		Path:   "/" + key,
+	}
+
+	// S3-compatible services, like MinIO, rarely have a DNS name per bucket
+	if objectStorage.S3Endpoint != "" {
+		custom, err := url.Parse(objectStorage.S3Endpoint)
+		if err != nil || custom.Host == "" {
+			return "", fmt.Errorf("invalid S3 endpoint %v", objectStorage.S3Endpoint)
+		}
+
+		endpoint = &url.URL{
+			Scheme: custom.Scheme,
+			Host:   custom.Host,
+			Path:   strings.TrimSuffix(custom.Path, "/") + "/" + bucket + "/" + key,
+		}
+	}
+
+	request, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	request = request.WithContext(ctx)
+
+	if credentials, ok := awsLoadCredentials(); ok {
+		signS3Request(request, credentials, region, time.Now().UTC())
+	}
+
+	return download(request, options)
+}
+
+// awsRegion returns the AWS region from the settings or the environment, defaulting to
+// "us-east-1".
+func awsRegion() string {
+	if region := settingOrEnv(objectStorage.S3Region, "AWS_REGION", "AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+
+	return "us-east-1"
+}
+
+// awsLoadCredentials looks up AWS credentials, first in the settings, then in the environment and
+// then in the profile of the shared credentials file (AWS_PROFILE, or "default", if the settings
+// don't name one).
+func awsLoadCredentials() (awsCredentials, bool) {
+	ret := awsCredentials{
+		AccessKeyID:     objectStorage.S3AccessKeyID,
+		SecretAccessKey: objectStorage.S3SecretAccessKey,
+		SessionToken:    objectStorage.S3SessionToken,
+	}
+	if ret.AccessKeyID != "" && ret.SecretAccessKey != "" {
+		return ret, true
+	}
+
+	ret = awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if ret.AccessKeyID != "" && ret.SecretAccessKey != "" {
+		return ret, true
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, false
+		}
+
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	profile := settingOrEnv(objectStorage.S3Profile, "AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return awsCredentials{}, false
+	}
+	defer f.Close()
+
+	ret = awsCredentials{}
+	inProfile := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+			continue
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		if !inProfile || len(split) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(split[1])
+		switch strings.TrimSpace(split[0]) {
+		case "aws_access_key_id":
+			ret.AccessKeyID = value
+		case "aws_secret_access_key":
+			ret.SecretAccessKey = value
+		case "aws_session_token":
+			ret.SessionToken = value
+		}
+	}
+
+	return ret, ret.AccessKeyID != "" && ret.SecretAccessKey != ""
+}
+
+// signS3Request signs a request with AWS Signature Version 4. The payload is not signed since we
+// only ever send GET requests without a body.
+func signS3Request(request *http.Request, credentials awsCredentials, region string, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/s3/aws4_request"
+
+	request.Header.Set("Host", request.URL.Host)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	request.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	var headerNames []string
+	for name := range request.Header {
+		headerNames = append(headerNames, strings.ToLower(name))
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(request.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(sha256Sum([]byte(canonicalRequest))),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Del("Host")
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+
+	return sum[:]
+}
@@ -0,0 +1,105 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fetcher fetches the resources of a URL scheme and returns the path of the local file containing
+// them, which should be options.Destination (or a file in it, when it is a directory).
+type Fetcher interface {
+	Fetch(ctx context.Context, u *url.URL, options *Options) (string, error)
+}
+
+// FetcherFunc is a function used as a Fetcher.
+type FetcherFunc func(ctx context.Context, u *url.URL, options *Options) (string, error)
+
+// Fetch calls f(ctx, u, options).
+func (f FetcherFunc) Fetch(ctx context.Context, u *url.URL, options *Options) (string, error) {
+	return f(ctx, u, options)
+}
+
+var (
+	schemes = map[string]Fetcher{
+		"azblob": FetcherFunc(fetchAzureBlob),
+		"file":   FetcherFunc(fetchFileURL),
+		"http":   FetcherFunc(fetchHTTPURL),
+		"https":  FetcherFunc(fetchHTTPURL),
+		"s3":     FetcherFunc(fetchS3),
+		"smb":    FetcherFunc(fetchSMB),
+	}
+	schemesMutex sync.RWMutex
+)
+
+// RegisterScheme makes Fetch use the given Fetcher for the URLs with the given scheme, e.g. to
+// plug in an internal artifact store or an IPFS gateway. It replaces the Fetcher of the scheme, if
+// any, including the built-in ones. Like the built-in ones other than "file" and "smb", custom
+// schemes are looked up in the download cache instead when offline.
+func RegisterScheme(scheme string, f Fetcher) {
+	if f == nil {
+		panic("fetch: nil Fetcher for scheme " + scheme)
+	}
+
+	schemesMutex.Lock()
+	defer schemesMutex.Unlock()
+
+	schemes[strings.ToLower(scheme)] = f
+}
+
+// Schemes returns the URL schemes that Fetch supports, sorted.
+func Schemes() []string {
+	schemesMutex.RLock()
+	defer schemesMutex.RUnlock()
+
+	var ret []string
+	for scheme := range schemes {
+		ret = append(ret, scheme)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// schemeFetcher returns the Fetcher of the given URL scheme, if any.
+func schemeFetcher(scheme string) (Fetcher, bool) {
+	schemesMutex.RLock()
+	defer schemesMutex.RUnlock()
+
+	f, ok := schemes[strings.ToLower(scheme)]
+	return f, ok
+}
+
+// fetchFileURL fetches a file:// URL: local files are returned as-is and files on network shares
+// are copied.
+func fetchFileURL(ctx context.Context, u *url.URL, options *Options) (string, error) {
+	path, unc := fileURLPath(u)
+	if unc {
+		return fetchFile(ctx, path, options)
+	}
+
+	options.localFile()
+	return path, nil
+}
+
+// fetchHTTPURL fetches an http:// or https:// URL.
+func fetchHTTPURL(ctx context.Context, u *url.URL, options *Options) (string, error) {
+	return fetchHTTP(ctx, u.String(), options)
+}
@@ -0,0 +1,251 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// minSegmentSize is the smallest amount of data worth its own connection.
+const minSegmentSize = 1 << 20
+
+// errNotSegmentable is returned by downloadSegmented when the resource has to be downloaded with a
+// single connection, because the server doesn't support ranges or the file is too small.
+var errNotSegmentable = errors.New("not segmentable")
+
+// downloadSegmented downloads the resource of the given request with options.Connections
+// concurrent Range requests, each writing its own part of the destination file.
+func downloadSegmented(request *http.Request, options *Options) (string, error) {
+	dest, err := destinationPath(request.URL.Path, options)
+	if err != nil {
+		return "", err
+	}
+
+	// Leave the leftover of an interrupted single-connection download to be resumed
+	tempDest := dest + ".download"
+	if _, err := os.Stat(tempDest + ".validator"); err == nil {
+		return "", errNotSegmentable
+	}
+
+	size, header, err := probeRanges(request, options)
+	if err != nil {
+		return "", err
+	}
+	validator := responseValidator(&http.Response{Header: header})
+
+	connections := int64(options.Connections)
+	if size/connections < minSegmentSize {
+		connections = size / minSegmentSize
+	}
+	if connections < 2 {
+		return "", errNotSegmentable
+	}
+
+	if err := CheckDiskSpace(tempDest, size); err != nil {
+		return "", err
+	}
+
+	destination, err := os.Create(tempDest)
+	if err != nil {
+		return "", fmt.Errorf("cannot create %v: %v", tempDest, err)
+	}
+	defer destination.Close()
+
+	// Parts are not tracked, so nothing can be resumed from a failed segmented download
+	complete := false
+	defer func() {
+		if !complete {
+			destination.Close()
+			os.Remove(tempDest)
+		}
+	}()
+
+	if err := destination.Truncate(size); err != nil {
+		return "", fmt.Errorf("cannot allocate %v: %v", tempDest, err)
+	}
+
+	progress := newProgress(options, 0, size)
+	defer func() { progress.finish(complete) }()
+
+	// A transport of its own, allowing a connection per segment and no HTTP/2 multiplexing
+	client := newDownloadClient(options, int(connections))
+
+	var limiter *rateLimiter
+	if options.MaxBytesPerSecond > 0 {
+		limiter = newRateLimiter(options.MaxBytesPerSecond)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, connections)
+	segmentSize := size / connections
+
+	for i := int64(0); i < connections; i++ {
+		start := i * segmentSize
+		end := start + segmentSize - 1
+		if i == connections-1 {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func(i, start, end int64) {
+			defer wg.Done()
+			errs[i] = downloadSegment(client, options, request, validator, destination, start, end, progress, limiter)
+		}(i, start, end)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if name := responseFileName(&http.Response{Header: header}); name != "" && destinationIsDir(options) {
+		dest = filepath.Join(options.Destination, name)
+	}
+
+	if err := commitFile(destination, dest); err != nil {
+		return "", err
+	}
+
+	complete = true
+	options.transferred(size)
+	saveValidators(dest, header)
+	options.recordResponse(&http.Response{Header: header, Request: request})
+
+	return dest, nil
+}
+
+// probeRanges asks for the first byte of the resource to learn its size and whether the server
+// supports Range requests, returning errNotSegmentable if it doesn't. The headers of the response
+// carry the validator that lets segment requests detect that the resource changed in the meantime.
+func probeRanges(request *http.Request, options *Options) (int64, http.Header, error) {
+	probe := request.Clone(request.Context())
+	probe.Header.Set("Range", "bytes=0-0")
+
+	response, err := newDownloadClient(options, 1).Do(probe)
+	if err != nil {
+		return 0, nil, connectionError(request, err)
+	}
+	defer response.Body.Close()
+
+	var start, end, size int64
+	if response.StatusCode != http.StatusPartialContent {
+		return 0, nil, errNotSegmentable
+	}
+	if _, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size); err != nil || size <= 0 {
+		return 0, nil, errNotSegmentable
+	}
+
+	if responseValidator(response) == "" {
+		return 0, nil, errNotSegmentable
+	}
+
+	return size, response.Header, nil
+}
+
+// FetchRange downloads the given byte range of an HTTP resource, end included, with the headers,
+// pins and redirect policy of the given options. The server must support Range requests.
+func FetchRange(rawurl string, start int64, end int64, options *Options) ([]byte, error) {
+	if offline {
+		return nil, &ErrOffline{Resource: rawurl}
+	}
+
+	request, err := NewRequest(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(request, options)
+	request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	response, err := newDownloadClient(options, 1).Do(request)
+	if err != nil {
+		return nil, connectionError(request, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("%v doesn't support Range requests", request.URL.Host)
+	} else if response.StatusCode != http.StatusPartialContent {
+		return nil, HTTPStatusError{URL: rawurl, StatusCode: response.StatusCode}
+	} else if contentRangeStart(response) != start {
+		return nil, fmt.Errorf("%v answered with the wrong range: %v", rawurl, response.Header.Get("Content-Range"))
+	}
+
+	body, stopWatchdog := watchIdle(response.Body, options)
+	defer stopWatchdog()
+
+	ret := make([]byte, end-start+1)
+	if n, err := io.ReadFull(body, ret); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrTruncatedDownload{URL: rawurl, Expected: int64(len(ret)), Received: int64(n)}
+	} else if err != nil {
+		return nil, fmt.Errorf("error downloading %v: %v", rawurl, err)
+	}
+
+	return ret, nil
+}
+
+// downloadSegment downloads the given byte range of the resource into the same range of the
+// destination file.
+func downloadSegment(client *http.Client, options *Options, request *http.Request, validator string, destination *os.File, start, end int64, progress *progress, limiter *rateLimiter) error {
+	segment := request.Clone(request.Context())
+	segment.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	segment.Header.Set("If-Range", validator)
+
+	response, err := client.Do(segment)
+	if err != nil {
+		return connectionError(request, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusPartialContent || contentRangeStart(response) != start {
+		return transientError{fmt.Errorf("%v changed while downloading it (HTTP response code %d)", request.URL, response.StatusCode)}
+	}
+
+	body, stopWatchdog := watchIdle(response.Body, options)
+	defer stopWatchdog()
+
+	writer := progress.writer(&sectionWriter{file: destination, offset: start})
+
+	length := end - start + 1
+	if n, err := io.CopyN(writer, limiter.reader(body), length); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return transientError{ErrTruncatedDownload{URL: request.URL.String(), Expected: length, Received: n}}
+	} else if err != nil {
+		return transientError{fmt.Errorf("error downloading %v: %v after %d of %d bytes", request.URL, err, n, length)}
+	}
+
+	return nil
+}
+
+// sectionWriter writes sequentially to a file from the given offset, without moving the file
+// offset shared by other writers.
+type sectionWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (w *sectionWriter) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,33 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// connectShare connects to a network share with the given credentials, for the current logon
+// session only.
+func connectShare(share string, username string, password string) error {
+	output, err := exec.Command("net", "use", share, password, "/user:"+username, "/persistent:no").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
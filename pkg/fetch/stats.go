@@ -0,0 +1,88 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"sync"
+	"time"
+)
+
+// Stat records a completed fetch, see Stats.
+type Stat struct {
+	Resource string
+	Bytes    int64         // Transferred, including the ones of failed attempts, 0 for cache hits
+	Duration time.Duration // Including retries and waiting for other processes
+	CacheHit bool          // Nothing had to be transferred, the local copy was up to date
+
+	local bool // The resource is a local file, returned as-is and left out of Stats
+}
+
+// Speed returns the average transfer rate of the fetch in bytes per second.
+func (s Stat) Speed() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+
+	return float64(s.Bytes) / s.Duration.Seconds()
+}
+
+var (
+	stats   []Stat
+	statsMu sync.Mutex
+)
+
+// Stats returns the fetches completed so far by this process, in the order they completed.
+// Resources that are local files, returned as-is, are left out.
+func Stats() []Stat {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	return append([]Stat(nil), stats...)
+}
+
+// RecordCacheHit records a cache hit for the given resource in Stats, for callers that reuse a
+// file without fetching it.
+func RecordCacheHit(resource string) {
+	recordStat(Stat{Resource: resource, CacheHit: true})
+}
+
+func recordStat(stat Stat) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	stats = append(stats, stat)
+}
+
+// transferred adds the given number of bytes to the statistics of the fetch, if recorded.
+func (o *Options) transferred(n int64) {
+	if o.stat != nil && n > 0 {
+		o.stat.Bytes += n
+	}
+}
+
+// localFile marks the fetch as one of a local file, left out of the statistics.
+func (o *Options) localFile() {
+	if o.stat != nil {
+		o.stat.local = true
+	}
+}
+
+// upToDate marks the fetch as a cache hit in its statistics, if recorded.
+func (o *Options) upToDate() {
+	if o.stat != nil {
+		o.stat.CacheHit = true
+	}
+}
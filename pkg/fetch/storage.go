@@ -0,0 +1,54 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import "os"
+
+// ObjectStorageSettings configures the s3:// and azblob:// schemes. Settings left empty are taken
+// from the standard environment variables of each service.
+type ObjectStorageSettings struct {
+	S3AccessKeyID     string // Optional, AWS_ACCESS_KEY_ID otherwise
+	S3SecretAccessKey string // Optional, AWS_SECRET_ACCESS_KEY otherwise
+	S3SessionToken    string // Optional, AWS_SESSION_TOKEN otherwise
+	S3Profile         string // Optional, profile of the shared credentials file, AWS_PROFILE otherwise
+	S3Region          string // Optional, AWS_REGION or AWS_DEFAULT_REGION otherwise
+	S3Endpoint        string // Optional, URL of an S3-compatible service addressed with path-style requests
+	AzureAccount      string // Optional, AZURE_STORAGE_ACCOUNT otherwise
+	AzureSASToken     string // Optional, AZURE_STORAGE_SAS_TOKEN otherwise
+}
+
+var objectStorage ObjectStorageSettings
+
+// SetObjectStorage makes future downloads of s3:// and azblob:// URLs use the given settings.
+func SetObjectStorage(settings ObjectStorageSettings) {
+	objectStorage = settings
+}
+
+// settingOrEnv returns the given setting, or the value of the first of the given environment
+// variables that is set if it is empty.
+func settingOrEnv(setting string, names ...string) string {
+	if setting != "" {
+		return setting
+	}
+
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,143 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// extraRoots holds the PEM certificates of the certificate authorities trusted in addition to the
+// system ones, like the ones of TLS-intercepting proxies (see SetCABundle).
+var extraRoots []byte
+
+// SetCABundle makes future requests trust the certificate authorities of the given PEM file, in
+// addition to the ones of the system.
+func SetCABundle(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if !x509.NewCertPool().AppendCertsFromPEM(data) {
+		return fmt.Errorf("no PEM certificate found in %v", path)
+	}
+
+	extraRoots = data
+	Transport.TLSClientConfig = tlsConfig(nil)
+	resetTransports()
+
+	return nil
+}
+
+// tlsConfig returns a TLS configuration that trusts the system certificate authorities and the
+// extra ones, and requires one of the certificates of the verified chain to match one of the given
+// pins, if any.
+func tlsConfig(pins []string) *tls.Config {
+	ret := &tls.Config{
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return checkPins(state, state.VerifiedChains, pins)
+		},
+	}
+
+	if extraRoots == nil {
+		return ret
+	}
+
+	if roots, err := x509.SystemCertPool(); err == nil {
+		roots.AppendCertsFromPEM(extraRoots)
+		ret.RootCAs = roots
+		return ret
+	}
+
+	// The system roots cannot be loaded into a pool on Windows before Go 1.18, verify against them
+	// and then against the extra ones instead
+	ret.InsecureSkipVerify = true
+	ret.VerifyConnection = func(state tls.ConnectionState) error {
+		chains, err := verifyWithExtraRoots(state)
+		if err != nil {
+			return err
+		}
+
+		return checkPins(state, chains, pins)
+	}
+
+	return ret
+}
+
+// verifyWithExtraRoots verifies the certificate of the server against the system certificate
+// authorities, then against the extra ones, and returns the verified chains.
+func verifyWithExtraRoots(state tls.ConnectionState) ([][]*x509.Certificate, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("the server sent no certificate")
+	} else if state.ServerName == "" {
+		return nil, errors.New("cannot verify servers addressed by IP with a CA bundle")
+	}
+
+	options := x509.VerifyOptions{DNSName: state.ServerName, Intermediates: x509.NewCertPool()}
+	for _, cert := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(cert)
+	}
+
+	chains, err := state.PeerCertificates[0].Verify(options)
+	if err == nil {
+		return chains, nil
+	}
+
+	options.Roots = x509.NewCertPool()
+	options.Roots.AppendCertsFromPEM(extraRoots)
+
+	return state.PeerCertificates[0].Verify(options)
+}
+
+// checkPins returns an error unless one of the certificates of the given chains matches one of the
+// given pins. Any chain is fine when there are no pins.
+func checkPins(state tls.ConnectionState, chains [][]*x509.Certificate, pins []string) error {
+	if len(pins) == 0 {
+		return nil
+	}
+
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if matchesPin(cert, pins) {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("the certificate of the server doesn't match any of the pinned keys")
+}
+
+// matchesPin returns whether the public key of the certificate has one of the given SHA-256 hashes,
+// in base64 and optionally prefixed with "sha256/".
+func matchesPin(cert *x509.Certificate, pins []string) bool {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	encoded := base64.StdEncoding.EncodeToString(hash[:])
+
+	for _, pin := range pins {
+		if strings.TrimPrefix(strings.TrimSpace(pin), "sha256/") == encoded {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,75 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package installer
+
+import (
+	"strings"
+)
+
+// AppxCommand returns the command that installs the MSIX or AppX package at the given path, along
+// with the given dependency packages (like the VCLibs framework) and license file, which may be
+// empty. Packages are installed for the current user, except in the machine scope where they are
+// provisioned for all users, which is the only case the license file is used in.
+func AppxCommand(path string, dependencies []string, license string, scope Scope) []string {
+	var quoted []string
+	for _, dependency := range dependencies {
+		quoted = append(quoted, powerShellQuote(dependency))
+	}
+
+	if scope == MachineScope {
+		script := "Add-AppxProvisionedPackage -Online -PackagePath " + powerShellQuote(path)
+		if len(quoted) > 0 {
+			script += " -DependencyPackagePath " + strings.Join(quoted, ",")
+		}
+
+		if license != "" {
+			script += " -LicensePath " + powerShellQuote(license)
+		} else {
+			script += " -SkipLicense"
+		}
+
+		return powerShell(script)
+	}
+
+	script := "Add-AppxPackage -Path " + powerShellQuote(path)
+	if len(quoted) > 0 {
+		script += " -DependencyPath " + strings.Join(quoted, ",")
+	}
+
+	return powerShell(script + " -ForceApplicationShutdown")
+}
+
+// AppxUninstallCommand returns the command that removes the MSIX or AppX package with the given
+// name (e.g. "Microsoft.WindowsTerminal"), installed for the given scope.
+func AppxUninstallCommand(name string, scope Scope) []string {
+	if scope == MachineScope {
+		return powerShell("Get-AppxProvisionedPackage -Online | Where-Object DisplayName -eq " + powerShellQuote(name) + " | Remove-AppxProvisionedPackage -Online; " +
+			"Get-AppxPackage -AllUsers -Name " + powerShellQuote(name) + " | Remove-AppxPackage -AllUsers")
+	}
+
+	return powerShell("Get-AppxPackage -Name " + powerShellQuote(name) + " | Remove-AppxPackage")
+}
+
+// powerShell returns the command that runs the given PowerShell script, stopping at the first
+// error so that failures are reported through the exit code.
+func powerShell(script string) []string {
+	return []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", "$ErrorActionPreference = 'Stop'; " + script}
+}
+
+// powerShellQuote quotes the given string as a literal PowerShell string.
+func powerShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -17,14 +17,24 @@ package installer
 
 import (
 	"archive/zip"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // ExtractZIP extracts the given ZIP archive to the given destination directory. If the destination
 // directory does not exist, it is created.
 func ExtractZIP(path string, dest string) error {
+	return ExtractZIPStrip(path, dest, 0)
+}
+
+// ExtractZIPStrip is like ExtractZIP, but removes the given number of leading directories from the
+// names of the files in the archive, like tar's --strip-components. Files that are not deep enough
+// are skipped, and archives with files outside of the destination directory (e.g. ../name) are
+// refused.
+func ExtractZIPStrip(path string, dest string, strip int) error {
 	if err := os.MkdirAll(dest, 0700); err != nil {
 		return err
 	}
@@ -35,8 +45,18 @@ func ExtractZIP(path string, dest string) error {
 	}
 	defer zipReader.Close()
 
+	root := filepath.Clean(dest)
+
 	for _, zipFile := range zipReader.File {
-		destinationPath := filepath.Join(dest, zipFile.Name)
+		components := strings.Split(strings.Trim(zipFile.Name, "/"), "/")
+		if len(components) <= strip {
+			continue
+		}
+
+		destinationPath := filepath.Join(dest, filepath.Join(components[strip:]...))
+		if destinationPath != root && !strings.HasPrefix(destinationPath, root+string(os.PathSeparator)) {
+			return fmt.Errorf("%v contains a file outside of the destination directory: %v", path, zipFile.Name)
+		}
 
 		if zipFile.FileInfo().IsDir() {
 			if err := os.MkdirAll(destinationPath, zipFile.Mode()); err != nil {
@@ -71,3 +91,19 @@ func ExtractZIP(path string, dest string) error {
 
 	return nil
 }
+
+// ZIPSize returns the total size, in bytes, of the files in the given ZIP archive once extracted.
+func ZIPSize(path string) (int64, error) {
+	zipReader, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer zipReader.Close()
+
+	var ret int64
+	for _, zipFile := range zipReader.File {
+		ret += int64(zipFile.UncompressedSize64)
+	}
+
+	return ret, nil
+}
@@ -21,7 +21,7 @@ type InstallerType string
 // IsValid returns whether the given installer type is known.
 func (it InstallerType) IsValid() bool {
 	switch it {
-	case AdvancedInstaller, AsIs, InnoSetup, MSI, NSIS, Squirrel:
+	case AdvancedInstaller, AppX, AsIs, InnoSetup, MSI, MSIX, NSIS, Squirrel:
 		return true
 	default:
 		return false
@@ -30,9 +30,11 @@ func (it InstallerType) IsValid() bool {
 
 const (
 	AdvancedInstaller InstallerType = "advancedinstaller"
+	AppX              InstallerType = "appx"
 	AsIs              InstallerType = "as-is"
 	InnoSetup         InstallerType = "innosetup"
 	MSI               InstallerType = "msi"
+	MSIX              InstallerType = "msix"
 	NSIS              InstallerType = "nsis"
 	Squirrel          InstallerType = "squirrel"
 )
@@ -42,6 +44,8 @@ func Command(path string, installerType InstallerType) []string {
 	switch installerType {
 	case AdvancedInstaller:
 		return []string{path, "/i", "/q"}
+	case AppX, MSIX:
+		return AppxCommand(path, nil, "", DefaultScope)
 	case AsIs:
 		return []string{path}
 	case InnoSetup:
@@ -56,3 +60,16 @@ func Command(path string, installerType InstallerType) []string {
 		panic("unknown installer type")
 	}
 }
+
+// InteractiveCommand returns the command that runs the given installer of the given type with its
+// user interface. MSIX and AppX packages have none, they are installed as usual.
+func InteractiveCommand(path string, installerType InstallerType) []string {
+	switch installerType {
+	case AppX, MSIX:
+		return Command(path, installerType)
+	case MSI:
+		return []string{"msiexec.exe", "/i", path}
+	default:
+		return []string{path}
+	}
+}
@@ -0,0 +1,80 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package installer
+
+// Scope is whom a program is installed for.
+type Scope string
+
+const (
+	DefaultScope Scope = ""        // Whatever the installer does by default
+	MachineScope Scope = "machine" // All users of the machine
+	UserScope    Scope = "user"    // Only the current user
+)
+
+// IsValid returns whether the given scope is known.
+func (s Scope) IsValid() bool {
+	switch s {
+	case DefaultScope, MachineScope, UserScope:
+		return true
+	default:
+		return false
+	}
+}
+
+// CommandWithScope is like Command, but also asks the installer to install for the given scope. It
+// returns false, along with the plain command, when the installer type can't choose a scope.
+func CommandWithScope(path string, installerType InstallerType, scope Scope) ([]string, bool) {
+	ret := Command(path, installerType)
+
+	if scope == DefaultScope {
+		return ret, true
+	}
+
+	switch installerType {
+	case AdvancedInstaller:
+		if scope == MachineScope {
+			return append(ret, "ALLUSERS=1"), true
+		}
+
+		return append(ret, "ALLUSERS=2", "MSIINSTALLPERUSER=1"), true
+	case AppX, MSIX:
+		return AppxCommand(path, nil, "", scope), true
+	case InnoSetup:
+		if scope == MachineScope {
+			return append(ret, "/ALLUSERS"), true
+		}
+
+		return append(ret, "/CURRENTUSER"), true
+	case MSI:
+		// Machine-wide is what we always do
+		if scope == MachineScope {
+			return ret, true
+		}
+
+		for i, arg := range ret {
+			if arg == "ALLUSERS=1" {
+				ret[i] = "ALLUSERS=2"
+			}
+		}
+
+		return append(ret, "MSIINSTALLPERUSER=1"), true
+	case Squirrel:
+		// Squirrel installers are always per-user
+		return ret, scope == UserScope
+	}
+
+	return ret, false
+}
@@ -0,0 +1,29 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package installer
+
+import (
+	"path/filepath"
+)
+
+// ShortcutCommand returns the command that creates a shortcut (a .lnk file) at the given path,
+// pointing to the given target and starting in its directory.
+func ShortcutCommand(path string, target string) []string {
+	return powerShell("$shortcut = (New-Object -ComObject WScript.Shell).CreateShortcut(" + powerShellQuote(path) + "); " +
+		"$shortcut.TargetPath = " + powerShellQuote(target) + "; " +
+		"$shortcut.WorkingDirectory = " + powerShellQuote(filepath.Dir(target)) + "; " +
+		"$shortcut.Save()")
+}
@@ -0,0 +1,65 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package installer
+
+import (
+	"regexp"
+	"strings"
+)
+
+var productCodeRegexp = regexp.MustCompile(`\{[0-9A-Fa-f-]{36}\}`)
+
+// UninstallCommand turns the uninstall command line found in the Uninstall registry hive for a
+// program installed with an installer of the given type into one that uninstalls it silently.
+func UninstallCommand(uninstallString string, installerType InstallerType) []string {
+	args := SplitCommandLine(uninstallString)
+
+	// Windows Installer packages are uninstalled by product code, whatever the uninstall string says
+	if strings.Contains(strings.ToLower(uninstallString), "msiexec") {
+		if productCode := productCodeRegexp.FindString(uninstallString); productCode != "" {
+			return []string{"msiexec.exe", "/q", "/x", productCode, "REBOOT=ReallySuppress"}
+		}
+	}
+
+	switch installerType {
+	case InnoSetup:
+		return append(args, "/norestart", "/suppressmsgboxes", "/verysilent")
+	case NSIS:
+		return append(args, "/S")
+	case Squirrel:
+		return append(args, "-s")
+	default:
+		return args
+	}
+}
+
+// SplitCommandLine splits a Windows command line, whose program path may or may not be quoted even
+// if it contains spaces, in its components.
+func SplitCommandLine(commandLine string) []string {
+	commandLine = strings.TrimSpace(commandLine)
+
+	if strings.HasPrefix(commandLine, `"`) {
+		if end := strings.Index(commandLine[1:], `"`); end >= 0 {
+			return append([]string{commandLine[1 : end+1]}, strings.Fields(commandLine[end+2:])...)
+		}
+	}
+
+	if end := strings.Index(strings.ToLower(commandLine), ".exe"); end >= 0 {
+		return append([]string{commandLine[:end+4]}, strings.Fields(commandLine[end+4:])...)
+	}
+
+	return strings.Fields(commandLine)
+}
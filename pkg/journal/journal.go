@@ -0,0 +1,105 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Timings are the durations of the phases of an installation.
+type Timings struct {
+	Resolve  time.Duration // Finding the version and the download URL
+	Download time.Duration
+	Verify   time.Duration // Checking the downloaded file against its checksum
+	Install  time.Duration // Running the installer and the steps around it
+}
+
+// Total returns the duration of the whole installation.
+func (t Timings) Total() time.Duration {
+	return t.Resolve + t.Download + t.Verify + t.Install
+}
+
+func (t Timings) String() string {
+	return fmt.Sprintf("resolve %v, download %v, verify %v, install %v", Round(t.Resolve), Round(t.Download), Round(t.Verify), Round(t.Install))
+}
+
+// Round rounds a duration to a precision suitable for display.
+func Round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Millisecond)
+}
+
+// Entry records a single installation or upgrade.
+type Entry struct {
+	Time      time.Time
+	Package   string
+	Version   string
+	Arch      string
+	Operation string // "install" or "upgrade"
+	Error     string `json:",omitempty"` // Empty on success
+	Timings   Timings
+}
+
+// Append adds an entry to the end of the journal at the given path, creating it if needed.
+func Append(path string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Read returns all the entries of the journal at the given path, oldest first. A missing file is
+// not an error, it is an empty journal.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []Entry
+
+	decoder := json.NewDecoder(f)
+	for {
+		var entry Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return ret, err
+		}
+
+		ret = append(ret, entry)
+	}
+}
@@ -0,0 +1,29 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// firstX64EmulationBuild is the first build of Windows (11) that runs x86_64 programs on ARM64,
+// earlier ARM64 editions only emulate x86.
+const firstX64EmulationBuild = 22000
+
+// hasX64Emulation returns whether this ARM64 edition of Windows can run x86_64 programs.
+func hasX64Emulation() bool {
+	return windows.RtlGetVersion().BuildNumber >= firstX64EmulationBuild
+}
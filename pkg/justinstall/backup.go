@@ -0,0 +1,270 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/just-install/just-install/pkg/cmd"
+	"github.com/just-install/just-install/pkg/installer"
+)
+
+// snapshotManifest is the name of the file listing the original location of each item of a
+// configuration snapshot.
+const snapshotManifest = "paths.json"
+
+// isRegistryKey returns whether a configuration location is a registry key rather than a path.
+func isRegistryKey(location string) bool {
+	upper := strings.ToUpper(location)
+
+	for _, prefix := range []string{`HKCU\`, `HKLM\`, `HKU\`, `HKEY_`} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// snapshotConfig saves the configuration locations of the entry that exist (files, directories
+// and registry keys) to a new temporary directory and returns its path, or the empty string if
+// there is nothing to back up.
+func (e *RegistryEntry) snapshotConfig() (string, error) {
+	if len(e.Config) == 0 {
+		return "", nil
+	}
+
+	ret, err := ioutil.TempDir(tempPath, "config-")
+	if err != nil {
+		return "", err
+	}
+
+	var locations []string
+
+	for i, location := range e.Config {
+		location = e.ExpandString(location)
+		locations = append(locations, location)
+		saved := filepath.Join(ret, strconv.Itoa(i))
+
+		if isRegistryKey(location) {
+			// Fails when the key doesn't exist, which is fine
+			if err := cmd.Run("reg.exe", "export", location, saved+".reg", "/y"); err == nil {
+				log.Println("Backed up", location)
+			}
+			continue
+		}
+
+		if _, err := os.Stat(location); os.IsNotExist(err) {
+			continue
+		}
+
+		log.Println("Backing up", location)
+
+		if err := copyPath(location, saved); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := json.Marshal(locations)
+	if err != nil {
+		return "", err
+	}
+
+	return ret, ioutil.WriteFile(filepath.Join(ret, snapshotManifest), data, 0600)
+}
+
+// restoreSnapshot puts the configuration saved by snapshotConfig back in place, over whatever is
+// there now, and removes the snapshot.
+func restoreSnapshot(snapshot string) error {
+	if snapshot == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(snapshot, snapshotManifest))
+	if err != nil {
+		return err
+	}
+
+	var locations []string
+	if err := json.Unmarshal(data, &locations); err != nil {
+		return err
+	}
+
+	for i, location := range locations {
+		saved := filepath.Join(snapshot, strconv.Itoa(i))
+
+		if isRegistryKey(location) {
+			if _, err := os.Stat(saved + ".reg"); err == nil {
+				log.Println("Restoring", location)
+
+				if err := cmd.Run("reg.exe", "import", saved+".reg"); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if _, err := os.Stat(saved); os.IsNotExist(err) {
+			continue
+		}
+
+		log.Println("Restoring", location)
+
+		if err := copyPath(saved, location); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(snapshot)
+}
+
+// BackupConfig archives the configuration locations declared by the entry to a ZIP file in the
+// given directory, named after the package, and returns its path.
+func (e *RegistryEntry) BackupConfig(name string, dir string) (string, error) {
+	if len(e.Config) == 0 {
+		return "", fmt.Errorf("%v declares no configuration to back up", name)
+	}
+
+	snapshot, err := e.snapshotConfig()
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(snapshot)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	ret := filepath.Join(dir, fmt.Sprintf("%v-%v.zip", name, time.Now().Format("20060102-150405")))
+
+	return ret, zipDirectory(snapshot, ret)
+}
+
+// RestoreConfig puts back the configuration archived by BackupConfig.
+func RestoreConfig(archive string) error {
+	snapshot, err := ioutil.TempDir(tempPath, "restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(snapshot)
+
+	if err := installer.ExtractZIP(archive, snapshot); err != nil {
+		return err
+	}
+
+	return restoreSnapshot(snapshot)
+}
+
+// zipDirectory archives the contents of a directory to a ZIP file.
+func zipDirectory(dir string, dest string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := zip.NewWriter(out)
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		w, err := writer.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		_, err = io.Copy(w, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// copyPath copies a file or a directory tree, overwriting existing files at the destination.
+func copyPath(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func copyFile(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,307 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/just-install/just-install/pkg/cache"
+	"github.com/just-install/just-install/pkg/config"
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// cacheMarker is created in the download cache so that a directory can be recognized as one. Since
+// "clean" wipes the cache, only empty directories or existing caches are accepted as a new location.
+const cacheMarker = ".just-install-cache"
+
+// defaultCacheMaxSize is the size, in MB, the download cache is kept under when the configuration
+// doesn't say otherwise.
+const defaultCacheMaxSize = 4096
+
+// SetCacheDir moves the download cache of future operations to the given directory, which is
+// created if needed. Existing contents are not moved, see MoveCache for that.
+func SetCacheDir(dir string) error {
+	dir, err := validateCacheDir(dir)
+	if err != nil {
+		return err
+	}
+
+	setPaths(dir, dataDir)
+	createTempDir()
+
+	return nil
+}
+
+// MoveCache relocates the contents of the download cache to the given directory and records it in
+// the configuration file, so that future runs use it.
+func MoveCache(dir string) error {
+	dir, err := validateCacheDir(dir)
+	if err != nil {
+		return err
+	}
+
+	if dir == tempPath {
+		return errors.New("the cache is already there")
+	} else if isSubPath(dir, tempPath) || isSubPath(tempPath, dir) {
+		return errors.New("the old and the new location overlap")
+	}
+
+	files, err := ioutil.ReadDir(tempPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, f := range files {
+		src := filepath.Join(tempPath, f.Name())
+		dst := filepath.Join(dir, f.Name())
+
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+
+		// Renaming fails across volumes, which is the common case here
+		if err := os.Rename(src, dst); err == nil {
+			continue
+		}
+
+		if err := copyPath(src, dst); err != nil {
+			return fmt.Errorf("cannot copy %v: %v", src, err)
+		}
+
+		if err := os.RemoveAll(src); err != nil {
+			return err
+		}
+	}
+
+	os.Remove(tempPath)
+
+	// Only change the cache location in the file, leaving out settings given on the command line
+	saved, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	saved.CacheDir = dir
+	if err := saved.Save(configPath); err != nil {
+		return fmt.Errorf("cannot update the configuration file: %v", err)
+	}
+
+	setPaths(dir, dataDir)
+	createTempDir()
+
+	return nil
+}
+
+// CacheDir returns the directory of the download cache.
+func CacheDir() string {
+	return tempPath
+}
+
+// CacheEntries returns the files in the download cache, least recently used first.
+func CacheEntries() ([]cache.Entry, error) {
+	return downloadCache.Entries()
+}
+
+// PruneCache evicts the least recently used files from the download cache until it takes at most
+// the given size in bytes, and returns the evicted files.
+func PruneCache(maxSize int64) ([]cache.Entry, error) {
+	return downloadCache.Evict(maxSize)
+}
+
+// VerifyCache checks the files in the download cache against the hashes recorded when they were
+// last used and removes the corrupted ones, returning the files found intact and the removed ones.
+func VerifyCache() ([]cache.Entry, []cache.Entry, error) {
+	verified, corrupted, err := downloadCache.Verify()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, entry := range corrupted {
+		if err := downloadCache.Remove(entry.Name); err != nil {
+			return verified, corrupted[:i], err
+		}
+	}
+
+	return verified, corrupted, nil
+}
+
+// CheckCache is VerifyCache, keeping the corrupted files.
+func CheckCache() ([]cache.Entry, []cache.Entry, error) {
+	return downloadCache.Verify()
+}
+
+// RedownloadCached replaces a file of the download cache, like a corrupted one, with a new download
+// from the URL it was downloaded from, and hashes it again.
+func RedownloadCached(entry cache.Entry) error {
+	if entry.URL == "" {
+		return fmt.Errorf("the origin of %v is unknown", entry.Name)
+	}
+
+	if err := downloadCache.Remove(entry.Name); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	path, err := fetch.FetchContext(ctx, entry.URL, fetchOptions(downloadCache.Path(entry.Name)))
+	if err != nil {
+		return err
+	}
+
+	useCached(entry.URL, path)
+
+	return nil
+}
+
+// CollectCacheGarbage removes the partial downloads left in the download cache by crashed
+// processes, and the ones kept to be resumed for longer than maxAge, returning the removed files.
+func CollectCacheGarbage(maxAge time.Duration) ([]string, error) {
+	if _, err := os.Stat(tempPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return fetch.CollectGarbage(tempPath, maxAge)
+}
+
+// cacheMaxSize returns the maximum size of the download cache in bytes, or -1 if unlimited.
+func cacheMaxSize() int64 {
+	if cfg.CacheMaxSize < 0 {
+		return -1
+	} else if cfg.CacheMaxSize == 0 {
+		return defaultCacheMaxSize * 1024 * 1024
+	}
+
+	return int64(cfg.CacheMaxSize) * 1024 * 1024
+}
+
+// useCached records that the file at the given path, downloaded from the given URL, was just used
+// and evicts the least recently used files if the download cache grew too large. Files outside of
+// the cache are ignored.
+func useCached(rawurl string, path string) {
+	if filepath.Dir(path) != downloadCache.Dir() {
+		return
+	}
+
+	name := filepath.Base(path)
+	if err := downloadCache.Touch(name, rawurl); err != nil {
+		log.Println("WARNING: cannot update the download cache index:", err)
+		return
+	}
+
+	maxSize := cacheMaxSize()
+	if maxSize < 0 {
+		return
+	}
+
+	evicted, err := downloadCache.Evict(maxSize, name)
+	for _, entry := range evicted {
+		log.Printf("Evicted %v from the download cache", entry.Name)
+	}
+
+	if err != nil {
+		log.Println("WARNING: cannot evict files from the download cache:", err)
+	}
+}
+
+// reuseCached makes a file of the download cache with the given SHA-256 hash available at the
+// given path, so that an installer republished under a different URL isn't downloaded again. It
+// reports whether such a file was found.
+func reuseCached(checksum string, path string) bool {
+	if checksum == "" || filepath.Dir(path) != downloadCache.Dir() {
+		return false
+	}
+
+	name, ok := downloadCache.Lookup(checksum)
+	if !ok || name == filepath.Base(path) {
+		return false
+	}
+
+	if err := downloadCache.Clone(name, path); err != nil {
+		log.Printf("WARNING: cannot reuse %v from the download cache: %v", name, err)
+		return false
+	}
+
+	log.Printf("Reusing %v from the download cache, which has the same contents", name)
+
+	return true
+}
+
+// recordCacheHit records in the download statistics that the file of the given URL came from the
+// download cache, unless it was already fetched (or revalidated) by this process.
+func recordCacheHit(rawurl string) {
+	for _, stat := range fetch.Stats() {
+		if stat.Resource == rawurl {
+			return
+		}
+	}
+
+	fetch.RecordCacheHit(rawurl)
+}
+
+// validateCacheDir checks that the given directory can hold the download cache and returns its
+// cleaned up path.
+func validateCacheDir(dir string) (string, error) {
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%v is not an absolute path", dir)
+	}
+
+	dir = filepath.Clean(dir)
+	if filepath.Dir(dir) == dir {
+		return "", fmt.Errorf("%v is the root of a volume", dir)
+	}
+
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("%v is not a directory", dir)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if len(files) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, cacheMarker)); err != nil {
+			return "", fmt.Errorf("%v is neither empty nor a just-install cache", dir)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(dir, "write-test-")
+	if err != nil {
+		return "", fmt.Errorf("%v is not writable: %v", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return dir, nil
+}
+
+// isSubPath reports whether path is inside dir.
+func isSubPath(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,84 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/verify"
+)
+
+// quarantinePath is where downloads failing verification are kept for investigation.
+var quarantinePath = filepath.Join(dataDir, "quarantine")
+
+// verifySignature checks the installer at the given path against the detached signature of the
+// entry for the current architecture, if it has one. The signature is downloaded every time, so
+// that a cached one cannot go stale.
+func (e *RegistryEntry) verifySignature(path string) error {
+	archInstaller, err := e.archInstaller(arch)
+	if err != nil || archInstaller.Signature == "" {
+		return nil
+	} else if e.Installer.PublicKey == "" {
+		return fmt.Errorf("cannot verify the signature of %v: the registry has no public key for it", path)
+	}
+
+	url, err := ResolveURL(e.ExpandString(archInstaller.Signature))
+	if err != nil {
+		return fmt.Errorf("cannot resolve the signature of %v: %v", path, err)
+	}
+
+	options := e.fetchOptions(tempFilePath(url, ""))
+	options.Progress = false
+
+	signaturePath, err := fetch.Fetch(url, options)
+	if err != nil {
+		return fmt.Errorf("cannot download the signature of %v: %v", path, err)
+	}
+
+	if err := verify.Detached(path, signaturePath, e.Installer.PublicKey); err != nil {
+		return fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	return nil
+}
+
+// quarantine moves a download that failed verification out of the download cache. Files outside
+// of the download cache (i.e. file:// sources) are left where they are.
+func quarantine(path string) {
+	if filepath.Dir(path) != downloadCache.Dir() {
+		return
+	}
+
+	if err := os.MkdirAll(quarantinePath, 0700); err != nil {
+		log.Println("WARNING: cannot create the quarantine directory:", err)
+		os.Remove(path)
+		return
+	}
+
+	dest := filepath.Join(quarantinePath, time.Now().Format("20060102-150405-")+filepath.Base(path))
+	if err := os.Rename(path, dest); err != nil {
+		log.Println("WARNING: cannot quarantine the download:", err)
+		os.Remove(path)
+		return
+	}
+
+	log.Println("Moved the download to", dest)
+}
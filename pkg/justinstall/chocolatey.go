@@ -0,0 +1,227 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/installer"
+)
+
+// nuspec is the manifest of a Chocolatey package.
+type nuspec struct {
+	XMLName  xml.Name `xml:"package"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Metadata struct {
+		ID          string `xml:"id"`
+		Version     string `xml:"version"`
+		Title       string `xml:"title"`
+		Authors     string `xml:"authors"`
+		Description string `xml:"description"`
+		Tags        string `xml:"tags"`
+	} `xml:"metadata"`
+	Files struct {
+		File []nuspecFile `xml:"file"`
+	} `xml:"files"`
+}
+
+type nuspecFile struct {
+	Src    string `xml:"src,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// ExportChocolatey writes a skeleton Chocolatey package for the entry, with the given name, to a
+// subdirectory of dir and returns its path. The package consists of a .nuspec manifest and a
+// tools\chocolateyInstall.ps1 script that downloads and silently runs the same installers that
+// just-install would use. Both are meant to be reviewed before being packed with "choco pack".
+func (e *RegistryEntry) ExportChocolatey(name string, dir string) (string, error) {
+	if e.IsGroup() {
+		return "", errors.New("groups cannot be exported")
+	} else if e.Version == "latest" {
+		return "", errors.New("packages without a fixed version cannot be exported")
+	} else if _, ok := e.Installer.options()["container"]; ok {
+		return "", errors.New("installers inside archives cannot be exported")
+	}
+
+	script, err := e.chocolateyInstallScript()
+	if err != nil {
+		return "", err
+	}
+
+	var spec nuspec
+	spec.XMLNS = "http://schemas.microsoft.com/packaging/2015/06/nuspec.xsd"
+	spec.Metadata.ID = name
+	spec.Metadata.Version = e.Version
+	spec.Metadata.Title = name
+	spec.Metadata.Authors = "TODO"
+	spec.Metadata.Description = fmt.Sprintf("%v, converted from the just-install registry.", name)
+	spec.Metadata.Tags = "just-install"
+	spec.Files.File = []nuspecFile{{Src: `tools\**`, Target: "tools"}}
+
+	manifest, err := xml.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	ret := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Join(ret, "tools"), 0755); err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(ret, name+".nuspec"), append([]byte(xml.Header), manifest...), 0644); err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(ret, "tools", "chocolateyInstall.ps1"), []byte(script), 0644); err != nil {
+		return "", err
+	}
+
+	return ret, nil
+}
+
+// chocolateyInstallScript returns a chocolateyInstall.ps1 script calling Install-ChocolateyPackage
+// with the URLs, checksums and silent switches of the entry.
+func (e *RegistryEntry) chocolateyInstallScript() (string, error) {
+	kind := e.Installer.Kind
+
+	for _, ai := range []*archInstaller{&e.Installer.X86, &e.Installer.X86_64} {
+		if ai.Kind != "" && ai.Kind != kind {
+			return "", errors.New("architecture-specific installer kinds cannot be exported")
+		}
+	}
+
+	fileType := "exe"
+	if kind == string(installer.MSI) {
+		fileType = "msi"
+	}
+
+	silentArgs, err := e.chocolateySilentArgs(kind)
+	if err != nil {
+		return "", err
+	}
+
+	args := [][2]string{
+		{"packageName", "$env:ChocolateyPackageName"},
+		{"fileType", quotePowerShell(fileType)},
+	}
+
+	sources := []struct {
+		installer *archInstaller
+		suffix    string
+	}{
+		{&e.Installer.X86, ""},
+		{&e.Installer.X86_64, "64"},
+	}
+
+	for _, source := range sources {
+		if source.installer.URL == "" {
+			continue
+		}
+
+		url, err := ResolveURL(e.ExpandString(source.installer.URL))
+		if err != nil {
+			return "", err
+		}
+
+		if source.suffix == "" {
+			args = append(args, [2]string{"url", quotePowerShell(url)})
+		} else {
+			args = append(args, [2]string{"url64bit", quotePowerShell(url)})
+		}
+
+		if source.installer.SHA256 != "" {
+			args = append(args,
+				[2]string{"checksum" + source.suffix, quotePowerShell(source.installer.SHA256)},
+				[2]string{"checksumType" + source.suffix, "'sha256'"})
+		}
+	}
+
+	args = append(args,
+		[2]string{"silentArgs", quotePowerShell(silentArgs)},
+		[2]string{"validExitCodes", "@(0, 3010)"})
+
+	width := 0
+	for _, arg := range args {
+		if len(arg[0]) > width {
+			width = len(arg[0])
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("$ErrorActionPreference = 'Stop'\r\n\r\n")
+	b.WriteString("# Generated by just-install, add the checksums if missing before publishing.\r\n")
+	b.WriteString("$packageArgs = @{\r\n")
+	for _, arg := range args {
+		fmt.Fprintf(&b, "  %-*v = %v\r\n", width, arg[0], arg[1])
+	}
+	b.WriteString("}\r\n\r\nInstall-ChocolateyPackage @packageArgs\r\n")
+
+	return b.String(), nil
+}
+
+// chocolateySilentArgs returns the arguments that make an installer of the given kind silent, in
+// the form expected by Install-ChocolateyPackage (which runs MSI packages through msiexec /i).
+func (e *RegistryEntry) chocolateySilentArgs(kind string) (string, error) {
+	const placeholder = "{{.installer}}"
+
+	if kind == "custom" {
+		arguments := e.arguments()
+		if len(arguments) == 0 || arguments[0] != placeholder {
+			return "", errors.New("custom installers that are not run directly cannot be exported")
+		}
+
+		var ret []string
+		for _, v := range arguments[1:] {
+			ret = append(ret, e.ExpandString(v))
+		}
+
+		return strings.Join(ret, " "), nil
+	}
+
+	installerType := installer.InstallerType(kind)
+	if !installerType.IsValid() {
+		return "", fmt.Errorf("unknown installer type: %v", kind)
+	} else if installerType == installer.AppX || installerType == installer.MSIX {
+		return "", errors.New("MSIX and AppX packages cannot be exported")
+	}
+
+	var ret []string
+	for _, arg := range installer.Command(placeholder, installerType) {
+		switch arg {
+		case placeholder, "msiexec.exe":
+			continue
+		case "/i":
+			if installerType == installer.MSI {
+				continue
+			}
+		}
+
+		ret = append(ret, arg)
+	}
+
+	return strings.Join(ret, " "), nil
+}
+
+// quotePowerShell returns s as a single-quoted PowerShell string.
+func quotePowerShell(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
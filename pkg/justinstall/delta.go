@@ -0,0 +1,173 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+
+	"github.com/just-install/just-install/pkg/delta"
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// patch is a bsdiff patch building an installer from the previous version of it.
+type patch struct {
+	From string // SHA-256 of the previous installer
+	URL  string
+}
+
+// digits matches the numbers in URLs, which tell versions apart.
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// downloadDelta builds the installer at the given URL at the given path from a previous version of
+// it in the download cache, downloading only a patch or the blocks that changed, and reports
+// whether it could. The result still has to be verified.
+func (e *RegistryEntry) downloadDelta(url string, path string) bool {
+	archInstaller, err := e.archInstaller(arch)
+	if err != nil || fetch.IsOffline() {
+		return false
+	}
+
+	for _, p := range archInstaller.Patches {
+		name, ok := downloadCache.Lookup(p.From)
+		if !ok {
+			continue
+		}
+
+		if err := e.applyPatch(p, downloadCache.Path(name), path); err != nil {
+			log.Println("WARNING: cannot patch the previous version of the installer:", err)
+			continue
+		}
+
+		log.Printf("Patched %v to the new version of the installer", name)
+		return true
+	}
+
+	if archInstaller.Zsync == "" {
+		return false
+	}
+
+	seeds := e.deltaSeeds(url)
+	if len(seeds) == 0 {
+		return false
+	}
+
+	fetched, length, err := e.zsync(url, archInstaller.Zsync, seeds, path)
+	if err != nil {
+		log.Println("WARNING: cannot build the installer from its previous versions:", err)
+		return false
+	}
+
+	log.Printf("Downloaded %.1f of %.1f MB, the rest comes from previous versions", float64(fetched)/1024/1024, float64(length)/1024/1024)
+	return true
+}
+
+// applyPatch downloads the given patch and applies it to the old installer, writing the result to
+// the given path.
+func (e *RegistryEntry) applyPatch(p patch, oldPath string, path string) error {
+	patchURL, err := ResolveURL(e.ExpandString(p.URL))
+	if err != nil {
+		return err
+	}
+
+	patchPath, err := fetch.Fetch(patchURL, e.fetchOptions(tempFilePath(patchURL, "")))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(patchPath)
+
+	old, err := ioutil.ReadFile(oldPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(patchPath)
+	if err != nil {
+		return err
+	}
+
+	patched, err := delta.Patch(old, data)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", patched, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// zsync downloads the given zsync control file and builds the installer it describes at the given
+// path from the seed files, fetching the missing blocks from the installer URL. It returns how many
+// bytes were fetched, and the size of the installer.
+func (e *RegistryEntry) zsync(url string, rawControlURL string, seeds []string, path string) (int64, int64, error) {
+	controlURL, err := ResolveURL(e.ExpandString(rawControlURL))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	controlPath, err := fetch.Fetch(controlURL, e.fetchOptions(tempFilePath(controlURL, "")))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(controlPath)
+
+	data, err := ioutil.ReadFile(controlPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	control, err := delta.ParseControl(data)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%v: %v", controlURL, err)
+	}
+
+	options := e.fetchOptions(path)
+	fetched, err := control.Reconstruct(path+".tmp", seeds, func(start int64, end int64) ([]byte, error) {
+		return fetch.FetchRange(url, start, end, options)
+	})
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return fetched, control.Length, err
+	}
+
+	return fetched, control.Length, os.Rename(path+".tmp", path)
+}
+
+// deltaSeeds returns the files of the download cache that are likely previous versions of the
+// installer at the given URL: the ones downloaded from URLs differing only by their numbers.
+func (e *RegistryEntry) deltaSeeds(url string) []string {
+	entries, err := downloadCache.Entries()
+	if err != nil {
+		return nil
+	}
+
+	pattern := digits.ReplaceAllString(url, "0")
+
+	// Most recently used first, which are also the most likely to be close to the new version
+	var ret []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].URL != "" && entries[i].URL != url && digits.ReplaceAllString(entries[i].URL, "0") == pattern {
+			ret = append(ret, downloadCache.Path(entries[i].Name))
+		}
+	}
+
+	return ret
+}
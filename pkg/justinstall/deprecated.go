@@ -0,0 +1,52 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"github.com/just-install/just-install/pkg/state"
+)
+
+// Replacement returns the package superseding the given deprecated package, if the registry has
+// one.
+func (r *Registry) Replacement(name string) (string, bool) {
+	entry, ok := r.Packages[name]
+	if !ok || !entry.Deprecated || entry.SupersededBy == "" {
+		return "", false
+	}
+
+	if _, ok := r.Packages[entry.SupersededBy]; !ok {
+		return "", false
+	}
+
+	return entry.SupersededBy, true
+}
+
+// InstalledDeprecated returns the sorted names of the deprecated packages that are installed.
+func (r *Registry) InstalledDeprecated(installState *state.State) []string {
+	var ret []string
+
+	for _, name := range r.SortedPackageNames() {
+		if !r.Packages[name].Deprecated {
+			continue
+		}
+
+		if _, ok := r.InstalledVersion(name, installState); ok {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,164 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"sort"
+
+	dry "github.com/ungerik/go-dry"
+)
+
+// Kinds of RegistryChange.
+const (
+	EntryAdded   = "added"
+	EntryChanged = "changed"
+	EntryRemoved = "removed"
+)
+
+// RegistryChange is a difference between two versions of the registry for a single package.
+type RegistryChange struct {
+	Package string
+	Kind    string   // EntryAdded, EntryChanged or EntryRemoved
+	Version string   `json:",omitempty"` // Of the added or removed entry
+	Details []string `json:",omitempty"` // What changed, like "version: 1.0 -> 1.1"
+}
+
+// DiffRegistries returns the differences between two versions of a registry, sorted by package
+// name: the entries that were added or removed, and the ones whose version, installer kind,
+// download URLs, checksums or anything else changed.
+func DiffRegistries(old Registry, new Registry) []RegistryChange {
+	var names []string
+	for name := range old.Packages {
+		names = append(names, name)
+	}
+	for name := range new.Packages {
+		if _, ok := old.Packages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var ret []RegistryChange
+
+	for _, name := range names {
+		oldEntry, inOld := old.Packages[name]
+		newEntry, inNew := new.Packages[name]
+
+		switch {
+		case !inOld:
+			ret = append(ret, RegistryChange{Package: name, Kind: EntryAdded, Version: newEntry.Version})
+		case !inNew:
+			ret = append(ret, RegistryChange{Package: name, Kind: EntryRemoved, Version: oldEntry.Version})
+		default:
+			if details := diffEntries(oldEntry, newEntry); len(details) > 0 {
+				ret = append(ret, RegistryChange{Package: name, Kind: EntryChanged, Details: details})
+			}
+		}
+	}
+
+	return ret
+}
+
+// diffEntries describes the differences between two versions of a registry entry.
+func diffEntries(old RegistryEntry, new RegistryEntry) []string {
+	var ret []string
+
+	changed := func(field string, a string, b string) {
+		if a != b {
+			ret = append(ret, fmt.Sprintf("%v: %v -> %v", field, orNone(a), orNone(b)))
+		}
+	}
+
+	changed("version", old.Version, new.Version)
+	changed("kind", old.Installer.Kind, new.Installer.Kind)
+
+	for _, a := range hashedArchs {
+		oldInstaller, newInstaller := old.Installer.forArch(a), new.Installer.forArch(a)
+
+		changed(a+" url", oldInstaller.URL, newInstaller.URL)
+		changed(a+" sha256", oldInstaller.SHA256, newInstaller.SHA256)
+	}
+
+	if len(ret) == 0 && !sameJSON(old, new) {
+		ret = append(ret, "other fields changed")
+	}
+
+	return ret
+}
+
+// orNone returns the given value, or "none" when it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+
+	return s
+}
+
+// sameJSON returns whether two values have the same JSON representation.
+func sameJSON(a interface{}, b interface{}) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+
+	return string(dataA) == string(dataB)
+}
+
+// previousRegistryPath returns the path of the copy of the official registry replaced by the last
+// download that changed it.
+func previousRegistryPath() string {
+	return registryPath + ".previous"
+}
+
+// keepPreviousRegistry saves the given contents of the official registry as the previous one, if
+// the registry downloaded since then is different.
+func keepPreviousRegistry(previous []byte) {
+	current, err := ioutil.ReadFile(registryPath)
+	if err != nil || string(current) == string(previous) {
+		return
+	}
+
+	if err := ioutil.WriteFile(previousRegistryPath(), previous, 0600); err != nil {
+		log.Println("WARNING: cannot keep the previous registry:", err)
+	}
+}
+
+// PreviousRegistry loads the copy of the official registry that was replaced by the last download
+// that changed it, if any.
+func PreviousRegistry() (Registry, bool) {
+	if !dry.FileExists(previousRegistryPath()) {
+		return Registry{}, false
+	}
+
+	return LoadRegistry(previousRegistryPath()), true
+}
+
+// LoadRegistrySource loads a single registry from a local path or a URL, or the official one for
+// DefaultRegistry, without the local overrides.
+func LoadRegistrySource(source string) Registry {
+	if source == DefaultRegistry {
+		return smartLoadRegistry(false)
+	}
+
+	return loadCustomRegistry(source)
+}
@@ -0,0 +1,230 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+
+	dry "github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/environ"
+	"github.com/just-install/just-install/pkg/installer"
+	"github.com/just-install/just-install/pkg/state"
+)
+
+// programDir returns the directory that the relative addToPath entries of the entry refer to: the
+// directory of portable programs, or the one of the file of the detection rule.
+func (e *RegistryEntry) programDir() (string, error) {
+	if e.isPortable() {
+		destination, err := e.destination()
+		if err != nil {
+			return "", err
+		}
+
+		return e.installDir(destination), nil
+	}
+
+	if e.Detect != nil && e.Detect.File != "" {
+		return filepath.Dir(e.ExpandString(e.Detect.File)), nil
+	}
+
+	return "", errors.New("relative addToPath directories need a portable installer or a file detection rule")
+}
+
+// pathDirectories returns the directories that the addToPath key of the entry, and the addToPath
+// option of portable installers, add to PATH, with their placeholders expanded.
+func (e *RegistryEntry) pathDirectories() ([]string, error) {
+	var ret []string
+
+	for _, dir := range e.AddToPath {
+		dir = e.ExpandString(dir)
+
+		if !filepath.IsAbs(dir) {
+			programDir, err := e.programDir()
+			if err != nil {
+				return nil, err
+			}
+
+			dir = filepath.Join(programDir, dir)
+		}
+
+		ret = append(ret, dir)
+	}
+
+	if e.isPortable() {
+		destination, err := e.destination()
+		if err != nil {
+			return nil, err
+		}
+
+		if dir, ok := e.pathDirectory(destination); ok && !dry.StringInSlice(dir, ret) {
+			ret = append(ret, dir)
+		}
+	}
+
+	return ret, nil
+}
+
+// environment returns the persistent environment variables set by the entry, sorted by name, with
+// their placeholders expanded.
+func (e *RegistryEntry) environment() []environ.Variable {
+	var ret []environ.Variable
+	for name, value := range e.Env {
+		ret = append(ret, environ.Variable{Name: name, Value: e.ExpandString(value)})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+
+	return ret
+}
+
+// ApplyEnvironment sets the persistent environment variables of the entry and adds its directories
+// to PATH, for the machine or for the current user depending on the scope. The changes are
+// recorded in the state database under the given package name, along with the values the
+// variables had before, so that RevertEnvironment can undo them. Variables changed by someone else
+// since a previous installation of the package are left alone.
+func (e *RegistryEntry) ApplyEnvironment(installState *state.State, name string) error {
+	dirs, err := e.pathDirectories()
+	if err != nil {
+		return err
+	}
+
+	machine := scope != installer.UserScope
+
+	recorded, ok := installState.EnvironmentChanges(name)
+	if ok && recorded.Machine != machine {
+		if err := RevertEnvironment(installState, name); err != nil {
+			return err
+		}
+
+		ok = false
+	}
+
+	if !ok {
+		recorded = state.Environment{Machine: machine}
+	}
+
+	variables := make(map[string]state.Variable)
+	for k, v := range recorded.Variables {
+		variables[k] = v
+	}
+
+	// Record each change as soon as it is made, in case a later one fails
+	defer func() {
+		recorded.Variables = variables
+		installState.RecordEnvironmentChanges(name, recorded)
+	}()
+
+	for _, v := range e.environment() {
+		current, set, err := environ.GetVariable(v.Name, machine)
+		if err != nil {
+			return fmt.Errorf("cannot read %v: %v", v.Name, err)
+		}
+
+		record, ok := variables[v.Name]
+		if ok && (!set || current != record.Value) {
+			log.Printf("Keeping %v as it is, it changed since %v set it", v.Name, name)
+			continue
+		} else if !ok && set {
+			previous := current
+			record.Previous = &previous
+		}
+
+		log.Printf("Setting %v=%v", v.Name, v.Value)
+
+		if err := environ.SetVariable(v.Name, v.Value, machine); err != nil {
+			return fmt.Errorf("cannot set %v: %v", v.Name, err)
+		}
+
+		record.Value = v.Value
+		variables[v.Name] = record
+	}
+
+	for _, dir := range dirs {
+		inPath, err := environ.InPath(dir, machine)
+		if err != nil {
+			return fmt.Errorf("cannot read PATH: %v", err)
+		} else if inPath {
+			continue
+		}
+
+		log.Println("Adding to PATH:", dir)
+
+		if err := environ.AddToPath(dir, machine); err != nil {
+			return fmt.Errorf("cannot add %v to PATH: %v", dir, err)
+		}
+
+		if !dry.StringInSlice(dir, recorded.Path) {
+			recorded.Path = append(recorded.Path, dir)
+		}
+	}
+
+	return nil
+}
+
+// RevertEnvironment undoes the changes to the persistent environment recorded by ApplyEnvironment
+// for the given package: it removes the directories it added to PATH, and puts back the previous
+// value of the variables it set, or removes them if they were not set before. Variables that
+// changed since are left alone.
+func RevertEnvironment(installState *state.State, name string) error {
+	recorded, ok := installState.EnvironmentChanges(name)
+	if !ok {
+		return nil
+	}
+
+	for _, dir := range recorded.Path {
+		if err := environ.RemoveFromPath(dir, recorded.Machine); err != nil {
+			return fmt.Errorf("cannot remove %v from PATH: %v", dir, err)
+		}
+	}
+
+	var names []string
+	for variable := range recorded.Variables {
+		names = append(names, variable)
+	}
+	sort.Strings(names)
+
+	for _, variable := range names {
+		record := recorded.Variables[variable]
+
+		current, set, err := environ.GetVariable(variable, recorded.Machine)
+		if err != nil {
+			return fmt.Errorf("cannot read %v: %v", variable, err)
+		}
+
+		if !set || current != record.Value {
+			log.Printf("Keeping %v as it is, it changed since %v set it", variable, name)
+		} else if record.Previous != nil {
+			log.Printf("Restoring %v=%v", variable, *record.Previous)
+			err = environ.SetVariable(variable, *record.Previous, recorded.Machine)
+		} else {
+			log.Println("Removing", variable)
+			err = environ.RemoveVariable(variable, recorded.Machine)
+		}
+
+		if err != nil {
+			return fmt.Errorf("cannot restore %v: %v", variable, err)
+		}
+	}
+
+	installState.RecordEnvironmentChanges(name, state.Environment{})
+
+	return nil
+}
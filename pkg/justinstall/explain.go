@@ -0,0 +1,297 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/cmd"
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/installer"
+	dry "github.com/ungerik/go-dry"
+)
+
+// Explain returns, in order, the operations that installing the entry (or upgrading it, when
+// upgrade is true) would perform, without performing any of them. Secrets are masked.
+func (e *RegistryEntry) Explain(upgrade bool, force bool) ([]string, error) {
+	var ret []string
+	add := func(format string, args ...interface{}) {
+		ret = append(ret, cmd.Mask(fmt.Sprintf(format, args...)))
+	}
+
+	resolved, archInstaller, err := e.Installer.resolveArch(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := e.installerURL(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolved != arch {
+		add("Resolve the %v installer (emulated on %v): %v", resolved, arch, url)
+	} else {
+		add("Resolve the %v installer: %v", resolved, url)
+	}
+
+	path := e.installerPath(url)
+	if dry.FileExists(path) && !force {
+		if archInstaller.SHA256 == "" && (strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")) {
+			add("Use the cached installer, unless the server reports that it changed: %v", path)
+		} else {
+			add("Use the cached installer: %v", path)
+		}
+	} else {
+		source := "the origin"
+		if cfg.PeerCache {
+			source = "a peer, falling back to the origin"
+		}
+		if mirrors := e.mirrorURLs(); len(mirrors) > 0 {
+			source += ", then the mirrors " + strings.Join(mirrors, ", ")
+		}
+		if cfg.Downloader == fetch.DownloaderBITS {
+			source += " (through BITS)"
+		} else if cfg.BITSMinSize > 0 {
+			source += " (through BITS if larger than " + fmt.Sprint(cfg.BITSMinSize) + " MB)"
+		}
+
+		if len(archInstaller.Patches) > 0 || archInstaller.Zsync != "" {
+			add("Build from a previous version in the download cache if possible, downloading only what changed")
+		}
+
+		add("Download from %v to: %v", source, path)
+
+		if len(e.Installer.Headers) > 0 {
+			var names []string
+			for name := range e.Installer.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			add("Send the request headers: %v", strings.Join(names, ", "))
+		}
+		if e.Installer.UserAgent != "" {
+			add("Send the User-Agent: %v", e.Installer.UserAgent)
+		}
+	}
+
+	if archInstaller.SHA256 != "" {
+		add("Verify the SHA-256 checksum: %v", archInstaller.SHA256)
+	}
+	if archInstaller.Signature != "" {
+		add("Verify the signature: %v", e.ExpandString(archInstaller.Signature))
+	}
+	if e.Installer.Publisher != "" {
+		add("Verify the Authenticode signature, signed by: %v", e.Installer.Publisher)
+	} else if e.Installer.Authenticode {
+		add("Verify the Authenticode signature")
+	}
+	if archInstaller.SHA256 == "" && archInstaller.Signature == "" && !e.Installer.Authenticode && e.Installer.Publisher == "" {
+		add("Verify: nothing, the registry has no checksum or signature for this installer")
+	}
+
+	if upgrade && len(e.Config) > 0 {
+		add("Back up the user configuration: %v", strings.Join(e.Config, ", "))
+	}
+
+	if upgrade {
+		ret = append(ret, e.explainSteps("Before upgrade", e.Installer.BeforeUpgrade)...)
+	}
+
+	if e.Installer.System && (restorePoints || cfg.RestorePoint) {
+		add("Create a System Restore point")
+	}
+
+	for _, h := range e.Installer.PreInstall {
+		add("Run the pre-install command: %v", e.ExpandString(h.String()))
+	}
+
+	if container, ok := e.Installer.options()["container"]; ok {
+		dir := filepath.Join(tempPath, crc32s(path))
+		add("Extract the archive to: %v", dir)
+
+		path = filepath.Join(dir, container.(map[string]interface{})["installer"].(string))
+	}
+
+	if e.isPortable() {
+		portable, err := e.explainPortable()
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, portable...)
+	} else {
+		command, scoped, err := e.installerCommand(path)
+		if err != nil {
+			return nil, err
+		}
+
+		add("Run the %v installer: %v", e.kind(), strings.Join(command, " "))
+
+		if env := e.installerEnv(); len(env) > 0 {
+			add("    with the environment: %v", strings.Join(env, " "))
+		}
+
+		if !scoped {
+			add("    for the default scope of the installer, it cannot be asked to install for the %v scope", scope)
+		}
+
+		if interactive {
+			add("    showing its user interface")
+		} else if e.Installer.Win32Automation && len(e.Installer.Win32Steps) > 0 {
+			add("    driving its user interface with %v Win32 automation steps", len(e.Installer.Win32Steps))
+		} else if e.Installer.Interactive {
+			add("    which might require user interaction")
+		}
+	}
+
+	add("Elevation: %v", e.elevation())
+
+	for _, h := range e.Installer.PostInstall {
+		add("Run the post-install command: %v", e.ExpandString(h.String()))
+	}
+
+	ret = append(ret, e.explainSteps("Activate", e.Installer.Activate)...)
+
+	for _, v := range e.environment() {
+		add("Set the environment variable: %v=%v", v.Name, v.Value)
+	}
+
+	dirs, err := e.pathDirectories()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		add("Add to PATH: %v", dir)
+	}
+
+	if upgrade && len(e.Config) > 0 {
+		add("Restore the user configuration")
+	}
+
+	if upgrade {
+		ret = append(ret, e.explainSteps("After upgrade", e.Installer.AfterUpgrade)...)
+	}
+
+	if shims, ok := e.Installer.options()["shims"].([]interface{}); ok {
+		exeproxy := os.ExpandEnv("${ProgramFiles(x86)}\\exeproxy\\exeproxy.exe")
+
+		for _, v := range shims {
+			target := e.ExpandString(v.(string))
+
+			if dry.FileExists(exeproxy) {
+				add("Create a shim: %v -> %v", filepath.Join(shimsPath, filepath.Base(target)), target)
+			} else {
+				add("Skip the shim for %v, exeproxy is not installed", target)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// explainPortable describes how a portable program is copied or extracted to its destination.
+func (e *RegistryEntry) explainPortable() ([]string, error) {
+	destination, err := e.destination()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+
+	if e.kind() == "zip" {
+		if strip := e.stripComponents(); strip > 0 {
+			ret = append(ret, fmt.Sprintf("Extract the archive to: %v, removing %v leading directories", destination, strip))
+		} else {
+			ret = append(ret, fmt.Sprintf("Extract the archive to: %v", destination))
+		}
+	} else {
+		ret = append(ret, fmt.Sprintf("Copy the file to: %v", destination))
+	}
+
+	shortcuts, err := e.shortcuts(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range shortcuts {
+		ret = append(ret, fmt.Sprintf("Create a Start Menu shortcut: %v -> %v", s.Path, s.Target))
+	}
+
+	return ret, nil
+}
+
+// explainSteps describes the given steps, each prefixed by the given label.
+func (e *RegistryEntry) explainSteps(label string, steps []step) []string {
+	var ret []string
+
+	for _, s := range steps {
+		if len(s.Command) > 0 {
+			var args []string
+			for _, arg := range s.Command {
+				args = append(args, e.ExpandString(arg))
+			}
+
+			ret = append(ret, fmt.Sprintf("%v: run %v", label, strings.Join(args, " ")))
+		}
+
+		if s.File != "" {
+			ret = append(ret, fmt.Sprintf("%v: write %v", label, e.ExpandString(s.File)))
+		}
+
+		if s.Remove != "" {
+			ret = append(ret, fmt.Sprintf("%v: remove %v", label, e.ExpandString(s.Remove)))
+		}
+	}
+
+	for i := range ret {
+		ret[i] = cmd.Mask(ret[i])
+	}
+
+	return ret
+}
+
+// elevation tells whether installing the entry needs administrative rights, and why.
+func (e *RegistryEntry) elevation() string {
+	if e.Installer.System {
+		return "required, this is a system-level package"
+	} else if scope == installer.UserScope {
+		return "not required for a per-user installation"
+	} else if e.isPortable() {
+		return "required if the destination or the machine-wide PATH is protected"
+	}
+
+	switch installer.InstallerType(e.kind()) {
+	case installer.AppX, installer.MSIX:
+		if scope == installer.MachineScope {
+			return "required to provision the package for all users"
+		}
+
+		return "not required, the package is installed for the current user"
+	case installer.Squirrel:
+		return "not required, the installer is per-user"
+	case installer.AdvancedInstaller, installer.InnoSetup, installer.MSI, installer.NSIS:
+		return "required for a machine-wide installation"
+	}
+
+	return "depends on the installer"
+}
@@ -0,0 +1,88 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NewRegistry returns an empty registry.
+func NewRegistry() Registry {
+	return Registry{Version: registrySupportedVersion, Packages: make(map[string]RegistryEntry)}
+}
+
+// Export returns a standalone registry made of the given packages and of everything they need:
+// their dependencies, the members of groups and all the providers of capabilities, recursively.
+// Names can be given like to Expand.
+func (r *Registry) Export(names []string) (Registry, error) {
+	ret := NewRegistry()
+
+	for _, name := range names {
+		if err := r.export(name, &ret); err != nil {
+			return ret, err
+		}
+	}
+
+	return ret, nil
+}
+
+func (r *Registry) export(name string, out *Registry) error {
+	if strings.HasPrefix(name, "tag:") {
+		tagged := r.Tagged(strings.TrimPrefix(name, "tag:"))
+		if len(tagged) == 0 {
+			return fmt.Errorf("no package is tagged %v", strings.TrimPrefix(name, "tag:"))
+		}
+
+		for _, pkg := range tagged {
+			if err := r.export(pkg, out); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	entry, ok := r.Packages[name]
+	if !ok {
+		providers := r.Providers(name)
+		if len(providers) == 0 {
+			return fmt.Errorf("unknown package %v", name)
+		}
+
+		for _, provider := range providers {
+			if err := r.export(provider, out); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if _, ok := out.Packages[name]; ok {
+		return nil
+	}
+
+	out.Packages[name] = entry
+
+	for _, dependency := range append(append([]string{}, entry.Group...), entry.Depends...) {
+		if err := r.export(dependency, out); err != nil {
+			return fmt.Errorf("%v depends on %v: %v", name, dependency, err)
+		}
+	}
+
+	return nil
+}
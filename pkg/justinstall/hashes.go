@@ -0,0 +1,97 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// hashedArchs are the architectures, in the order of the registry, whose installers are hashed by
+// InstallerHashes.
+var hashedArchs = []string{"x86", "x86_64", "arm64"}
+
+// InstallerHashes downloads the installers of the entry again, for all the architectures it has
+// one for, and returns their SHA-256 hashes by architecture. The hashes in the registry are not
+// checked, so that they can be updated after a new release.
+func (e *RegistryEntry) InstallerHashes() (map[string]string, error) {
+	previous := arch
+	defer func() { arch = previous }()
+
+	ret := make(map[string]string)
+
+	for _, a := range hashedArchs {
+		if e.Installer.forArch(a).URL == "" {
+			continue
+		}
+
+		arch = a
+
+		url, err := e.installerURL(a)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", a, err)
+		}
+
+		path := e.installerPath(url)
+		if err := downloadCache.Remove(filepath.Base(path)); err != nil {
+			return nil, err
+		}
+
+		path, err = fetch.Fetch(url, e.fetchOptions(path))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", a, err)
+		}
+
+		useCached(url, path)
+
+		if ret[a], err = fetch.SHA256File(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// WriteInstallerHashes sets the sha256 of the installers of the given package, by architecture,
+// in the registry file at the given path. The rest of the file is left as it was written.
+func WriteInstallerHashes(path string, name string, hashes map[string]string) error {
+	registry, err := ReadRegistryFile(path)
+	if err != nil {
+		return err
+	}
+
+	entry, ok := registry.Packages[name]
+	if !ok {
+		return fmt.Errorf("%v is not in %v", name, path)
+	}
+
+	for a, hash := range hashes {
+		installer := entry.Installer.forArch(a)
+		if installer.URL == "" {
+			return fmt.Errorf("%v has no %v installer in %v", name, a, path)
+		}
+
+		installer.SHA256 = hash
+	}
+
+	if err := registry.Update(name, entry); err != nil {
+		return err
+	}
+
+	return WriteRegistry(path, registry)
+}
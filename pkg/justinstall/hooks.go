@@ -0,0 +1,101 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/cmd"
+)
+
+// hook is a command run right before or right after the installer: a command line run directly,
+// a cmd.exe command line or a PowerShell script. A plain string in the registry is run directly,
+// split on whitespace, as it always has been. All of them can use templates, such as
+// {{.installDir}}.
+type hook struct {
+	Command    string `json:"-"`          // Command line run directly ...
+	Cmd        string `json:"cmd"`        // ... or run by cmd.exe ...
+	PowerShell string `json:"powershell"` // ... or PowerShell script
+}
+
+// UnmarshalJSON accepts either a command line string or a JSON object.
+func (h *hook) UnmarshalJSON(data []byte) error {
+	var command string
+	if err := json.Unmarshal(data, &command); err == nil {
+		*h = hook{Command: command}
+		return nil
+	}
+
+	type plainHook hook
+	return json.Unmarshal(data, (*plainHook)(h))
+}
+
+// String returns the hook as shown to users.
+func (h hook) String() string {
+	if h.PowerShell != "" {
+		return "PowerShell: " + h.PowerShell
+	} else if h.Cmd != "" {
+		return "cmd.exe: " + h.Cmd
+	}
+
+	return h.Command
+}
+
+// run runs the hook, with its templates expanded, and returns its output.
+func (e *RegistryEntry) run(h hook) (string, error) {
+	switch {
+	case h.Cmd != "" && h.PowerShell != "":
+		return "", errors.New("hooks need either a cmd command line or a PowerShell script, not both")
+	case h.Cmd != "":
+		return cmd.OutputCmd(e.installerEnv(), e.ExpandString(h.Cmd))
+	case h.PowerShell != "":
+		return cmd.Output(e.installerEnv(), "powershell.exe", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", e.ExpandString(h.PowerShell))
+	}
+
+	// Placeholders are expanded after splitting, so that paths with spaces stay a single argument
+	args := strings.Fields(h.Command)
+	if len(args) == 0 {
+		return "", errors.New("hooks need a command line, a cmd command line or a PowerShell script")
+	}
+
+	for i := range args {
+		args[i] = e.ExpandString(args[i])
+	}
+
+	return cmd.Output(e.installerEnv(), args...)
+}
+
+// runHooks runs the given hooks in order, in the environment of the installer, stopping at the
+// first failing one. Their output is logged, prefixed with the given phase.
+func (e *RegistryEntry) runHooks(phase string, hooks []hook) error {
+	for _, h := range hooks {
+		out, err := e.run(h)
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				log.Printf("    %v: %v", phase, cmd.Mask(line))
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// MirrorRegistryName is the file name of the registry in a mirror directory.
+const MirrorRegistryName = "just-install-v4.json"
+
+// MirrorSource is the registry mirrored by default.
+const MirrorSource = registryURL
+
+// Mirror downloads the registry from the given source (a URL or a local path) along with the
+// installers of all its packages to the root directory, then writes a copy of the registry whose
+// installer URLs point to the mirrored files under the given base URL. Packages whose installers
+// cannot be mirrored are logged and keep pointing to their original location.
+func Mirror(source string, root string, baseURL string) error {
+	filesPath := filepath.Join(root, "files")
+	if err := os.MkdirAll(filesPath, 0755); err != nil {
+		return err
+	}
+
+	if strings.Contains(source, "://") || strings.HasPrefix(source, `\\`) {
+		var err error
+		source, err = fetch.Fetch(source, &fetch.Options{Destination: filepath.Join(root, "upstream.json")})
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	registry, err := parseRegistry(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse the registry: %v", err)
+	}
+
+	m := mirror{baseURL: strings.TrimSuffix(baseURL, "/"), filesPath: filesPath, files: make(map[string]bool)}
+
+	for _, name := range registry.SortedPackageNames() {
+		entry := registry.Packages[name]
+		if entry.IsGroup() {
+			continue
+		}
+
+		mirrored, err := m.entry(entry)
+		if err != nil {
+			log.Printf("WARNING: cannot mirror %v: %v", name, err)
+			continue
+		}
+
+		registry.Packages[name] = mirrored
+	}
+
+	m.prune()
+
+	return WriteRegistry(filepath.Join(root, MirrorRegistryName), registry)
+}
+
+// mirror keeps track of the files downloaded during a single Mirror run.
+type mirror struct {
+	baseURL   string
+	filesPath string
+	files     map[string]bool // Names of the files referenced by the mirrored registry
+}
+
+// entry mirrors the installers of all release channels of an entry and returns a copy pointing to
+// them. Versions are pinned, since the mirrored files are those of a specific version.
+func (m *mirror) entry(e RegistryEntry) (RegistryEntry, error) {
+	ret := e
+	ret.Channels = nil
+	ret.Versions = nil // Only the current version of each channel is mirrored
+
+	for _, channel := range e.ChannelNames() {
+		variant, err := e.WithChannel(channel)
+		if err != nil {
+			return e, err
+		}
+
+		variant, err = variant.WithLatestVersion()
+		if err != nil {
+			return e, err
+		}
+
+		for _, target := range []*archInstaller{&variant.Installer.Arm64, &variant.Installer.X86, &variant.Installer.X86_64} {
+			if target.URL == "" {
+				continue
+			}
+
+			rawurl, err := ResolveURL(variant.ExpandString(target.URL))
+			if err != nil {
+				return e, err
+			}
+
+			if target.URL, err = m.file(rawurl, target.SHA256); err != nil {
+				return e, err
+			}
+
+			if target.Signature != "" {
+				rawurl, err := ResolveURL(variant.ExpandString(target.Signature))
+				if err != nil {
+					return e, err
+				}
+
+				if target.Signature, err = m.file(rawurl, ""); err != nil {
+					return e, err
+				}
+			}
+		}
+
+		if channel == DefaultChannel {
+			ret.Scrape = nil
+			ret.Version = variant.Version
+			ret.VersionSource = nil
+			ret.Installer = variant.Installer
+			continue
+		}
+
+		if ret.Channels == nil {
+			ret.Channels = make(map[string]channelEntry)
+		}
+		ret.Channels[channel] = channelEntry{Version: variant.Version, Installer: variant.Installer}
+	}
+
+	return ret, nil
+}
+
+// file downloads the given URL to the mirror, unless already there, and returns the URL clients
+// should use to download it. The download is verified against the given checksum, if any.
+func (m *mirror) file(rawurl string, checksum string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	name := crc32s(rawurl) + filepath.Ext(u.Path)
+	dest := filepath.Join(m.filesPath, name)
+
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		log.Println("Mirroring", rawurl)
+
+		if _, err := fetch.Fetch(rawurl, &fetch.Options{Destination: dest}); err != nil {
+			return "", err
+		}
+
+		if err := fetch.VerifySHA256(dest, checksum); err != nil {
+			os.Remove(dest)
+			return "", err
+		}
+	}
+
+	m.files[name] = true
+
+	return m.baseURL + "/files/" + name, nil
+}
+
+// prune removes the mirrored files that are no longer referenced by the registry.
+func (m *mirror) prune() {
+	infos, err := ioutil.ReadDir(m.filesPath)
+	if err != nil {
+		log.Println("WARNING: cannot prune the mirror:", err)
+		return
+	}
+
+	for _, info := range infos {
+		if m.files[info.Name()] {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(m.filesPath, info.Name())); err != nil {
+			log.Println("WARNING: cannot prune the mirror:", err)
+		}
+	}
+}
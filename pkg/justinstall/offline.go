@@ -0,0 +1,89 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// SetOffline makes future operations run without network access, taking the installers and other
+// files they need from the download cache or, if not empty, from the given mirror directory (see
+// Mirror). Sharing the download cache with peers is disabled as well.
+func SetOffline(mirrorDir string) {
+	var dirs []string
+	if mirrorDir != "" {
+		dirs = append(dirs, mirrorDir)
+	}
+
+	fetch.SetOffline(true, dirs...)
+	cfg.PeerCache = false
+}
+
+// IsOffline returns whether network access is disabled (see SetOffline).
+func IsOffline() bool {
+	return fetch.IsOffline()
+}
+
+// OfflineMissing returns the URLs of the files needed to install the entry that are neither in the
+// download cache nor in the mirror directory given to SetOffline. An error is returned when the
+// installer URL cannot be resolved without network access.
+func (e *RegistryEntry) OfflineMissing() ([]string, error) {
+	url, err := e.installerURL(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	archInstaller, err := e.archInstaller(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+
+	if !e.availableOffline(url, archInstaller.SHA256) {
+		ret = append(ret, url)
+	}
+
+	if archInstaller.Signature != "" {
+		signatureURL, err := ResolveURL(e.ExpandString(archInstaller.Signature))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := fetch.Local(signatureURL, e.fetchOptions(tempFilePath(signatureURL, ""))); !ok {
+			ret = append(ret, signatureURL)
+		}
+	}
+
+	return ret, nil
+}
+
+// availableOffline returns whether the installer at the given URL, or one of its mirrors, can be
+// found offline, possibly in the download cache under another URL thanks to its checksum.
+func (e *RegistryEntry) availableOffline(url string, checksum string) bool {
+	if _, ok := downloadCache.Lookup(checksum); ok {
+		return true
+	}
+
+	options := e.fetchOptions(e.installerPath(url))
+	for _, source := range append([]string{url}, e.mirrorURLs()...) {
+		if _, ok := fetch.Local(source, options); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,180 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/peer"
+)
+
+// defaultPeerPort is the TCP port the download cache is shared on, unless configured otherwise.
+const defaultPeerPort = 47047
+
+// peerDiscoveryTimeout is how long we wait for other instances to answer a discovery query.
+const peerDiscoveryTimeout = time.Second
+
+// peerIndexPath is the file mapping the SHA-256 hashes of downloaded installers to the names of the
+// files in the temporary directory, which is how peers ask for a file.
+var peerIndexPath = filepath.Join(tempPath, "peer-index.json")
+
+var (
+	peers         []peer.Peer
+	peersOnce     sync.Once
+	peerCacheOnce sync.Once
+)
+
+// startPeerCache shares the download cache with other instances on the local network, if enabled
+// in the configuration, the first time it is called. The cache is shared in the background until
+// the process exits.
+func startPeerCache() {
+	peerCacheOnce.Do(func() {
+		if !cfg.PeerCache {
+			return
+		}
+
+		if err := servePeerCache(); err != nil {
+			log.Println("WARNING: cannot share the download cache with peers:", err)
+		}
+	})
+}
+
+// servePeerCache listens for the requests of peers and advertises the download cache to them.
+func servePeerCache() error {
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", peerPort()))
+	if err != nil {
+		return err
+	}
+
+	go http.Serve(listener, http.HandlerFunc(servePeerFile))
+	go func() {
+		if err := peer.Advertise(peerInstance(), peerPort(), nil); err != nil {
+			log.Println("WARNING: cannot advertise the download cache:", err)
+		}
+	}()
+
+	return nil
+}
+
+func peerPort() int {
+	if cfg.PeerPort != 0 {
+		return cfg.PeerPort
+	}
+
+	return defaultPeerPort
+}
+
+// peerInstance returns the name this instance advertises itself with. The process ID makes it
+// unique even among machines cloned from the same image.
+func peerInstance() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "just-install"
+	}
+
+	return fmt.Sprintf("%v-%d", strings.ToLower(hostname), os.Getpid())
+}
+
+// servePeerFile serves a file of the download cache to a peer, given its SHA-256 hash.
+func servePeerFile(w http.ResponseWriter, r *http.Request) {
+	name, ok := loadPeerIndex()[strings.TrimPrefix(r.URL.Path, "/cache/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, downloadCache.Path(filepath.Base(name)))
+}
+
+// fetchFromPeers tries to download the installer with the given SHA-256 hash from the cache of the
+// other instances on the local network, and reports whether it succeeded. Peers are not
+// authenticated, so installers without a hash are never asked to them, and the caller must verify
+// the file before sharing it in turn.
+func fetchFromPeers(checksum string, destinationPath string) bool {
+	if !cfg.PeerCache || checksum == "" {
+		return false
+	}
+
+	peersOnce.Do(func() {
+		found, err := peer.Discover(peerDiscoveryTimeout)
+		if err != nil {
+			log.Println("WARNING: cannot discover peers:", err)
+		}
+
+		self := peerInstance()
+		for _, p := range found {
+			if p.Instance != self {
+				peers = append(peers, p)
+			}
+		}
+	})
+
+	for _, p := range peers {
+		peerURL := fmt.Sprintf("http://%v/cache/%v", p.Addr, strings.ToLower(checksum))
+
+		if _, err := fetch.Fetch(peerURL, &fetch.Options{Destination: destinationPath, Progress: !unattended}); err != nil {
+			continue
+		}
+
+		log.Println("Downloaded from peer", p.Instance)
+
+		return true
+	}
+
+	return false
+}
+
+// sharePeerFile makes a downloaded file, which was verified to have the given SHA-256 hash,
+// available to peers.
+func sharePeerFile(checksum string, path string) {
+	if !cfg.PeerCache || checksum == "" || filepath.Dir(path) != downloadCache.Dir() {
+		return
+	}
+
+	index := loadPeerIndex()
+	index[strings.ToLower(checksum)] = filepath.Base(path)
+
+	data, err := json.Marshal(index)
+	if err == nil {
+		err = ioutil.WriteFile(peerIndexPath, data, 0600)
+	}
+
+	if err != nil {
+		log.Println("WARNING: cannot share the download with peers:", err)
+	}
+}
+
+func loadPeerIndex() map[string]string {
+	ret := make(map[string]string)
+
+	if data, err := ioutil.ReadFile(peerIndexPath); err == nil {
+		json.Unmarshal(data, &ret)
+	}
+
+	return ret
+}
@@ -0,0 +1,217 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/just-install/just-install/pkg/cmd"
+	"github.com/just-install/just-install/pkg/installer"
+	dry "github.com/ungerik/go-dry"
+)
+
+// shortcut is a Start Menu shortcut created for a portable program.
+type shortcut struct {
+	Path   string
+	Target string
+}
+
+// isPortable returns whether the entry is a portable program, copied or extracted to its
+// destination instead of being installed by an installer.
+func (e *RegistryEntry) isPortable() bool {
+	kind := e.kind()
+
+	return kind == "copy" || kind == "zip"
+}
+
+// installPortable copies the file at the given path to the destination of the entry, or extracts
+// it there for zip entries, then creates the Start Menu shortcuts of the entry and adds it to PATH.
+func (e *RegistryEntry) installPortable(path string) error {
+	destination, err := e.destination()
+	if err != nil {
+		return err
+	}
+
+	if e.kind() == "zip" {
+		log.Println("Extracting to", destination)
+
+		if err := installer.ExtractZIPStrip(path, destination, e.stripComponents()); err != nil {
+			return err
+		}
+	} else {
+		log.Println("Copying to", destination)
+
+		if err := os.MkdirAll(filepath.Dir(destination), 0700); err != nil {
+			return err
+		}
+
+		if err := dry.FileCopy(path, destination); err != nil {
+			return err
+		}
+	}
+
+	shortcuts, err := e.shortcuts(destination)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range shortcuts {
+		log.Printf("Creating shortcut for %s (%s)\n", s.Target, s.Path)
+
+		if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+			return err
+		}
+
+		if err := cmd.Run(installer.ShortcutCommand(s.Path, s.Target)...); err != nil {
+			return fmt.Errorf("cannot create the shortcut %v: %v", s.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// uninstallPortable undoes installPortable: it deletes its Start Menu shortcuts and then its
+// destination.
+func (e *RegistryEntry) uninstallPortable() error {
+	destination, err := e.destination()
+	if err != nil {
+		return err
+	}
+
+	shortcuts, err := e.shortcuts(destination)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range shortcuts {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	log.Println("Removing", destination)
+
+	if e.kind() == "zip" {
+		return os.RemoveAll(destination)
+	}
+
+	if err := os.Remove(destination); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// destination returns where copy entries copy their file to, and where zip entries extract their
+// archive to, with its placeholders expanded.
+func (e *RegistryEntry) destination() (string, error) {
+	destination, ok := e.Installer.options()["destination"].(string)
+	if !ok || destination == "" {
+		return "", fmt.Errorf("%v installers need a destination option", e.kind())
+	}
+
+	return e.ExpandString(os.ExpandEnv(destination)), nil
+}
+
+// installDir returns the directory the program ends up in, given the destination of the entry.
+func (e *RegistryEntry) installDir(destination string) string {
+	if e.kind() == "zip" {
+		return destination
+	}
+
+	return filepath.Dir(destination)
+}
+
+// stripComponents returns how many leading directories to remove from the names of the files in
+// the archive of zip entries.
+func (e *RegistryEntry) stripComponents() int {
+	if n, ok := e.Installer.options()["stripComponents"].(float64); ok && n > 0 {
+		return int(n)
+	}
+
+	return 0
+}
+
+// shortcuts returns the Start Menu shortcuts of the entry, sorted by name. Their targets are
+// relative to the directory of the program unless absolute.
+func (e *RegistryEntry) shortcuts(destination string) ([]shortcut, error) {
+	option, ok := e.Installer.options()["shortcuts"]
+	if !ok {
+		return nil, nil
+	}
+
+	targets, ok := option.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the shortcuts option must map the names of the shortcuts to their targets")
+	}
+
+	var names []string
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ret []shortcut
+	for _, name := range names {
+		target, ok := targets[name].(string)
+		if !ok {
+			return nil, fmt.Errorf("the target of the shortcut %v must be a string", name)
+		}
+
+		target = e.ExpandString(target)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(e.installDir(destination), target)
+		}
+
+		ret = append(ret, shortcut{Path: filepath.Join(startMenuPath(), e.ExpandString(name)+".lnk"), Target: target})
+	}
+
+	return ret, nil
+}
+
+// pathDirectory returns the directory the addToPath option asks to add to PATH: the directory of
+// the program when true, or the given directory relative to it. It is added along with those of
+// the addToPath key of the entry.
+func (e *RegistryEntry) pathDirectory(destination string) (string, bool) {
+	switch v := e.Installer.options()["addToPath"].(type) {
+	case bool:
+		return e.installDir(destination), v
+	case string:
+		dir := e.ExpandString(v)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(e.installDir(destination), dir)
+		}
+
+		return dir, true
+	}
+
+	return "", false
+}
+
+// startMenuPath returns the Programs folder of the Start Menu of the current user for the user
+// scope, or the one shared by all users otherwise.
+func startMenuPath() string {
+	if scope == installer.UserScope {
+		return os.ExpandEnv(`${APPDATA}\Microsoft\Windows\Start Menu\Programs`)
+	}
+
+	return os.ExpandEnv(`${ProgramData}\Microsoft\Windows\Start Menu\Programs`)
+}
@@ -0,0 +1,145 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	dry "github.com/ungerik/go-dry"
+)
+
+// parallelDownloads returns how many installers Prefetch downloads at once, as configured.
+func parallelDownloads() int {
+	if cfg.ParallelDownloads <= 0 {
+		return fetch.DefaultParallelism
+	}
+
+	return cfg.ParallelDownloads
+}
+
+// Prefetch downloads the installers of the given entries that are not in the download cache yet,
+// several at a time, so that installing a list of packages doesn't wait for each download in
+// turn. Failed downloads are only reported, DownloadInstaller tries them again. Nothing is
+// verified here either, DownloadInstaller verifies the cached files.
+func Prefetch(entries []RegistryEntry) {
+	// Peers are asked for installers one at a time by DownloadInstaller
+	if parallelDownloads() < 2 || cfg.PeerCache {
+		return
+	}
+
+	prefetch(entries, 2)
+}
+
+// PrefetchArch downloads the installers of the given entries for the given architecture (or the
+// one they fall back to) to the download cache without running anything, for preparing offline
+// media (see SetOffline) and lab images. The architecture doesn't have to be the one of this
+// machine. Installers already in the cache are kept, and all of them are verified. It returns the
+// paths of the installers and the errors of the entries that could not be downloaded.
+func PrefetchArch(entries []RegistryEntry, target string) ([]string, []error) {
+	if _, ok := archFallbacks[target]; !ok {
+		return nil, []error{fmt.Errorf("unknown architecture: %v", target)}
+	}
+
+	previous := arch
+	arch = target
+	defer func() { arch = previous }()
+
+	prefetch(entries, 1)
+
+	var paths []string
+	var errs []error
+
+	for i := range entries {
+		e := &entries[i]
+
+		url, err := e.installerURL(arch)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		checksum := ""
+		if archInstaller, err := e.archInstaller(arch); err == nil {
+			checksum = archInstaller.SHA256
+		}
+
+		path := e.installerPath(url)
+		if !dry.FileExists(path) {
+			errs = append(errs, fmt.Errorf("cannot download %v", url))
+			continue
+		} else if err := e.verify(path, checksum); err != nil {
+			quarantine(path)
+			errs = append(errs, err)
+			continue
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, errs
+}
+
+// prefetch downloads the installers of the given entries that are not in the download cache yet,
+// if there are at least minRequests of them.
+func prefetch(entries []RegistryEntry, minRequests int) {
+	parallelism := parallelDownloads()
+
+	var requests []fetch.Request
+
+	for i := range entries {
+		e := &entries[i]
+
+		url, err := e.installerURL(arch)
+		if err != nil {
+			continue
+		}
+
+		path := e.installerPath(url)
+		if dry.FileExists(path) {
+			continue
+		}
+
+		requests = append(requests, fetch.Request{
+			Resources: append([]string{url}, e.mirrorURLs()...),
+			Options:   e.fetchOptions(path),
+		})
+	}
+
+	if len(requests) == 0 || len(requests) < minRequests {
+		return
+	}
+
+	log.Printf("Downloading %d installers, %d at a time", len(requests), parallelism)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	results := fetch.FetchAllContext(ctx, requests, &fetch.Options{Parallelism: parallelism, Progress: !unattended})
+	if ctx.Err() != nil {
+		log.Fatalln("Download interrupted")
+	}
+
+	for i, result := range results {
+		if result.Err != nil {
+			log.Println("WARNING:", result.Err)
+			continue
+		}
+
+		useCached(requests[i].Resources[0], result.Path)
+	}
+}
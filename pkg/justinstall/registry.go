@@ -12,23 +12,54 @@ import (
 	"strings"
 	"time"
 
+	"github.com/just-install/just-install/pkg/cache"
 	"github.com/just-install/just-install/pkg/cmd"
+	"github.com/just-install/just-install/pkg/config"
+	"github.com/just-install/just-install/pkg/detect"
+	"github.com/just-install/just-install/pkg/fetch"
 	"github.com/just-install/just-install/pkg/installer"
+	"github.com/just-install/just-install/pkg/journal"
+	"github.com/just-install/just-install/pkg/secret"
+	"github.com/just-install/just-install/pkg/signature"
+	"github.com/just-install/just-install/pkg/state"
+	versions "github.com/just-install/just-install/pkg/version"
+	"github.com/just-install/just-install/pkg/win32ui"
 	dry "github.com/ungerik/go-dry"
 )
 
 const (
 	registrySupportedVersion = 4
 	registryURL              = "https://just-install.github.io/registry/just-install-v4.json"
+
+	// defaultRegistryMaxAge is how long, in hours, the downloaded registry is used before checking
+	// for a new one when the configuration doesn't say otherwise.
+	defaultRegistryMaxAge = 24
 )
 
 var (
-	arch         = "x86"
-	isAmd64      = false
-	shimsPath    = os.ExpandEnv("${SystemDrive}\\Shims")
-	shimsPathOld = os.ExpandEnv("${SystemDrive}\\just-install")
-	tempPath     = filepath.Join(os.TempDir(), "just-install")
-	registryPath = filepath.Join(tempPath, fmt.Sprintf("just-install-v%v.json", registrySupportedVersion))
+	arch            = "x86"
+	cfg             = &config.Config{}
+	installerEnv    []string
+	interactive     = false
+	isAmd64         = false
+	isArm64         = false
+	x64Emulation    = true
+	refreshRegistry = false
+	restorePoints   = false
+	scope           = installer.DefaultScope
+	shimsPath       = os.ExpandEnv("${SystemDrive}\\Shims")
+	shimsPathOld    = os.ExpandEnv("${SystemDrive}\\just-install")
+	tempPath        = filepath.Join(os.TempDir(), "just-install")
+	downloadCache   = cache.New(tempPath)
+	unattended      = false
+	dataDir         = defaultDataPath()
+	variables       = make(map[string]string)
+	registryPath    = filepath.Join(tempPath, fmt.Sprintf("just-install-v%v.json", registrySupportedVersion))
+	secretsPath     = filepath.Join(dataDir, "secrets", "secrets.json")
+	statePath       = filepath.Join(dataDir, "state.json")
+	configPath      = filepath.Join(dataDir, "config.json")
+	journalPath     = filepath.Join(dataDir, "journal.jsonl")
+	overridesPath   = filepath.Join(dataDir, "overrides.json")
 )
 
 //
@@ -36,18 +67,61 @@ var (
 //
 
 func init() {
-	createTempDir()
 	determineArch()
 	normalizeProgramFiles()
 }
 
 func createTempDir() {
-	os.MkdirAll(tempPath, 0700)
+	os.MkdirAll(downloadCache.Dir(), 0700)
+	ioutil.WriteFile(filepath.Join(tempPath, cacheMarker), nil, 0600)
+}
+
+// defaultDataPath returns the machine-wide directory where just-install keeps persistent data. It
+// falls back to the temporary directory when %ProgramData% is not available.
+func defaultDataPath() string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		return tempPath
+	}
+
+	return filepath.Join(programData, "just-install")
+}
+
+// setPaths moves the temporary directory (which is also the download cache) and the directory of
+// persistent data, updating all the paths derived from them.
+func setPaths(temp string, data string) {
+	tempPath = temp
+	dataDir = data
+
+	downloadCache = cache.New(tempPath)
+
+	registryPath = filepath.Join(tempPath, fmt.Sprintf("just-install-v%v.json", registrySupportedVersion))
+	secretsPath = filepath.Join(dataDir, "secrets", "secrets.json")
+	statePath = filepath.Join(dataDir, "state.json")
+	configPath = filepath.Join(dataDir, "config.json")
+	journalPath = filepath.Join(dataDir, "journal.jsonl")
+	overridesPath = filepath.Join(dataDir, "overrides.json")
+	quarantinePath = filepath.Join(dataDir, "quarantine")
+
+	peerIndexPath = filepath.Join(tempPath, "peer-index.json")
+	releaseCachePath = filepath.Join(tempPath, "releases.json")
+	scrapeCachePath = filepath.Join(tempPath, "scraped-versions.json")
 }
 
 // determineArch determines the Windows architecture of the current Windows installation. It changes
-// both the "isAmd64" and "arch" globals.
+// the "isAmd64", "isArm64", "x64Emulation" and "arch" globals.
 func determineArch() {
+	// Windows on ARM reports its native architecture to both native and emulated processes, the
+	// latter through PROCESSOR_ARCHITEW6432. ARM64 editions of Windows 11 also run x86_64 programs
+	// (emulated), Windows 10 only x86 ones.
+	if os.Getenv("PROCESSOR_ARCHITECTURE") == "ARM64" || os.Getenv("PROCESSOR_ARCHITEW6432") == "ARM64" {
+		arch = "arm64"
+		isAmd64 = true
+		isArm64 = true
+		x64Emulation = hasX64Emulation()
+		return
+	}
+
 	// Since our output is a 32-bit executable (for maximum compatibility) and all other options
 	// proved fruitless, let's just test for something that is usually available only on x86_64
 	// editions of Windows.
@@ -101,7 +175,11 @@ func CleanTempDir() error {
 func SetArchitecture(a string) error {
 	if a == "x86_64" && !isAmd64 {
 		return errors.New("This machine is not 64-bit capable")
-	} else if a != "x86" && a != "x86_64" {
+	} else if a == "x86_64" && !x64Emulation {
+		return errors.New("This version of Windows cannot run x86_64 programs on ARM64")
+	} else if a == "arm64" && !isArm64 {
+		return errors.New("This machine is not ARM64 capable")
+	} else if _, ok := archFallbacks[a]; !ok {
 		return fmt.Errorf("Unknown architecture: %v", a)
 	}
 
@@ -110,19 +188,250 @@ func SetArchitecture(a string) error {
 	return nil
 }
 
+// SetPortable keeps the download cache, the configuration, the state database and the shims in
+// the given directory (usually the one of the executable), so that nothing is written to the user
+// profile or to machine-wide locations. It must be called before LoadConfig.
+func SetPortable(dir string) {
+	shimsPath = filepath.Join(dir, "shims")
+	setPaths(filepath.Join(dir, "cache"), filepath.Join(dir, "data"))
+}
+
+// LoadConfig reads the configuration file from the just-install data directory.
+func LoadConfig() *config.Config {
+	ret, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Unable to read the configuration file %v: %v", configPath, err)
+	}
+
+	return ret
+}
+
+// Configure applies the given configuration to future operations. The temporary directory is created
+// here rather than at initialization, so that portable mode never touches the default one.
+func Configure(c *config.Config) {
+	cfg = c
+
+	if c.CacheDir != "" {
+		if err := SetCacheDir(c.CacheDir); err != nil {
+			log.Fatalln("Invalid cache directory:", err)
+		}
+	}
+
+	maxBytesPerSecond = 0
+	if c.LimitRate != "" {
+		rate, err := fetch.ParseRate(c.LimitRate)
+		if err != nil {
+			log.Fatalln("Invalid download rate limit:", err)
+		}
+
+		maxBytesPerSecond = rate
+	}
+
+	if c.CABundle != "" {
+		if err := fetch.SetCABundle(c.CABundle); err != nil {
+			log.Fatalln("Cannot load the CA bundle:", err)
+		}
+	}
+
+	if c.Downloader != "" && c.Downloader != fetch.DownloaderHTTP && c.Downloader != fetch.DownloaderBITS {
+		log.Fatalf("Unknown downloader %q, expected %v or %v", c.Downloader, fetch.DownloaderHTTP, fetch.DownloaderBITS)
+	}
+
+	if err := fetch.SetProgressStyle(c.Progress); err != nil {
+		log.Fatalln("Invalid progress setting:", err)
+	}
+
+	fetch.SetTransportLimits(fetch.TransportLimits{
+		MaxConnsPerHost: c.MaxConnsPerHost,
+		IdleConnTimeout: time.Duration(c.IdleConnTimeout) * time.Second,
+	})
+
+	fetch.SetGitHubToken(gitHubToken())
+	fetch.SetHostHeaders(expandHostHeaders(c.Headers))
+	fetch.SetObjectStorage(fetch.ObjectStorageSettings{
+		S3AccessKeyID:     c.S3AccessKeyID,
+		S3SecretAccessKey: expandEnvReferences(c.S3SecretAccessKey),
+		S3Profile:         c.S3Profile,
+		S3Region:          c.S3Region,
+		S3Endpoint:        c.S3Endpoint,
+		AzureAccount:      c.AzureStorageAccount,
+		AzureSASToken:     expandEnvReferences(c.AzureStorageSASToken),
+	})
+	fetch.SetUserAgent(c.UserAgent)
+
+	if err := fetch.SetProxy(fetch.ProxySettings{
+		URL:      c.Proxy,
+		NoProxy:  c.ProxyBypass,
+		Auth:     c.ProxyAuth,
+		Username: c.ProxyUser,
+		Password: c.ProxyPassword,
+	}); err != nil {
+		log.Fatalln("Invalid proxy settings:", err)
+	}
+
+	createTempDir()
+}
+
+// SetInstallerEnv sets additional "KEY=VALUE" environment variables for future installer processes.
+// They take precedence over the ones defined by registry entries.
+func SetInstallerEnv(env []string) error {
+	for _, v := range env {
+		if !strings.Contains(v, "=") || strings.HasPrefix(v, "=") {
+			return fmt.Errorf("Invalid environment variable, expected KEY=VALUE: %v", v)
+		}
+	}
+
+	installerEnv = env
+
+	return nil
+}
+
+// SetScope sets whom future installations are for, "machine" or "user". The empty string leaves it
+// to each installer.
+func SetScope(s string) error {
+	if !installer.Scope(s).IsValid() {
+		return fmt.Errorf("Unknown installation scope: %v", s)
+	}
+
+	scope = installer.Scope(s)
+
+	return nil
+}
+
+// SetRefreshRegistry makes future operations check for a new official registry even if the
+// downloaded one is not older than the configured registryMaxAge.
+func SetRefreshRegistry(refresh bool) {
+	refreshRegistry = refresh
+}
+
+// SetUnattended disables progress bars, so that the output of future operations is suitable for
+// logs of unattended runs.
+func SetUnattended(enable bool) {
+	unattended = enable
+}
+
+// SetVariable sets a single variable, like SetVariables.
+func SetVariable(name string, value string) {
+	variables[name] = value
+}
+
+// SetVariables sets "KEY=VALUE" variables that future installations can use in the templates of
+// registry entries, as {{.KEY}}.
+func SetVariables(vars []string) error {
+	ret := make(map[string]string)
+
+	for _, v := range vars {
+		split := strings.SplitN(v, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return fmt.Errorf("Invalid variable, expected KEY=VALUE: %v", v)
+		}
+
+		ret[split[0]] = split[1]
+	}
+
+	variables = ret
+
+	return nil
+}
+
+// SetInteractive makes future installations run the user interface of installers, with their
+// interactive arguments if any, so that users can customize the installation.
+func SetInteractive(enabled bool) {
+	interactive = enabled
+}
+
+// SetRestorePoints enables the creation of a System Restore point before running the installers of
+// system-level packages, in addition to the configuration file setting.
+func SetRestorePoints(enabled bool) {
+	restorePoints = enabled
+}
+
+// Arch returns the architecture used for future package installations.
+func Arch() string {
+	return arch
+}
+
+// LoadState loads the database of packages installed by just-install on this machine.
+func LoadState() *state.State {
+	ret, err := state.Load(statePath)
+	if err != nil {
+		log.Fatalln("Unable to read the state database:", err)
+	}
+
+	return ret
+}
+
+// LoadJournal loads the history of the installations performed on this machine.
+func LoadJournal() []journal.Entry {
+	ret, err := journal.Read(journalPath)
+	if err != nil {
+		log.Fatalln("Unable to read the journal:", err)
+	}
+
+	return ret
+}
+
+// AppendJournal adds an entry to the history of the installations performed on this machine.
+func AppendJournal(entry journal.Entry) error {
+	return journal.Append(journalPath, entry)
+}
+
+// LoadSecrets loads the store of secrets kept on this machine.
+func LoadSecrets() *secret.Store {
+	ret, err := secret.Load(secretsPath)
+	if err != nil {
+		log.Fatalln("Unable to read the secret store:", err)
+	}
+
+	return ret
+}
+
+// lookupSecret returns the value of a stored secret, for use in templates as {{secret "name"}}. The
+// value is hidden from logged command lines.
+func lookupSecret(name string) (string, error) {
+	store, err := secret.Load(secretsPath)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := store.Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	cmd.Redact(value)
+
+	return value, nil
+}
+
 // SmartLoadRegistry tries to load a cached copy downloaded from the Internet. If neither is
-// available, it tries to download it from the known location first.
+// available, it tries to download it from the known location first. Local overrides are applied,
+// see applyOverrides.
 func SmartLoadRegistry(force bool) Registry {
-	download := !dry.FileExists(registryPath)
-	download = download || dry.FileTimeModified(registryPath).Before(time.Now().Add(-24*time.Hour))
-	download = download || force
+	ret := smartLoadRegistry(force)
+	applyOverrides(&ret)
+
+	return ret
+}
+
+// smartLoadRegistry is SmartLoadRegistry, without the local overrides.
+func smartLoadRegistry(force bool) Registry {
+	force = force || refreshRegistry
+	download := force || registryExpired()
+
+	// Offline, the registry can only come from a mirror when there is no local copy
+	if fetch.IsOffline() && dry.FileExists(registryPath) {
+		download = false
+	}
 
 	if download {
 		log.Println("Updating registry from:", registryURL)
 
-		downloadRegistry()
+		downloadRegistry(force)
 	}
 
+	verifyRegistry()
+
 	return LoadRegistry(registryPath)
 }
 
@@ -133,22 +442,174 @@ func LoadRegistry(path string) Registry {
 		log.Fatalf("Unable to read the registry file.")
 	}
 
+	ret, err := parseRegistry(data)
+	if err == errUnsupportedRegistry {
+		log.Fatalln("Please update to a new version of just-install by running: msiexec.exe /i https://just-install.github.io/stable/just-install.msi")
+	} else if err != nil {
+		log.Fatalln("Unable to parse the registry file.")
+	}
+
+	return ret
+}
+
+var errUnsupportedRegistry = errors.New("unsupported registry version")
+
+// parseRegistry unmarshals the registry from its JSON representation.
+func parseRegistry(data []byte) (Registry, error) {
 	var ret Registry
 
 	if err := json.Unmarshal(data, &ret); err != nil {
-		log.Fatalln("Unable to parse the registry file.")
+		return ret, err
 	}
 
 	if ret.Version != registrySupportedVersion {
-		log.Fatalln("Please update to a new version of just-install by running: msiexec.exe /i https://just-install.github.io/stable/just-install.msi")
+		return ret, errUnsupportedRegistry
+	}
+
+	if source, err := newRegistrySource(data); err == nil {
+		ret.source = source
+	}
+
+	return ret, nil
+}
+
+// registryTimestampPath returns the path of the file recording when the official registry was
+// last downloaded or found unchanged on the server. Unlike the modification time of the registry,
+// it isn't carried along when the download cache is copied or restored.
+func registryTimestampPath() string {
+	return registryPath + ".timestamp"
+}
+
+// registryExpired reports whether the downloaded registry is missing or older than the configured
+// registryMaxAge.
+func registryExpired() bool {
+	if !dry.FileExists(registryPath) {
+		return true
+	}
+
+	maxAge := cfg.RegistryMaxAge
+	if maxAge < 0 {
+		return false
+	} else if maxAge == 0 {
+		maxAge = defaultRegistryMaxAge
+	}
+
+	data, err := ioutil.ReadFile(registryTimestampPath())
+	if err != nil {
+		return true
+	}
+
+	checked, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return true
+	}
+
+	return time.Since(checked) > time.Duration(maxAge)*time.Hour
+}
+
+// Downloads the registry, and its signature when it can be verified, from the canonical URL. An
+// existing copy is only downloaded again if it changed on the server, unless forced, and is kept
+// as the previous registry when it did.
+func downloadRegistry(force bool) {
+	if previous, err := ioutil.ReadFile(registryPath); err == nil {
+		defer keepPreviousRegistry(previous)
+	}
+
+	if force || !dry.FileExists(registryPath) {
+		download(registryURL, registryPath)
+	} else {
+		revalidate(registryURL, fetchOptions(registryPath))
+	}
+
+	if err := ioutil.WriteFile(registryTimestampPath(), []byte(time.Now().Format(time.RFC3339)), 0600); err != nil {
+		log.Println("WARNING: cannot record when the registry was downloaded:", err)
 	}
 
+	if registryPublicKey != "" {
+		if err := downloadRegistrySignature(); err != nil {
+			log.Println("WARNING: cannot download the signature of the registry:", err)
+		}
+	}
+}
+
+// FetchRegistry downloads a custom registry from the given URL, with any of the schemes supported
+// for installers, and returns the path of the local copy.
+func FetchRegistry(rawurl string) string {
+	return downloadExt(rawurl, ".json", true)
+}
+
+// DefaultRegistry stands for the official registry in the lists of registries of LoadRegistries.
+const DefaultRegistry = "default"
+
+// LoadRegistries loads the given registries, local paths or URLs, and merges them in order: the
+// entries of a registry replace the same-named entries of the ones before it. DefaultRegistry is
+// loaded as SmartLoadRegistry does, so that a company registry can add and override entries while
+// still inheriting the official ones. Local overrides are applied last, see applyOverrides.
+func LoadRegistries(sources []string) Registry {
+	ret := Registry{Version: registrySupportedVersion, Packages: make(map[string]RegistryEntry)}
+
+	for _, source := range sources {
+		var registry Registry
+		if source == DefaultRegistry {
+			registry = smartLoadRegistry(false)
+		} else {
+			registry = loadCustomRegistry(source)
+		}
+
+		for name, entry := range registry.Packages {
+			ret.Packages[name] = entry
+		}
+	}
+
+	applyOverrides(&ret)
+
 	return ret
 }
 
-// Downloads the registry from the canonical URL.
-func downloadRegistry() {
-	download(registryURL, registryPath)
+// applyOverrides replaces the entries of the given registry with the same-named ones of the
+// overrides.json file in the data directory, if any, so that a broken entry can be fixed locally
+// without waiting for a new registry. The file has the format of the registry itself.
+func applyOverrides(r *Registry) {
+	data, err := ioutil.ReadFile(overridesPath)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Fatalln("Unable to read the registry overrides:", err)
+	}
+
+	overrides, err := parseRegistry(data)
+	if err != nil {
+		log.Fatalf("Unable to parse the registry overrides in %v: %v", overridesPath, err)
+	}
+
+	if r.Packages == nil {
+		r.Packages = make(map[string]RegistryEntry)
+	}
+
+	for _, name := range overrides.SortedPackageNames() {
+		log.Printf("Using the local override of %v from %v", name, overridesPath)
+		r.Packages[name] = overrides.Packages[name]
+	}
+}
+
+// Registries returns the registries given in the configuration, see LoadRegistries.
+func Registries() []string {
+	return cfg.Registries
+}
+
+// loadCustomRegistry loads a registry other than the official one from a local path or a URL.
+func loadCustomRegistry(source string) Registry {
+	path := source
+	if strings.Contains(path, "://") || strings.HasPrefix(path, `\\`) {
+		path = FetchRegistry(path)
+	}
+
+	if !dry.FileExists(path) {
+		log.Fatalf("%v: no such file.\n", path)
+	}
+
+	log.Println("Loading custom registry at", path)
+	return LoadRegistry(path)
 }
 
 //
@@ -156,19 +617,120 @@ func downloadRegistry() {
 //
 
 type installerEntry struct {
-	Interactive bool
-	Kind        string
-	Options     map[string]interface{} // Optional
-	Preinstall  []string // Optional
-	Postinstall []string // Optional
-	X86         string
-	X86_64      string
+	Activate             []step            // Optional
+	AfterUninstall       []step            // Optional
+	AfterUpgrade         []step            // Optional
+	Authenticode         bool              // Optional, requires a valid Authenticode signature on the installer
+	BeforeUninstall      []step            // Optional
+	BeforeUpgrade        []step            // Optional
+	Env                  map[string]string // Optional
+	Headers              map[string]string // Optional, sent with the requests downloading the installer
+	InsecureRedirects    bool              // Optional, allows HTTPS downloads to be redirected to plain HTTP
+	InstalledSize        int               // Optional, megabytes taken by the installed program
+	Interactive          bool
+	InteractiveArguments []string // Optional, command line running the installer with its user interface
+	Kind                 string
+	Pins                 []string               // Optional, SHA-256 hashes of the public keys trusted to serve the installer
+	PublicKey            string                 // Optional, minisign or OpenPGP key checking the signatures of the installer
+	Publisher            string                 // Optional, expected signer of the Authenticode signature, implies Authenticode
+	Options              map[string]interface{} // Optional
+	PostInstall          []hook                 // Optional, run after the installer
+	PreInstall           []hook                 // Optional, run before the installer
+	System               bool                   // Optional, set for drivers, runtimes and other system-level changes
+	Win32Automation      bool                   // Optional, must be set to run Win32Steps
+	Win32Steps           []win32ui.Step         // Optional
+	Uninstaller          []string               // Optional, found in the Uninstall registry hive otherwise
+	UserAgent            string                 // Optional, User-Agent header of the requests downloading the installer
+	Arm64                archInstaller          // Optional
+	X86                  archInstaller
+	X86_64               archInstaller
+}
+
+// archFallbacks lists, for each architecture, the installers that can run on it in order of
+// preference: the native one first, then the emulated ones.
+var archFallbacks = map[string][]string{
+	"arm64":  {"arm64", "x86_64", "x86"},
+	"x86_64": {"x86_64", "x86"},
+	"x86":    {"x86"},
+}
+
+// forArch returns the installer for exactly the given architecture. Its URL is empty if the
+// registry doesn't provide one.
+func (s *installerEntry) forArch(a string) *archInstaller {
+	switch a {
+	case "arm64":
+		return &s.Arm64
+	case "x86_64":
+		return &s.X86_64
+	}
+
+	return &s.X86
+}
+
+// resolveArch returns the architecture of the installer to use on the given architecture, which
+// differs from it when falling back to an emulated installer.
+func (s *installerEntry) resolveArch(a string) (string, *archInstaller, error) {
+	fallbacks, ok := archFallbacks[a]
+	if !ok {
+		return "", nil, errors.New("Unknown architecture")
+	}
+
+	for _, candidate := range fallbacks {
+		// Windows 10 on ARM only emulates x86
+		if a == "arm64" && candidate == "x86_64" && !x64Emulation {
+			continue
+		}
+
+		if installer := s.forArch(candidate); installer.URL != "" {
+			return candidate, installer, nil
+		}
+	}
+
+	switch a {
+	case "x86":
+		return "", nil, errors.New("64-bit only package")
+	case "x86_64":
+		return "", nil, errors.New("No fallback 32-bit download")
+	}
+
+	return "", nil, errors.New("No ARM64, x86_64 or x86 download")
+}
+
+// archInstaller is the architecture-specific part of an installer entry. In the registry it is
+// either a plain URL string or a JSON object which can also override the installer kind and the
+// arguments of custom installers.
+type archInstaller struct {
+	URL       string
+	Kind      string   // Optional
+	Arguments []string // Optional
+	Mirrors   []string // Optional, tried in order when URL cannot be downloaded
+	SHA256    string   // Optional, verified after each download
+	Signature string   // Optional, URL of a detached signature made with the public key of the installer
+	Patches   []patch  // Optional, bsdiff patches from previous versions of the installer
+	Zsync     string   // Optional, URL of a zsync control file describing the installer
+}
+
+// UnmarshalJSON accepts both the plain URL and the JSON object forms.
+func (a *archInstaller) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*a = archInstaller{URL: url}
+		return nil
+	}
+
+	type plainArchInstaller archInstaller
+	return json.Unmarshal(data, (*plainArchInstaller)(a))
 }
 
 // options returns the architecture-specific options (if available), otherwise returns the whole
 // options map.
 func (s *installerEntry) options() map[string]interface{} {
-	archSpecificOptions, ok := s.Options[arch].(map[string]interface{})
+	resolved, _, err := s.resolveArch(arch)
+	if err != nil {
+		resolved = arch
+	}
+
+	archSpecificOptions, ok := s.Options[resolved].(map[string]interface{})
 	if !ok {
 		return s.Options
 	}
@@ -184,6 +746,8 @@ func (s *installerEntry) options() map[string]interface{} {
 type Registry struct {
 	Version  int
 	Packages map[string]RegistryEntry
+
+	source *registrySource // Where the registry was parsed from, if anywhere
 }
 
 // SortedPackageNames returns the list of packages present in the registry, sorted alphabetically.
@@ -201,42 +765,406 @@ func (r *Registry) SortedPackageNames() []string {
 
 // RegistryEntry is a single entry in the just-install registry.
 type RegistryEntry struct {
-	Version   string
-	Installer installerEntry
+	AddToPath     []string                // Optional, directories added to the persistent PATH once installed
+	Category      string                  // Optional, like "browsers"
+	Channels      map[string]channelEntry // Optional
+	Config        []string                // Optional, user configuration preserved across upgrades
+	Depends       []string                // Optional, packages (or capabilities) installed before this one
+	Deprecated    bool                    // Optional
+	Description   string                  // Optional, shown and searched by "just-install search"
+	Detect        *detect.Rule            // Optional
+	EULA          string                  // Optional, the license agreement to accept before installing
+	Env           map[string]string       // Optional, persistent environment variables set once installed
+	Group         []string                // Optional, makes this entry a group of other packages
+	License       string                  // Optional, an SPDX identifier like "MIT"
+	Provides      []string                // Optional
+	Scrape        *scrapeRule             // Optional
+	SupersededBy  string                  // Optional, the package replacing a deprecated one
+	Tags          []string                // Optional, keywords searched by "just-install search"
+	Variables     map[string]Variable     // Optional
+	Version       string
+	VersionSource *versionSource            // Optional, where to find the current version instead
+	Versions      map[string]installerEntry // Optional, other versions that can be installed with <package>@<version>
+	Installer     installerEntry
+
+	timings *journal.Timings // Shared by the copies made for the same installation
+}
+
+// Variable is a variable used by the templates of a registry entry, whose value is given by the
+// user.
+type Variable struct {
+	Description string
+	Default     string // Optional, the variable must be given a value otherwise
+}
+
+// DefaultChannel is the channel described by the top-level version and installer of an entry.
+const DefaultChannel = "stable"
+
+// channelEntry is an alternative release channel (e.g. beta) of a registry entry.
+type channelEntry struct {
+	Scrape        *scrapeRule // Optional
+	Version       string
+	VersionSource *versionSource // Optional
+	Installer     installerEntry
+}
+
+// ChannelNames returns the names of the release channels offered by the entry, starting with the
+// default one.
+func (e *RegistryEntry) ChannelNames() []string {
+	var ret []string
+
+	for name := range e.Channels {
+		if name != DefaultChannel {
+			ret = append(ret, name)
+		}
+	}
+
+	sort.Strings(ret)
+
+	return append([]string{DefaultChannel}, ret...)
+}
+
+// WithChannel returns a copy of the entry whose version and installer are the ones of the given
+// release channel. The empty string means the default channel.
+func (e RegistryEntry) WithChannel(channel string) (RegistryEntry, error) {
+	if channel == "" || channel == DefaultChannel {
+		return e, nil
+	}
+
+	channelEntry, ok := e.Channels[channel]
+	if !ok {
+		return e, fmt.Errorf("no %v channel available", channel)
+	}
+
+	e.Scrape = channelEntry.Scrape
+	e.Version = channelEntry.Version
+	e.VersionSource = channelEntry.VersionSource
+	e.Installer = channelEntry.Installer
+
+	return e, nil
+}
+
+// VersionNames returns the versions offered by the entry, the current one and the ones listed in
+// "versions", newest first.
+func (e *RegistryEntry) VersionNames() []string {
+	ret := []string{e.Version}
+
+	for version := range e.Versions {
+		if !versions.Equal(version, e.Version) {
+			ret = append(ret, version)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return versions.Compare(ret[i], ret[j]) > 0 })
+
+	return ret
+}
+
+// WithVersion returns a copy of the entry whose version and installer are the ones of the given
+// version, which is either the current one or one of those listed in "versions".
+func (e RegistryEntry) WithVersion(version string) (RegistryEntry, error) {
+	if versions.Equal(version, e.Version) {
+		return e, nil
+	}
+
+	for name, installer := range e.Versions {
+		if versions.Equal(name, version) {
+			e.Scrape = nil
+			e.Version = name
+			e.VersionSource = nil
+			e.Installer = installer
+
+			return e, nil
+		}
+	}
+
+	return e, fmt.Errorf("version %v is not available, the registry offers: %v", version, strings.Join(e.VersionNames(), ", "))
+}
+
+// IsGroup returns whether this entry is a group of other packages rather than an installable one.
+func (e *RegistryEntry) IsGroup() bool {
+	return len(e.Group) > 0
+}
+
+// DetectVersion returns the installed version of the package according to the entry's detection
+// rules. The second return value is false when the entry has no detection rules or the package
+// doesn't appear to be installed.
+func (e *RegistryEntry) DetectVersion() (string, bool) {
+	if e.Detect == nil {
+		return "", false
+	}
+
+	rule := *e.Detect
+	rule.File = e.ExpandString(rule.File)
+	rule.Registry = e.ExpandString(rule.Registry)
+	rule.Command = nil
+	for _, arg := range e.Detect.Command {
+		rule.Command = append(rule.Command, e.ExpandString(arg))
+	}
+
+	version, ok, err := rule.Detect()
+	if err != nil {
+		log.Println("WARNING: cannot detect the installed version:", err)
+		return "", false
+	}
+
+	return version, ok
 }
 
 // DownloadInstaller downloads the installer for the current entry in the temporary directory.
 func (e *RegistryEntry) DownloadInstaller(force bool) string {
-	options := e.Installer.options()
-
+	start := time.Now()
 	url, err := e.installerURL(arch)
+	e.track().Resolve += time.Since(start)
 	if err != nil {
 		log.Fatalln("Cannot download installation package:", err)
 	}
 
-	log.Println(arch, "-", url)
+	if resolved := e.InstallerArch(); resolved != arch {
+		log.Printf("%v (emulating %v) - %v", arch, resolved, url)
+	} else {
+		log.Println(arch, "-", url)
+	}
+
+	ret := e.installerPath(url)
+
+	startPeerCache()
+
+	checksum := ""
+	if archInstaller, err := e.archInstaller(arch); err == nil {
+		checksum = archInstaller.SHA256
+	}
+
+	// Files with a checksum cannot go stale, others may have been replaced since
+	if dry.FileExists(ret) && !force && checksum == "" {
+		start := time.Now()
+		ret = revalidate(url, e.fetchOptions(ret))
+		e.track().Download += time.Since(start)
+	}
+
+	if dry.FileExists(ret) && !force {
+		err := e.verify(ret, checksum)
+		if err == nil {
+			recordCacheHit(url)
+			useCached(url, ret)
+			return ret
+		}
+
+		log.Println("WARNING:", err)
+		quarantine(ret)
+	}
+
+	if !force && reuseCached(checksum, ret) {
+		err := e.verify(ret, checksum)
+		if err == nil {
+			recordCacheHit(url)
+			useCached(url, ret)
+			return ret
+		}
+
+		log.Println("WARNING:", err)
+		quarantine(ret)
+	}
+
+	sources := append([]string{url}, e.mirrorURLs()...)
+
+	start = time.Now()
+	path := ret
+	if !force && e.downloadDelta(url, ret) {
+		e.track().Download += time.Since(start)
+
+		err := e.verify(ret, checksum)
+		if err == nil {
+			sharePeerFile(checksum, ret)
+			useCached(url, ret)
+			return ret
+		}
+
+		log.Println("WARNING:", err)
+		quarantine(ret)
+		start = time.Now()
+	}
+
+	var result *fetch.FetchResult
+	if !fetchFromPeers(checksum, ret) {
+		path, result = downloadAny(sources, e.fetchOptions(ret))
+	}
+	e.track().Download += time.Since(start)
+
+	// Retry once from the original location, in case the file was corrupted in transit or by a peer
+	if err := e.verify(path, checksum); err != nil {
+		log.Println("WARNING:", err)
+		quarantine(path)
+
+		log.Println("Downloading again from", url)
+		start = time.Now()
+		path, result = downloadAny(sources, e.fetchOptions(ret))
+		e.track().Download += time.Since(start)
+
+		if err := e.verify(path, checksum); err != nil {
+			quarantine(path)
+			fatalDownloadError("Cannot download installation package:", err)
+		}
+	}
+
+	// Registry maintainers can copy the hash of installers that don't have one yet
+	if checksum == "" && result != nil {
+		log.Printf("No sha256 in the registry for this installer, the downloaded file has %v", result.SHA256)
+	}
+
+	sharePeerFile(checksum, path)
+	useCached(url, path)
+
+	return path
+}
+
+// verify is fetch.VerifySHA256 followed by the signature checks of the entry, accounting for the time
+// they take.
+func (e *RegistryEntry) verify(path string, checksum string) error {
+	defer func(start time.Time) { e.track().Verify += time.Since(start) }(time.Now())
+
+	if err := fetch.VerifySHA256(path, checksum); err != nil {
+		return err
+	}
+
+	if err := e.verifySignature(path); err != nil {
+		return err
+	}
+
+	if e.Installer.Authenticode || e.Installer.Publisher != "" {
+		return signature.Verify(path, e.Installer.Publisher)
+	}
+
+	return nil
+}
+
+// checkDiskSpace checks, before running the installer, that the volume programs are installed to
+// can hold the installedSize of the entry, and that the download cache can hold the contents of
+// containers once extracted.
+func (e *RegistryEntry) checkDiskSpace(downloadedFile string, options map[string]interface{}) error {
+	if _, ok := options["container"]; ok {
+		if size, err := installer.ZIPSize(downloadedFile); err == nil {
+			if err := fetch.CheckDiskSpace(tempPath, size); err != nil {
+				return err
+			}
+		}
+	}
+
+	if e.Installer.InstalledSize <= 0 {
+		return nil
+	}
+
+	target := os.Getenv("ProgramFiles")
+	if scope == installer.UserScope {
+		target = os.Getenv("LOCALAPPDATA")
+	}
+	if target == "" {
+		return nil
+	}
+
+	return fetch.CheckDiskSpace(target, int64(e.Installer.InstalledSize)*1024*1024)
+}
+
+// track returns the phase durations of the current installation of the entry.
+func (e *RegistryEntry) track() *journal.Timings {
+	if e.timings == nil {
+		e.timings = &journal.Timings{}
+	}
+
+	return e.timings
+}
+
+// Timings returns how long the phases of the last installation of the entry took.
+func (e RegistryEntry) Timings() journal.Timings {
+	if e.timings == nil {
+		return journal.Timings{}
+	}
+
+	return *e.timings
+}
+
+// installerPath returns where the installer downloaded from the given URL is kept in the
+// download cache.
+func (e *RegistryEntry) installerPath(url string) string {
+	options := e.Installer.options()
 
 	if filename, ok := options["filename"]; ok {
-		return downloadTemp(url, filename.(string), force)
+		return downloadCache.Path(filename.(string))
 	} else if ext, ok := options["extension"]; ok {
-		return downloadExt(url, ext.(string), force)
+		return tempFilePath(url, ext.(string))
+	}
+
+	return tempFilePath(url, "")
+}
+
+// CachedInstaller returns the path of the installer of the entry in the download cache, if it is
+// there.
+func (e *RegistryEntry) CachedInstaller() (string, bool) {
+	url, err := e.installerURL(arch)
+	if err != nil {
+		return "", false
+	}
+
+	ret := e.installerPath(url)
+
+	return ret, dry.FileExists(ret)
+}
+
+// Binaries returns the paths of the installed executables known to the entry (the file of its
+// detection rule and the targets of its shims) that exist on this machine.
+func (e *RegistryEntry) Binaries() []string {
+	var candidates []string
+
+	if e.Detect != nil && e.Detect.File != "" {
+		candidates = append(candidates, e.ExpandString(e.Detect.File))
+	}
+
+	if shims, ok := e.Installer.options()["shims"].([]interface{}); ok {
+		for _, v := range shims {
+			if shim, ok := v.(string); ok {
+				candidates = append(candidates, e.ExpandString(shim))
+			}
+		}
+	}
+
+	var ret []string
+	for _, path := range candidates {
+		if dry.FileExists(path) && !dry.StringInSlice(path, ret) {
+			ret = append(ret, path)
+		}
 	}
 
-	return downloadAutoExt(url, force)
+	return ret
 }
 
 // JustInstall will download and install the given registry entry. Setting `force` to true will
 // force a re-download and re-installation the package.
 func (e *RegistryEntry) JustInstall(force bool) error {
+	if err := e.CheckTemplates(); err != nil {
+		return err
+	}
+
 	options := e.Installer.options()
 	downloadedFile := e.DownloadInstaller(force)
 
-	for _, command := range e.Installer.Preinstall {
-		cmd.Run(strings.Fields(command)...)
+	defer func(start time.Time) { e.track().Install += time.Since(start) }(time.Now())
+
+	if err := e.checkDiskSpace(downloadedFile, options); err != nil {
+		return err
+	}
+
+	if e.Installer.System && (restorePoints || cfg.RestorePoint) {
+		if err := createRestorePoint("just-install: " + filepath.Base(downloadedFile)); err != nil {
+			return fmt.Errorf("cannot create a System Restore point: %v", err)
+		}
+	}
+
+	if err := e.runHooks("pre-install", e.Installer.PreInstall); err != nil {
+		return fmt.Errorf("pre-install command failed: %v", err)
 	}
 
 	if container, ok := options["container"]; ok {
-		tempDir := filepath.Join(os.TempDir(), crc32s(downloadedFile))
+		tempDir := filepath.Join(tempPath, crc32s(downloadedFile))
 		if err := installer.ExtractZIP(downloadedFile, tempDir); err != nil {
 			return err
 		}
@@ -251,8 +1179,12 @@ func (e *RegistryEntry) JustInstall(force bool) error {
 		}
 	}
 
-	for _, command := range e.Installer.Postinstall {
-		cmd.Run(strings.Fields(command)...)
+	if err := e.runHooks("post-install", e.Installer.PostInstall); err != nil {
+		return fmt.Errorf("post-install command failed: %v", err)
+	}
+
+	if err := e.runSteps(e.Installer.Activate); err != nil {
+		return fmt.Errorf("activation failed: %v", err)
 	}
 
 	e.CreateShims()
@@ -261,54 +1193,350 @@ func (e *RegistryEntry) JustInstall(force bool) error {
 }
 
 func (e *RegistryEntry) installerURL(arch string) (string, error) {
-	var url string
+	archInstaller, err := e.archInstaller(arch)
+	if err != nil {
+		return "", err
+	}
 
-	if arch == "x86_64" {
-		if e.Installer.X86_64 != "" {
-			url = e.Installer.X86_64
-		} else if e.Installer.X86 != "" {
-			url = e.Installer.X86
-		} else {
-			return "", errors.New("No fallback 32-bit download")
+	return ResolveURL(e.ExpandString(archInstaller.URL))
+}
+
+// headers returns the headers of the requests downloading the installer, with their placeholders
+// expanded.
+func (e *RegistryEntry) headers() map[string]string {
+	if len(e.Installer.Headers) == 0 {
+		return nil
+	}
+
+	ret := make(map[string]string)
+	for name, value := range e.Installer.Headers {
+		ret[name] = e.ExpandString(value)
+	}
+
+	return ret
+}
+
+// fetchOptions returns the options of the downloads of the installer to the given destination,
+// with its request headers, pins and redirect policy.
+func (e *RegistryEntry) fetchOptions(destinationPath string) *fetch.Options {
+	ret := fetchOptions(destinationPath)
+	ret.AllowInsecureRedirects = e.Installer.InsecureRedirects
+	ret.Headers = e.headers()
+	ret.Pins = e.Installer.Pins
+	ret.UserAgent = e.Installer.UserAgent
+
+	return ret
+}
+
+// mirrorURLs returns the URLs of the mirrors of the installer for the current architecture. Mirrors
+// that cannot be resolved are skipped with a warning.
+func (e *RegistryEntry) mirrorURLs() []string {
+	archInstaller, err := e.archInstaller(arch)
+	if err != nil {
+		return nil
+	}
+
+	var ret []string
+	for _, mirror := range archInstaller.Mirrors {
+		url, err := ResolveURL(e.ExpandString(mirror))
+		if err != nil {
+			log.Printf("WARNING: skipping mirror %v: %v", mirror, err)
+			continue
 		}
-	} else if arch == "x86" {
-		if e.Installer.X86 != "" {
-			url = e.Installer.X86
-		} else {
-			return "", errors.New("64-bit only package")
+
+		ret = append(ret, url)
+	}
+
+	return ret
+}
+
+// ResolveURL turns installer sources that don't point directly to a file, such as github:// ones,
+// into a download URL. Other URLs are returned unchanged.
+func ResolveURL(rawurl string) (string, error) {
+	if _, ok := forgeFor(rawurl); !ok {
+		return rawurl, nil
+	}
+
+	source, err := parseReleaseSource(rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	url, _, err := source.resolve()
+	return url, err
+}
+
+// archInstaller returns the architecture-specific installer to use on the given architecture,
+// falling back to the ones that run emulated (see archFallbacks).
+func (e *RegistryEntry) archInstaller(arch string) (*archInstaller, error) {
+	_, ret, err := e.Installer.resolveArch(arch)
+	return ret, err
+}
+
+// InstallerArch returns the architecture of the installer used for future installations of the
+// entry. It is not the current architecture when falling back to an emulated installer, and the
+// empty string if the entry has no suitable installer.
+func (e *RegistryEntry) InstallerArch() string {
+	ret, _, _ := e.Installer.resolveArch(arch)
+	return ret
+}
+
+// kind returns the installer kind for the current architecture.
+func (e *RegistryEntry) kind() string {
+	if archInstaller, err := e.archInstaller(arch); err == nil && archInstaller.Kind != "" {
+		return archInstaller.Kind
+	}
+
+	return e.Installer.Kind
+}
+
+// arguments returns the command line of custom installers for the current architecture.
+func (e *RegistryEntry) arguments() []string {
+	if archInstaller, err := e.archInstaller(arch); err == nil && len(archInstaller.Arguments) > 0 {
+		return archInstaller.Arguments
+	}
+
+	var ret []string
+
+	if arguments, ok := e.Installer.options()["arguments"].([]interface{}); ok {
+		for _, v := range arguments {
+			ret = append(ret, v.(string))
 		}
-	} else {
-		return "", errors.New("Unknown architecture")
 	}
 
-	return e.ExpandString(url), nil
+	return ret
 }
 
 func (e *RegistryEntry) ExpandString(s string) string {
-	return expandString(s, map[string]string{"version": e.Version})
+	return expandString(s, e.templateContext(nil))
 }
 
+// UnsetVariables returns the names of the variables declared by the entry that were not given a
+// value, sorted alphabetically.
+func (e *RegistryEntry) UnsetVariables() []string {
+	var ret []string
+
+	defined := userVariables()
+
+	for name := range e.Variables {
+		if _, ok := defined[name]; !ok {
+			ret = append(ret, name)
+		}
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+// templateContext returns the variables specific to this entry to expand its templates with,
+// along with the given extra ones.
+func (e *RegistryEntry) templateContext(extra map[string]string) map[string]string {
+	ret := map[string]string{
+		"arch":       e.InstallerArch(),
+		"installDir": programsPath(),
+		"scope":      string(scope),
+		"tempDir":    tempPath,
+		"version":    e.Version,
+	}
+
+	// Defaults of the variables that were not given a value
+	for _, name := range e.UnsetVariables() {
+		ret[name] = e.Variables[name].Default
+	}
+
+	for k, v := range extra {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+// programsPath returns the directory programs are installed to for the installation scope:
+// %LOCALAPPDATA%\Programs for the user scope and %ProgramFiles% otherwise.
+func programsPath() string {
+	if scope == installer.UserScope {
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs")
+	}
+
+	return os.Getenv("ProgramFiles")
+}
+
+// install runs the installer at the given path. If the entry opted into Win32 automation, its steps
+// are performed while the installer is running.
 func (e *RegistryEntry) install(path string) error {
-	if e.Installer.Kind == "custom" {
+	if interactive || !e.Installer.Win32Automation || len(e.Installer.Win32Steps) == 0 {
+		return e.runInstaller(path)
+	}
+
+	log.Println("Driving the installer user interface with", len(e.Installer.Win32Steps), "automation steps")
+
+	stop := make(chan struct{})
+	automationErr := make(chan error, 1)
+	go func() {
+		automationErr <- win32ui.Run(e.Installer.Win32Steps, stop)
+	}()
+
+	installErr := e.runInstaller(path)
+	close(stop)
+
+	// The installer exiting before all steps ran is not an error in itself
+	if err := <-automationErr; err != nil && err != win32ui.ErrStopped && installErr == nil {
+		return fmt.Errorf("Win32 automation failed: %v", err)
+	}
+
+	return installErr
+}
+
+func (e *RegistryEntry) runInstaller(path string) error {
+	if e.isPortable() {
+		return e.installPortable(path)
+	}
+
+	command, scoped, err := e.installerCommand(path)
+	if err != nil {
+		return err
+	}
+
+	if !scoped {
+		log.Printf("WARNING: %v installers cannot be asked to install for the %v scope, using their default", e.kind(), scope)
+	}
+
+	return cmd.RunWithEnv(e.installerEnv(), command...)
+}
+
+// installerCommand returns the command line that runs the installer at the given path, and whether
+// it honors the installation scope.
+func (e *RegistryEntry) installerCommand(path string) ([]string, bool, error) {
+	kind := e.kind()
+
+	if interactive {
+		return e.interactiveCommand(path)
+	}
+
+	if kind == "custom" {
 		var args []string
 
-		for _, v := range e.Installer.options()["arguments"].([]interface{}) {
-			args = append(args, expandString(v.(string), map[string]string{"installer": path}))
+		for _, v := range e.arguments() {
+			args = append(args, expandString(v, e.templateContext(map[string]string{"installer": path})))
 		}
 
-		return cmd.Run(args...)
+		return args, true, nil
 	}
 
-	installerType := installer.InstallerType(e.Installer.Kind)
+	installerType := installer.InstallerType(kind)
 	if !installerType.IsValid() {
-		return fmt.Errorf("unknown installer type: %v", e.Installer.Kind)
+		return nil, false, fmt.Errorf("unknown installer type: %v", kind)
+	}
+
+	if installerType == installer.AppX || installerType == installer.MSIX {
+		return e.appxCommand(path)
+	}
+
+	command, ok := installer.CommandWithScope(path, installerType, scope)
+
+	return command, ok, nil
+}
+
+// interactiveCommand returns the command line that runs the installer at the given path with its
+// user interface, where users pick the installation scope themselves.
+func (e *RegistryEntry) interactiveCommand(path string) ([]string, bool, error) {
+	scoped := scope == installer.DefaultScope
+
+	if len(e.Installer.InteractiveArguments) > 0 {
+		var args []string
+
+		for _, v := range e.Installer.InteractiveArguments {
+			args = append(args, expandString(v, e.templateContext(map[string]string{"installer": path})))
+		}
+
+		return args, scoped, nil
+	}
+
+	kind := e.kind()
+	if kind == "custom" {
+		return []string{path}, scoped, nil
+	}
+
+	installerType := installer.InstallerType(kind)
+	if !installerType.IsValid() {
+		return nil, false, fmt.Errorf("unknown installer type: %v", kind)
+	}
+
+	if installerType == installer.AppX || installerType == installer.MSIX {
+		return e.appxCommand(path)
+	}
+
+	return installer.InteractiveCommand(path, installerType), scoped, nil
+}
+
+// appxCommand returns the command line that installs the MSIX or AppX package at the given path,
+// after downloading the dependency packages and the license file given by the "dependencies" and
+// "license" options.
+func (e *RegistryEntry) appxCommand(path string) ([]string, bool, error) {
+	options := e.Installer.options()
+
+	var dependencies []string
+	if urls, ok := options["dependencies"].([]interface{}); ok {
+		for _, v := range urls {
+			rawurl, ok := v.(string)
+			if !ok {
+				return nil, false, errors.New("the dependencies of MSIX and AppX packages must be URLs")
+			}
+
+			dependency, err := e.downloadAuxiliary(rawurl)
+			if err != nil {
+				return nil, false, fmt.Errorf("cannot download the dependency %v: %v", rawurl, err)
+			}
+
+			dependencies = append(dependencies, dependency)
+		}
 	}
 
-	return cmd.Run(installer.Command(path, installerType)...)
+	license := ""
+	if rawurl, ok := options["license"].(string); ok {
+		var err error
+		if license, err = e.downloadAuxiliary(rawurl); err != nil {
+			return nil, false, fmt.Errorf("cannot download the license file: %v", err)
+		}
+	}
+
+	return installer.AppxCommand(path, dependencies, license, scope), true, nil
 }
 
-func (e *RegistryEntry) destination() string {
-	return expandString(os.ExpandEnv(e.Installer.options()["destination"].(string)), nil)
+// downloadAuxiliary downloads a file the installer needs, like a dependency package, to the
+// download cache with the request headers of the installer, and returns its local path.
+func (e *RegistryEntry) downloadAuxiliary(rawurl string) (string, error) {
+	url, err := ResolveURL(e.ExpandString(rawurl))
+	if err != nil {
+		return "", err
+	}
+
+	path, err := fetch.Fetch(url, e.fetchOptions(tempFilePath(url, "")))
+	if err != nil {
+		return "", err
+	}
+
+	useCached(url, path)
+
+	return path, nil
+}
+
+// installerEnv returns the environment variables to set for the installer process, the ones
+// defined by the entry first followed by the ones given on the command line.
+func (e *RegistryEntry) installerEnv() []string {
+	var keys []string
+	for k := range e.Installer.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ret []string
+	for _, k := range keys {
+		ret = append(ret, k+"="+e.ExpandString(e.Installer.Env[k]))
+	}
+
+	return append(ret, installerEnv...)
 }
 
 func (e *RegistryEntry) CreateShims() {
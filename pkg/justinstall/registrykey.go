@@ -0,0 +1,92 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"log"
+	"os"
+
+	dry "github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/verify"
+)
+
+// registryPublicKey is the minisign public key the official registry is signed with (the RW...
+// line of its .pub file). It is not a secret. make.go refuses to build just-install without it;
+// development builds lacking it use the registry unverified, with a warning.
+const registryPublicKey = ""
+
+// insecureRegistry allows using an official registry whose signature is missing or invalid.
+var insecureRegistry = false
+
+// SetInsecureRegistry makes future operations use the official registry even when its signature
+// is missing or invalid, with a warning, instead of refusing to.
+func SetInsecureRegistry(insecure bool) {
+	insecureRegistry = insecure
+}
+
+// registrySignaturePath returns the path of the detached signature of the cached registry.
+func registrySignaturePath() string {
+	return registryPath + ".minisig"
+}
+
+// downloadRegistrySignature downloads the detached signature of the official registry next to its
+// cached copy. A stale signature is removed when the download fails, so that it cannot vouch for
+// a newer registry.
+func downloadRegistrySignature() error {
+	options := fetchOptions(registrySignaturePath())
+	options.Progress = false
+
+	if _, err := fetch.Fetch(registryURL+".minisig", options); err != nil {
+		os.Remove(registrySignaturePath())
+		return err
+	}
+
+	return nil
+}
+
+// verifyRegistry checks the cached copy of the official registry against its detached signature
+// and exits if it doesn't match, unless insecureRegistry is set. The registry tells just-install
+// what to download and run, so a tampered one is as bad as a tampered installer.
+func verifyRegistry() {
+	if registryPublicKey == "" {
+		log.Println("WARNING: this build of just-install has no registry key, the registry is not verified")
+		return
+	}
+
+	var err error
+	if !dry.FileExists(registrySignaturePath()) {
+		err = downloadRegistrySignature()
+	}
+
+	if err == nil {
+		err = verify.Detached(registryPath, registrySignaturePath(), registryPublicKey)
+	}
+
+	if err == nil {
+		return
+	} else if insecureRegistry {
+		log.Println("WARNING: using the registry despite its missing or invalid signature:", err)
+		return
+	}
+
+	// Download it again next time instead of keeping a bad copy for a day
+	os.Remove(registryPath)
+	os.Remove(registrySignaturePath())
+
+	log.Fatalln("Refusing to use the registry, its signature is missing or invalid (see --insecure-registry):", err)
+}
@@ -0,0 +1,442 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// rateLimitWarning is the number of remaining API requests below which we warn users.
+const rateLimitWarning = 10
+
+var (
+	releaseCachePath = filepath.Join(tempPath, "releases.json")
+	releaseCacheLock sync.Mutex
+)
+
+// release is the subset of a forge's release information we care about.
+type release struct {
+	Tag    string
+	Assets []releaseAsset
+}
+
+type releaseAsset struct {
+	Name   string
+	URL    string
+	APIURL string `json:",omitempty"` // Downloads the asset with a token, for private repositories
+}
+
+// forge describes the release API of a code hosting service.
+type forge struct {
+	name         string
+	scheme       string
+	tokenEnv     string
+	officialHost string                        // The only host the token of tokenEnv is sent to
+	releaseURL   func(s *releaseSource) string // Of the latest release, or of the release of s.tag
+	authorize    func(request *http.Request, token string)
+	decode       func(r io.Reader) (*release, error)
+	defaultHost  string // Host for URLs without one (e.g. github://owner/repo/pattern)
+}
+
+var forges = []*forge{{
+	name:         "GitHub",
+	scheme:       "github://",
+	tokenEnv:     "GITHUB_TOKEN",
+	officialHost: "github.com",
+	defaultHost:  "github.com",
+	releaseURL: func(s *releaseSource) string {
+		if s.tag != "" {
+			return fmt.Sprintf("https://api.github.com/repos/%v/%v/releases/tags/%v", s.owner, s.repo, url.PathEscape(s.tag))
+		}
+
+		return fmt.Sprintf("https://api.github.com/repos/%v/%v/releases/latest", s.owner, s.repo)
+	},
+	authorize: func(request *http.Request, token string) {
+		request.Header.Set("Accept", "application/vnd.github.v3+json")
+		request.Header.Set("Authorization", "token "+token)
+	},
+	decode: decodeGitHubStyleRelease,
+}, {
+	name:         "GitLab",
+	scheme:       "gitlab://",
+	tokenEnv:     "GITLAB_TOKEN",
+	officialHost: "gitlab.com",
+	releaseURL: func(s *releaseSource) string {
+		project := url.PathEscape(s.owner + "/" + s.repo)
+		if s.tag != "" {
+			return fmt.Sprintf("https://%v/api/v4/projects/%v/releases/%v", s.host, project, url.PathEscape(s.tag))
+		}
+
+		return fmt.Sprintf("https://%v/api/v4/projects/%v/releases?per_page=1", s.host, project)
+	},
+	authorize: func(request *http.Request, token string) {
+		request.Header.Set("PRIVATE-TOKEN", token)
+	},
+	decode: decodeGitLabRelease,
+}, {
+	name:         "Gitea",
+	scheme:       "gitea://",
+	tokenEnv:     "GITEA_TOKEN",
+	officialHost: "gitea.com",
+	releaseURL: func(s *releaseSource) string {
+		if s.tag != "" {
+			return fmt.Sprintf("https://%v/api/v1/repos/%v/%v/releases/tags/%v", s.host, s.owner, s.repo, url.PathEscape(s.tag))
+		}
+
+		return fmt.Sprintf("https://%v/api/v1/repos/%v/%v/releases/latest", s.host, s.owner, s.repo)
+	},
+	authorize: func(request *http.Request, token string) {
+		request.Header.Set("Authorization", "token "+token)
+	},
+	decode: decodeGitHubStyleRelease,
+}}
+
+// releaseSource is an installer URL pointing to the asset of the latest release of a project, whose
+// name matches the regular expression pattern. GitHub sources have the form
+// github://owner/repo/pattern, GitLab and Gitea ones have the form gitlab://host/owner/repo/pattern
+// and gitea://host/owner/repo/pattern respectively. Writing repo@tag instead of repo picks the
+// release of the given tag instead of the latest one.
+type releaseSource struct {
+	forge   *forge
+	host    string
+	owner   string
+	repo    string
+	tag     string
+	pattern string
+}
+
+// forgeFor returns the forge handling the given URL, if any.
+func forgeFor(rawurl string) (*forge, bool) {
+	for _, f := range forges {
+		if strings.HasPrefix(rawurl, f.scheme) {
+			return f, true
+		}
+	}
+
+	return nil, false
+}
+
+func parseReleaseSource(rawurl string) (*releaseSource, error) {
+	f, ok := forgeFor(rawurl)
+	if !ok {
+		return nil, fmt.Errorf("unknown release source %v", rawurl)
+	}
+
+	rest := strings.TrimPrefix(rawurl, f.scheme)
+	ret := &releaseSource{forge: f, host: f.defaultHost}
+
+	if ret.host == "" {
+		split := strings.SplitN(rest, "/", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, fmt.Errorf("invalid %v source %v, expected %vhost/owner/repo/pattern", f.name, rawurl, f.scheme)
+		}
+
+		ret.host, rest = split[0], split[1]
+	}
+
+	split := strings.SplitN(rest, "/", 3)
+	if len(split) != 3 || split[0] == "" || split[1] == "" || split[2] == "" {
+		return nil, fmt.Errorf("invalid %v source %v", f.name, rawurl)
+	}
+
+	// GitLab projects in nested groups are written with an escaped slash (group%2Fsubgroup)
+	ret.owner, _ = url.PathUnescape(split[0])
+	ret.repo = split[1]
+	ret.pattern = split[2]
+
+	if i := strings.Index(ret.repo, "@"); i >= 0 {
+		ret.repo, ret.tag = ret.repo[:i], ret.repo[i+1:]
+		if ret.repo == "" || ret.tag == "" {
+			return nil, fmt.Errorf("invalid %v source %v", f.name, rawurl)
+		}
+	}
+
+	return ret, nil
+}
+
+// token returns the token used to authenticate against the forge's API, if any. Tokens for
+// specific hosts in the configuration file take precedence. The hosts of GitLab and Gitea sources
+// come from the registry, so the tokens of the environment are only sent to the official host of
+// the forge: any other host would receive them just by being named in a registry entry.
+func (s *releaseSource) token() string {
+	if token, ok := cfg.Tokens[s.host]; ok {
+		return token
+	}
+
+	if !strings.EqualFold(s.host, s.forge.officialHost) {
+		return ""
+	}
+
+	if s.forge.scheme == "github://" && cfg.GitHubToken != "" {
+		return cfg.GitHubToken
+	}
+
+	return os.Getenv(s.forge.tokenEnv)
+}
+
+// tokenSetting tells users where to give the token of the source.
+func (s *releaseSource) tokenSetting() string {
+	if strings.EqualFold(s.host, s.forge.officialHost) {
+		return s.forge.tokenEnv
+	}
+
+	return fmt.Sprintf("a token for %v in the tokens setting", s.host)
+}
+
+// gitHubToken returns the token used to authenticate against github.com, if any.
+func gitHubToken() string {
+	source := releaseSource{forge: forges[0], host: forges[0].defaultHost}
+	return source.token()
+}
+
+func (s *releaseSource) key() string {
+	ret := s.forge.scheme + s.host + "/" + s.owner + "/" + s.repo
+	if s.tag != "" {
+		ret += "@" + s.tag
+	}
+
+	return ret
+}
+
+// resolve returns the download URL of the matching asset and the version of the release.
+func (s *releaseSource) resolve() (string, string, error) {
+	re, err := regexp.Compile(s.pattern)
+	if err != nil {
+		return "", "", err
+	}
+
+	release, err := s.latestRelease()
+	if err != nil {
+		return "", "", err
+	}
+
+	version := strings.TrimPrefix(release.Tag, "v")
+
+	for _, asset := range release.Assets {
+		if !re.MatchString(asset.Name) {
+			continue
+		}
+
+		// Assets of private repositories are only served through the API, to authenticated users.
+		// The fragment keeps the name of the asset, which the API URL doesn't have.
+		if asset.APIURL != "" && s.token() != "" {
+			return asset.APIURL + "#" + asset.Name, version, nil
+		}
+
+		return asset.URL, version, nil
+	}
+
+	return "", "", fmt.Errorf("no asset of %v/%v %v matches %q", s.owner, s.repo, release.Tag, s.pattern)
+}
+
+// releaseCacheEntry is a cached API response, reused when the forge throttles us and revalidated
+// with its ETag otherwise (conditional requests don't count against GitHub's rate limit).
+type releaseCacheEntry struct {
+	ETag      string
+	FetchedAt time.Time
+	Release   release
+}
+
+func (s *releaseSource) latestRelease() (*release, error) {
+	releaseCacheLock.Lock()
+	defer releaseCacheLock.Unlock()
+
+	key := s.key()
+
+	cache := make(map[string]releaseCacheEntry)
+	if data, err := ioutil.ReadFile(releaseCachePath); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	cached, isCached := cache[key]
+
+	request, err := http.NewRequest("GET", s.forge.releaseURL(s), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := s.token(); token != "" {
+		s.forge.authorize(request, token)
+	}
+	if isCached && cached.ETag != "" {
+		request.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	response, err := fetch.NewClient().Do(request)
+	if err != nil {
+		if isCached {
+			log.Printf("WARNING: cannot reach %v (%v), using the release of %v cached on %v", s.forge.name, err, key, cached.FetchedAt.Format(time.RFC1123))
+			return &cached.Release, nil
+		}
+
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	remaining, reset, hasRateLimit := rateLimit(response)
+
+	switch {
+	case response.StatusCode == http.StatusNotModified && isCached:
+		return &cached.Release, nil
+	case response.StatusCode == http.StatusOK:
+		// Handled below
+	case (response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusTooManyRequests) && hasRateLimit && remaining == 0:
+		if isCached {
+			log.Printf("WARNING: %v API rate limit exceeded until %v, using the release of %v cached on %v", s.forge.name, reset, key, cached.FetchedAt.Format(time.RFC1123))
+			return &cached.Release, nil
+		}
+
+		return nil, fmt.Errorf("%v API rate limit exceeded until %v, set %v to raise the limit", s.forge.name, reset, s.tokenSetting())
+	case response.StatusCode == http.StatusNotFound && s.token() == "":
+		return nil, fmt.Errorf("%v: no such release, set %v if the repository is private", key, s.tokenSetting())
+	default:
+		return nil, fmt.Errorf("%v: %v API returned status code %v", key, s.forge.name, response.StatusCode)
+	}
+
+	if hasRateLimit && remaining < rateLimitWarning {
+		log.Printf("WARNING: only %v %v API requests left until %v", remaining, s.forge.name, reset)
+	}
+
+	release, err := s.forge.decode(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if release.Tag == "" {
+		return nil, fmt.Errorf("%v API returned a release without a tag", s.forge.name)
+	}
+
+	cache[key] = releaseCacheEntry{ETag: response.Header.Get("ETag"), FetchedAt: time.Now(), Release: *release}
+	if data, err := json.Marshal(cache); err == nil {
+		if err := ioutil.WriteFile(releaseCachePath, data, 0600); err != nil {
+			log.Println("WARNING: cannot cache the release:", err)
+		}
+	}
+
+	return release, nil
+}
+
+// rateLimit returns the number of remaining API requests and when the limit resets, looking at
+// both the GitHub (X-RateLimit-*) and GitLab (RateLimit-*) response headers.
+func rateLimit(response *http.Response) (int, string, bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(response.Header.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+
+		reset := "later"
+		if timestamp, err := strconv.ParseInt(response.Header.Get(prefix+"Reset"), 10, 64); err == nil {
+			reset = time.Unix(timestamp, 0).Format(time.RFC1123)
+		}
+
+		return remaining, reset, true
+	}
+
+	return 0, "", false
+}
+
+// decodeGitHubStyleRelease decodes a release from the GitHub API, whose format Gitea mimics.
+func decodeGitHubStyleRelease(r io.Reader) (*release, error) {
+	var decoded struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name               string `json:"name"`
+			URL                string `json:"url"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	ret := &release{Tag: decoded.TagName}
+	for _, asset := range decoded.Assets {
+		ret.Assets = append(ret.Assets, releaseAsset{Name: asset.Name, URL: asset.BrowserDownloadURL, APIURL: gitHubAssetURL(asset.URL)})
+	}
+
+	return ret, nil
+}
+
+// gitHubAssetURL returns the given API URL of a release asset if it is one of GitHub's, which
+// pkg/fetch knows how to download, and the empty string otherwise (e.g. for Gitea).
+func gitHubAssetURL(apiURL string) string {
+	if !strings.HasPrefix(apiURL, "https://api.github.com/repos/") {
+		return ""
+	}
+
+	return apiURL
+}
+
+// decodeGitLabRelease decodes the first release of a list returned by the GitLab API (sorted by
+// release date, newest first), or the single release returned for a tag.
+func decodeGitLabRelease(r io.Reader) (*release, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		data = []byte("[" + trimmed + "]")
+	}
+
+	var decoded []struct {
+		TagName string `json:"tag_name"`
+		Assets  struct {
+			Links []struct {
+				Name           string `json:"name"`
+				URL            string `json:"url"`
+				DirectAssetURL string `json:"direct_asset_url"`
+			} `json:"links"`
+		} `json:"assets"`
+	}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+
+	if len(decoded) == 0 {
+		return nil, errors.New("GitLab API returned no releases")
+	}
+
+	ret := &release{Tag: decoded[0].TagName}
+	for _, link := range decoded[0].Assets.Links {
+		assetURL := link.DirectAssetURL
+		if assetURL == "" {
+			assetURL = link.URL
+		}
+
+		ret.Assets = append(ret.Assets, releaseAsset{Name: link.Name, URL: assetURL})
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,214 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/state"
+	dry "github.com/ungerik/go-dry"
+)
+
+// InstalledVersion returns the installed version of the given package. Detection rules given by
+// the registry entry take precedence over what is recorded in the state database, since they also
+// see software installed by other means.
+func (r *Registry) InstalledVersion(name string, installState *state.State) (string, bool) {
+	entry, ok := r.Packages[name]
+	if ok && entry.Detect != nil {
+		return entry.DetectVersion()
+	}
+
+	installed, ok := installState.Installed(name)
+	return installed.Version, ok
+}
+
+// Providers returns the sorted names of the packages that provide the given capability.
+func (r *Registry) Providers(capability string) []string {
+	var ret []string
+
+	for name, entry := range r.Packages {
+		if dry.StringInSlice(capability, entry.Provides) {
+			ret = append(ret, name)
+		}
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+// Tagged returns the sorted names of the packages with the given tag, ignoring case.
+func (r *Registry) Tagged(tag string) []string {
+	var ret []string
+
+	for name, entry := range r.Packages {
+		if entry.HasTag(tag) {
+			ret = append(ret, name)
+		}
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+// HasTag returns whether the entry has the given tag, ignoring case.
+func (e *RegistryEntry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InCategory returns whether the entry belongs to the given category, ignoring case.
+func (e *RegistryEntry) InCategory(category string) bool {
+	return strings.EqualFold(e.Category, category)
+}
+
+// Resolve maps a name given by the user, either a package or a capability declared through
+// "provides", to the name of a package in the registry. Packages take precedence over capabilities.
+// A capability resolves to the provider that is already installed or, failing that, to its only
+// provider.
+func (r *Registry) Resolve(name string, installState *state.State) (string, error) {
+	if _, ok := r.Packages[name]; ok {
+		return name, nil
+	}
+
+	providers := r.Providers(name)
+	switch len(providers) {
+	case 0:
+		return "", fmt.Errorf("unknown package %v", name)
+	case 1:
+		return providers[0], nil
+	}
+
+	for _, provider := range providers {
+		if _, ok := r.InstalledVersion(provider, installState); ok {
+			return provider, nil
+		}
+	}
+
+	return "", fmt.Errorf("%v is provided by more than one package, please choose one of: %v", name, strings.Join(providers, ", "))
+}
+
+// Expand resolves the given name like Resolve does and, if it refers to a group, recursively
+// expands it into the packages it contains. Names of the form "tag:<tag>" expand to all the
+// packages with that tag. The returned list contains no duplicates.
+func (r *Registry) Expand(name string, installState *state.State) ([]string, error) {
+	var ret []string
+
+	if err := r.expand(name, installState, nil, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (r *Registry) expand(name string, installState *state.State, parents []string, out *[]string) error {
+	if strings.HasPrefix(name, "tag:") {
+		tagged := r.Tagged(strings.TrimPrefix(name, "tag:"))
+		if len(tagged) == 0 {
+			return fmt.Errorf("no package is tagged %v", strings.TrimPrefix(name, "tag:"))
+		}
+
+		for _, pkg := range tagged {
+			if err := r.expand(pkg, installState, parents, out); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	pkg, err := r.Resolve(name, installState)
+	if err != nil {
+		return err
+	}
+
+	if dry.StringInSlice(pkg, parents) {
+		return fmt.Errorf("group %v includes itself", pkg)
+	}
+
+	entry := r.Packages[pkg]
+	if !entry.IsGroup() {
+		if !dry.StringInSlice(pkg, *out) {
+			*out = append(*out, pkg)
+		}
+
+		return nil
+	}
+
+	for _, member := range entry.Group {
+		if err := r.expand(member, installState, append(parents, pkg), out); err != nil {
+			return fmt.Errorf("%v: %v", pkg, err)
+		}
+	}
+
+	return nil
+}
+
+// WithDependencies returns the given packages along with the packages they depend on, through
+// "depends", that are not installed yet. Dependencies come before the packages depending on them,
+// and otherwise the order of the given packages is kept. Dependencies can be capabilities and
+// groups, like the names given by the user, and a package depending on itself through a chain of
+// dependencies is an error.
+func (r *Registry) WithDependencies(packages []string, installState *state.State) ([]string, error) {
+	var ret []string
+
+	for _, pkg := range packages {
+		if err := r.withDependencies(pkg, installState, nil, &ret); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func (r *Registry) withDependencies(pkg string, installState *state.State, parents []string, out *[]string) error {
+	if dry.StringInSlice(pkg, parents) {
+		return fmt.Errorf("dependency cycle: %v -> %v", strings.Join(parents, " -> "), pkg)
+	} else if dry.StringInSlice(pkg, *out) {
+		return nil
+	}
+
+	for _, dependency := range r.Packages[pkg].Depends {
+		members, err := r.Expand(dependency, installState)
+		if err != nil {
+			return fmt.Errorf("%v depends on %v: %v", pkg, dependency, err)
+		}
+
+		for _, member := range members {
+			if _, ok := r.InstalledVersion(member, installState); ok && !dry.StringInSlice(member, parents) {
+				continue
+			}
+
+			if err := r.withDependencies(member, installState, append(parents, pkg), out); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !dry.StringInSlice(pkg, *out) {
+		*out = append(*out, pkg)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"strings"
+
+	"github.com/just-install/just-install/pkg/cmd"
+)
+
+// createRestorePoint creates a System Restore point with the given description. Windows creates at
+// most one restore point per day by default, later requests are silently skipped.
+func createRestorePoint(description string) error {
+	script := "$ErrorActionPreference = 'Stop'; Checkpoint-Computer -Description '" +
+		strings.Replace(description, "'", "''", -1) + "' -RestorePointType APPLICATION_INSTALL"
+
+	return cmd.Run("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script)
+}
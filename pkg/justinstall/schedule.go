@@ -0,0 +1,171 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/cmd"
+)
+
+// scheduleFolder is the Task Scheduler folder holding the tasks created by just-install.
+const scheduleFolder = `\just-install\`
+
+var (
+	scheduleNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	scheduleTimeRegexp = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+)
+
+// ScheduledTask is a Windows Scheduled Task created by just-install.
+type ScheduledTask struct {
+	Name    string
+	NextRun string
+	Status  string
+	Command string // The just-install command line it runs
+}
+
+// Schedule creates (or replaces) a Scheduled Task with the given name that runs just-install with
+// the given arguments "daily" or "weekly" at the given time (HH:MM), as the SYSTEM account. The
+// output of each run is appended to a log file in the just-install data directory.
+func Schedule(name string, executable string, args []string, frequency string, at string) error {
+	if !scheduleNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid task name: %v", name)
+	}
+
+	var schedule []string
+	switch frequency {
+	case "daily":
+		schedule = []string{"/SC", "DAILY"}
+	case "weekly":
+		schedule = []string{"/SC", "WEEKLY", "/D", "SUN"}
+	default:
+		return fmt.Errorf("unknown frequency: %v", frequency)
+	}
+
+	if !scheduleTimeRegexp.MatchString(at) {
+		return fmt.Errorf("invalid time, expected HH:MM: %v", at)
+	}
+
+	// Task commands are limited to 261 characters, so the task runs a script instead
+	logPath := filepath.Join(dataDir, "logs", name+".log")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		return err
+	}
+
+	commandLine := quoteCommandLine(append([]string{executable}, args...))
+	script := "@echo off\r\n" +
+		"echo [%DATE% %TIME%] " + strings.Replace(commandLine, "%", "%%", -1) + " >> \"" + logPath + "\"\r\n" +
+		strings.Replace(commandLine, "%", "%%", -1) + " >> \"" + logPath + "\" 2>&1\r\n"
+
+	scriptPath := scheduleScriptPath(name)
+	if err := os.MkdirAll(filepath.Dir(scriptPath), 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(scriptPath, []byte(script), 0700); err != nil {
+		return err
+	}
+
+	createArgs := []string{"schtasks.exe", "/Create", "/F", "/TN", scheduleFolder + name, "/TR", `"` + scriptPath + `"`,
+		"/ST", at, "/RU", "SYSTEM", "/RL", "HIGHEST"}
+
+	return cmd.Run(append(createArgs, schedule...)...)
+}
+
+// ScheduledTasks returns the Scheduled Tasks created by just-install.
+func ScheduledTasks() ([]ScheduledTask, error) {
+	output, err := exec.Command("schtasks.exe", "/Query", "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(output)))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []ScheduledTask
+
+	for _, record := range records {
+		if len(record) < 3 || !strings.HasPrefix(record[0], scheduleFolder) {
+			continue
+		}
+
+		task := ScheduledTask{
+			Name:    strings.TrimPrefix(record[0], scheduleFolder),
+			NextRun: record[1],
+			Status:  record[2],
+		}
+
+		// The last line of the script is the command line, followed by the redirection to the log
+		if data, err := ioutil.ReadFile(scheduleScriptPath(task.Name)); err == nil {
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			last := strings.TrimSpace(lines[len(lines)-1])
+
+			if i := strings.LastIndex(last, " >> "); i > 0 {
+				task.Command = strings.Replace(last[:i], "%%", "%", -1)
+			}
+		}
+
+		ret = append(ret, task)
+	}
+
+	return ret, nil
+}
+
+// Unschedule deletes the Scheduled Task with the given name, along with its script.
+func Unschedule(name string) error {
+	if !scheduleNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid task name: %v", name)
+	}
+
+	if err := cmd.Run("schtasks.exe", "/Delete", "/F", "/TN", scheduleFolder+name); err != nil {
+		return err
+	}
+
+	os.Remove(scheduleScriptPath(name))
+
+	return nil
+}
+
+func scheduleScriptPath(name string) string {
+	return filepath.Join(dataDir, "schedules", name+".cmd")
+}
+
+// quoteCommandLine joins a command line for cmd.exe, quoting arguments containing spaces.
+func quoteCommandLine(args []string) string {
+	var quoted []string
+
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t&|<>^") {
+			arg = `"` + arg + `"`
+		}
+
+		quoted = append(quoted, arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
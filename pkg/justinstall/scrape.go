@@ -0,0 +1,191 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/just-install/just-install/pkg/journal"
+	versions "github.com/just-install/just-install/pkg/version"
+)
+
+// scrapeCacheTTL is how long a version extracted from a download page is reused before scraping
+// the page again.
+const scrapeCacheTTL = 24 * time.Hour
+
+var scrapeCachePath = filepath.Join(tempPath, "scraped-versions.json")
+
+// scrapeRule describes how to find the newest version of a product on its vendor's download page.
+type scrapeRule struct {
+	URL   string
+	Regex string // The first capturing group must match a version string
+}
+
+type scrapedVersion struct {
+	Version   string
+	ScrapedAt time.Time
+}
+
+// key identifies the rule in the cache.
+func (s *scrapeRule) key() string {
+	return crc32s(s.URL + "\x00" + s.Regex)
+}
+
+// latestVersion returns the newest version found on the page, consulting the cache first.
+func (s *scrapeRule) latestVersion() (string, error) {
+	return cachedVersion(s.key(), s.scrape)
+}
+
+// cachedVersion returns the version cached under the given key, or the one returned by the given
+// function when it is missing or expired.
+func cachedVersion(key string, find func() (string, error)) (string, error) {
+	cache := make(map[string]scrapedVersion)
+	if data, err := ioutil.ReadFile(scrapeCachePath); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+
+	if cached, ok := cache[key]; ok && time.Since(cached.ScrapedAt) < scrapeCacheTTL {
+		return cached.Version, nil
+	}
+
+	version, err := find()
+	if err != nil {
+		return "", err
+	}
+
+	cache[key] = scrapedVersion{Version: version, ScrapedAt: time.Now()}
+	if data, err := json.Marshal(cache); err == nil {
+		if err := ioutil.WriteFile(scrapeCachePath, data, 0600); err != nil {
+			log.Println("WARNING: cannot cache the scraped version:", err)
+		}
+	}
+
+	return version, nil
+}
+
+func (s *scrapeRule) scrape() (string, error) {
+	re, err := regexp.Compile(s.Regex)
+	if err != nil {
+		return "", err
+	}
+
+	if re.NumSubexp() < 1 {
+		return "", fmt.Errorf("regular expression %q has no capturing group", s.Regex)
+	}
+
+	response, err := CustomGet(s.URL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%v: unexpected status code %v", s.URL, response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var newest string
+	for _, match := range re.FindAllSubmatch(body, -1) {
+		if candidate := string(match[1]); newest == "" || versions.Compare(candidate, newest) > 0 {
+			newest = candidate
+		}
+	}
+
+	if newest == "" {
+		return "", fmt.Errorf("no version found at %v", s.URL)
+	}
+
+	return newest, nil
+}
+
+// WithLatestVersion returns a copy of the entry whose version is the one given by its version
+// source, or the newest one found on the vendor's download page when the entry has a scraping
+// rule, or the version of the latest release for "latest" entries pointing to a github://,
+// gitlab:// or gitea:// source.
+func (e RegistryEntry) WithLatestVersion() (RegistryEntry, error) {
+	// Resolving is the first phase of an installation, start timing it from scratch
+	start := time.Now()
+	e.timings = &journal.Timings{}
+	defer func() { e.timings.Resolve += time.Since(start) }()
+
+	return e.withLatestVersion()
+}
+
+func (e RegistryEntry) withLatestVersion() (RegistryEntry, error) {
+	if e.VersionSource != nil {
+		version, err := e.VersionSource.latestVersion(&e)
+		if err != nil {
+			return e, fmt.Errorf("cannot find the latest version: %v", err)
+		}
+
+		e.Version = version
+
+		return e, nil
+	}
+
+	if e.Scrape == nil {
+		return e.withReleaseVersion()
+	}
+
+	version, err := e.Scrape.latestVersion()
+	if err != nil {
+		return e, fmt.Errorf("cannot find the latest version: %v", err)
+	}
+
+	e.Version = version
+
+	return e, nil
+}
+
+func (e RegistryEntry) withReleaseVersion() (RegistryEntry, error) {
+	if e.Version != "latest" {
+		return e, nil
+	}
+
+	archInstaller, err := e.archInstaller(arch)
+	if err != nil {
+		return e, nil
+	}
+
+	if _, ok := forgeFor(archInstaller.URL); !ok {
+		return e, nil
+	}
+
+	source, err := parseReleaseSource(e.ExpandString(archInstaller.URL))
+	if err != nil {
+		return e, err
+	}
+
+	_, version, err := source.resolve()
+	if err != nil {
+		return e, fmt.Errorf("cannot find the latest release: %v", err)
+	}
+
+	e.Version = version
+
+	return e, nil
+}
@@ -0,0 +1,158 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"sort"
+	"strings"
+)
+
+// SearchResult is a package matching a search, with its relevance: results with a higher score
+// match the query better.
+type SearchResult struct {
+	Name  string
+	Score int
+}
+
+// Search returns the packages matching all the words of the given query, best matches first, or all
+// of them for an empty query. Each word is looked up in the name, the category, the tags, the
+// capabilities and the description of the entries: exact names rank first, then name prefixes and
+// substrings, categories, tags and capabilities, words of the description, and finally names
+// within a couple of typos of the word.
+func (r *Registry) Search(query string) []SearchResult {
+	terms := strings.Fields(strings.ToLower(query))
+
+	var ret []SearchResult
+
+	for _, name := range r.SortedPackageNames() {
+		entry := r.Packages[name]
+
+		total := 0
+		matches := true
+		for _, term := range terms {
+			score := entry.matchScore(strings.ToLower(name), term)
+			if score == 0 {
+				matches = false
+				break
+			}
+
+			total += score
+		}
+
+		if matches {
+			ret = append(ret, SearchResult{Name: name, Score: total})
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Score > ret[j].Score })
+
+	return ret
+}
+
+// matchScore returns how well a single search term matches the entry with the given lower case
+// name, 0 meaning that it doesn't match at all.
+func (e *RegistryEntry) matchScore(name string, term string) int {
+	switch {
+	case name == term:
+		return 100
+	case strings.HasPrefix(name, term):
+		return 80
+	case strings.Contains(name, term):
+		return 60
+	}
+
+	for _, keyword := range append(append([]string{e.Category}, e.Tags...), e.Provides...) {
+		keyword = strings.ToLower(keyword)
+
+		if keyword == term {
+			return 50
+		} else if strings.HasPrefix(keyword, term) {
+			return 40
+		}
+	}
+
+	description := strings.ToLower(e.Description)
+	for _, word := range strings.FieldsFunc(description, isWordSeparator) {
+		if strings.HasPrefix(word, term) {
+			return 30
+		}
+	}
+
+	if strings.Contains(description, term) {
+		return 20
+	}
+
+	// Typos, in the whole name or in one of its dash-separated parts
+	for _, candidate := range append([]string{name}, strings.Split(name, "-")...) {
+		if d := editDistance(candidate, term); d <= maxTypos(term) {
+			return 15 - 5*d
+		}
+	}
+
+	return 0
+}
+
+// isWordSeparator tells whether the given character separates the words of a description.
+func isWordSeparator(r rune) bool {
+	return !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r < 0x80
+}
+
+// maxTypos returns how many typos a search term can contain: none for very short terms, which
+// would match about anything, one for short ones and two otherwise.
+func maxTypos(term string) int {
+	switch n := len(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+
+	return 2
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = previous[j-1] + cost
+			if deletion := previous[j] + 1; deletion < current[j] {
+				current[j] = deletion
+			}
+			if insertion := current[j-1] + 1; insertion < current[j] {
+				current[j] = insertion
+			}
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
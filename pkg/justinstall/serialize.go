@@ -0,0 +1,478 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	dry "github.com/ungerik/go-dry"
+)
+
+// registrySource is the JSON document a registry was parsed from, kept so that serializing the
+// registry again only rewrites the entries that changed.
+type registrySource struct {
+	data    []byte
+	start   int // Offset of the contents of the packages object, after its opening brace
+	end     int // Offset of the closing brace of the packages object
+	entries []sourceEntry
+}
+
+// sourceEntry is a member of the packages object of a registry document.
+type sourceEntry struct {
+	name  string
+	start int // Offset of the name
+	value int // Offset of the value
+	end   int // Offset after the value
+}
+
+// ReadRegistryFile reads the registry file at the given path, so that it can be changed and
+// written back with WriteRegistry.
+func ReadRegistryFile(path string) (Registry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	ret, err := parseRegistry(data)
+	if err != nil {
+		return Registry{}, fmt.Errorf("cannot parse %v: %v", path, err)
+	}
+
+	return ret, nil
+}
+
+// WriteRegistry serializes the given registry to a file, replacing it atomically.
+func WriteRegistry(path string, registry Registry) error {
+	data, err := registry.Serialize()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// Add adds a new entry to the registry.
+func (r *Registry) Add(name string, entry RegistryEntry) error {
+	if _, ok := r.Packages[name]; ok {
+		return fmt.Errorf("%v is already in the registry", name)
+	}
+
+	if r.Packages == nil {
+		r.Packages = make(map[string]RegistryEntry)
+	}
+
+	r.Packages[name] = entry
+
+	return nil
+}
+
+// Update replaces an entry of the registry.
+func (r *Registry) Update(name string, entry RegistryEntry) error {
+	if _, ok := r.Packages[name]; !ok {
+		return fmt.Errorf("unknown package %v", name)
+	}
+
+	r.Packages[name] = entry
+
+	return nil
+}
+
+// Remove removes an entry from the registry.
+func (r *Registry) Remove(name string) error {
+	if _, ok := r.Packages[name]; !ok {
+		return fmt.Errorf("unknown package %v", name)
+	}
+
+	delete(r.Packages, name)
+
+	return nil
+}
+
+// Serialize returns the JSON document of the registry. For a registry parsed from a document, the
+// entries that didn't change are kept as they were written, in the same order, and the other ones
+// are indented like the document. New entries are inserted in alphabetical order if the document
+// is sorted, and appended otherwise. Entries are written with their keys sorted and their empty
+// keys left out, like the official registry.
+func (r *Registry) Serialize() ([]byte, error) {
+	if r.source == nil {
+		document := map[string]interface{}{"packages": encodeValue(reflect.ValueOf(r.Packages)), "version": r.Version}
+
+		data, err := marshalJSON(document, "", "  ")
+		return append(data, '\n'), err
+	}
+
+	s := r.source
+	indent, entryIndent := s.indentation()
+
+	separator := ": "
+	if indent == "" {
+		separator = ":"
+	}
+
+	var names []string
+	var texts [][]byte
+	sorted := true
+
+	for i, e := range s.entries {
+		entry, ok := r.Packages[e.name]
+		if !ok {
+			continue
+		}
+
+		if i > 0 && s.entries[i-1].name > e.name {
+			sorted = false
+		}
+
+		var original RegistryEntry
+		if err := json.Unmarshal(s.data[e.value:e.end], &original); err == nil && sameJSON(original, entry) {
+			names = append(names, e.name)
+			texts = append(texts, s.data[e.start:e.end])
+			continue
+		}
+
+		text, err := encodeEntry(e.name, entry, entryIndent, indent, separator)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, e.name)
+		texts = append(texts, text)
+	}
+
+	for _, name := range r.SortedPackageNames() {
+		if dry.StringInSlice(name, names) {
+			continue
+		}
+
+		text, err := encodeEntry(name, r.Packages[name], entryIndent, indent, separator)
+		if err != nil {
+			return nil, err
+		}
+
+		i := len(names)
+		if sorted {
+			i = sort.SearchStrings(names, name)
+		}
+
+		names = append(names[:i], append([]string{name}, names[i:]...)...)
+		texts = append(texts[:i], append([][]byte{text}, texts[i:]...)...)
+	}
+
+	// Keep the whitespace around the entries
+	leading := []byte("\n" + entryIndent)
+	trailing := []byte("\n" + entryIndent[:len(entryIndent)-len(indent)])
+	if indent == "" {
+		leading, trailing = nil, nil
+	}
+	if len(s.entries) > 0 {
+		leading = s.data[s.start:s.entries[0].start]
+		trailing = s.data[s.entries[len(s.entries)-1].end:s.end]
+	}
+
+	var buf bytes.Buffer
+	buf.Write(s.data[:s.start])
+	if len(texts) > 0 {
+		buf.Write(leading)
+		buf.Write(bytes.Join(texts, append([]byte(","), leading...)))
+		buf.Write(trailing)
+	}
+	buf.Write(s.data[s.end:])
+
+	return buf.Bytes(), nil
+}
+
+// indentation returns the indentation unit of the document, the empty string if it is written on
+// a single line, and the indentation of its entries.
+func (s *registrySource) indentation() (string, string) {
+	if len(s.entries) > 0 {
+		between := s.data[s.start:s.entries[0].start]
+		if i := bytes.LastIndexByte(between, '\n'); i >= 0 {
+			entryIndent := string(between[i+1:])
+			return entryIndent[:len(entryIndent)/2], entryIndent
+		}
+	}
+
+	if i := bytes.IndexByte(s.data, '\n'); i >= 0 {
+		line := s.data[i+1:]
+		indent := string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+		return indent, indent + indent
+	}
+
+	return "", ""
+}
+
+// encodeEntry returns the member of the packages object for the given entry, indented with the
+// given prefix for lines after the first one.
+func encodeEntry(name string, entry RegistryEntry, prefix string, indent string, separator string) ([]byte, error) {
+	key, err := marshalJSON(name, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := marshalJSON(encodeValue(reflect.ValueOf(entry)), prefix, indent)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(key, separator...), value...), nil
+}
+
+// marshalJSON encodes the given value like json.MarshalIndent, without escaping HTML characters
+// and without a trailing newline.
+func marshalJSON(v interface{}, prefix string, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(prefix, indent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// encodeValue turns a registry entry, or any value within it, into the generic JSON values written
+// to registry files: struct fields are named in lower camel case, or as told by their JSON tag,
+// and are left out when empty, while installers given by URL only and hooks run directly are written
+// as plain strings.
+func encodeValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return encodeValue(v.Elem())
+	case reflect.Struct:
+		if a, ok := v.Interface().(archInstaller); ok && reflect.DeepEqual(a, archInstaller{URL: a.URL}) {
+			return a.URL
+		}
+
+		if h, ok := v.Interface().(hook); ok && h.Cmd == "" && h.PowerShell == "" {
+			return h.Command
+		}
+
+		ret := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || isEmptyValue(v.Field(i)) {
+				continue
+			}
+
+			name := lowerCamelCase(field.Name)
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+
+			ret[name] = encodeValue(v.Field(i))
+		}
+
+		return ret
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		ret := make(map[string]interface{})
+		for _, key := range v.MapKeys() {
+			ret[fmt.Sprint(key.Interface())] = encodeValue(v.MapIndex(key))
+		}
+
+		return ret
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+
+		ret := make([]interface{}, v.Len())
+		for i := range ret {
+			ret[i] = encodeValue(v.Index(i))
+		}
+
+		return ret
+	}
+
+	return v.Interface()
+}
+
+// isEmptyValue reports whether a struct field is left out of registry files.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// lowerCamelCase returns the key of the given struct field in registry files, like "sha256" for
+// SHA256 or "urlPattern" for URLPattern.
+func lowerCamelCase(name string) string {
+	runes := []rune(name)
+
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+
+	// The last capital starts the next word of names like URLPattern
+	if n > 1 && n < len(runes) && unicode.IsLower(runes[n]) {
+		n--
+	}
+
+	for i := 0; i < n; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	return string(runes)
+}
+
+// newRegistrySource locates the entries of the packages object of a registry document.
+func newRegistrySource(data []byte) (*registrySource, error) {
+	ret := &registrySource{data: data, start: -1}
+
+	err := scanObject(data, skipSpace(data, 0), func(name string, start int, value int, end int) error {
+		if name != "packages" {
+			return nil
+		}
+
+		if value >= len(data) || data[value] != '{' {
+			return errors.New("packages is not an object")
+		}
+
+		ret.start = value + 1
+		ret.end = end - 1
+
+		return scanObject(data, value, func(name string, start int, value int, end int) error {
+			ret.entries = append(ret.entries, sourceEntry{name: name, start: start, value: value, end: end})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	} else if ret.start < 0 {
+		return nil, errors.New("no packages object")
+	}
+
+	return ret, nil
+}
+
+// scanObject calls member with the name and the offsets of the name, the value and the end of the
+// value of each member of the JSON object starting at offset i.
+func scanObject(data []byte, i int, member func(name string, start int, value int, end int) error) error {
+	if i >= len(data) || data[i] != '{' {
+		return errors.New("expected an object")
+	}
+
+	i = skipSpace(data, i+1)
+	if i < len(data) && data[i] == '}' {
+		return nil
+	}
+
+	for {
+		start := i
+		keyEnd, err := scanValue(data, i)
+		if err != nil {
+			return err
+		}
+
+		var name string
+		if err := json.Unmarshal(data[start:keyEnd], &name); err != nil {
+			return err
+		}
+
+		i = skipSpace(data, keyEnd)
+		if i >= len(data) || data[i] != ':' {
+			return errors.New("expected a colon")
+		}
+
+		value := skipSpace(data, i+1)
+		end, err := scanValue(data, value)
+		if err != nil {
+			return err
+		}
+
+		if err := member(name, start, value, end); err != nil {
+			return err
+		}
+
+		i = skipSpace(data, end)
+		if i < len(data) && data[i] == '}' {
+			return nil
+		} else if i >= len(data) || data[i] != ',' {
+			return errors.New("expected a comma")
+		}
+
+		i = skipSpace(data, i+1)
+	}
+}
+
+// scanValue returns the offset after the JSON value starting at offset i.
+func scanValue(data []byte, i int) (int, error) {
+	depth := 0
+
+	for ; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		case depth == 0 && (c == ',' || c == ':' || c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			return i, nil
+		}
+
+		if depth == 0 && (data[i] == '"' || data[i] == '}' || data[i] == ']') {
+			return i + 1, nil
+		} else if depth < 0 {
+			return i, nil
+		}
+	}
+
+	if depth != 0 {
+		return i, errors.New("unexpected end of JSON input")
+	}
+
+	return i, nil
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n') {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,74 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/just-install/just-install/pkg/cmd"
+)
+
+// step is an action performed around the installation or removal of a package: running a command,
+// writing a file or removing a path. Every field can use templates, such as {{secret "name"}}.
+type step struct {
+	Command []string // Command line to run
+	File    string   // Path of the file to write ...
+	Content string   // ... and its contents
+	Remove  string   // Path of a file or directory to remove, if it exists
+}
+
+// runSteps performs the given steps in order, stopping at the first failing one.
+func (e *RegistryEntry) runSteps(steps []step) error {
+	for _, s := range steps {
+		if len(s.Command) == 0 && s.File == "" && s.Remove == "" {
+			return errors.New("steps need a command, a file or a path to remove")
+		}
+
+		if len(s.Command) > 0 {
+			var args []string
+			for _, arg := range s.Command {
+				args = append(args, e.ExpandString(arg))
+			}
+
+			if err := cmd.RunWithEnv(e.installerEnv(), args...); err != nil {
+				return err
+			}
+		}
+
+		if s.File != "" {
+			path := e.ExpandString(s.File)
+
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+
+			if err := ioutil.WriteFile(path, []byte(e.ExpandString(s.Content)), 0600); err != nil {
+				return err
+			}
+		}
+
+		if s.Remove != "" {
+			if err := os.RemoveAll(e.ExpandString(s.Remove)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
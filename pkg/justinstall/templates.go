@@ -0,0 +1,145 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CheckTemplates expands every template of the entry for the current architecture, failing on the
+// first one that is malformed or uses a placeholder that is not defined, so that typos in the
+// registry are reported before anything is downloaded.
+func (e *RegistryEntry) CheckTemplates() error {
+	var templates []string
+
+	if archInstaller, err := e.archInstaller(arch); err == nil {
+		templates = append(templates, archInstaller.URL, archInstaller.Signature, archInstaller.Zsync)
+		templates = append(templates, archInstaller.Mirrors...)
+
+		for _, p := range archInstaller.Patches {
+			templates = append(templates, p.URL)
+		}
+	}
+
+	templates = append(templates, sortedValues(e.Installer.Env)...)
+	templates = append(templates, sortedValues(e.Installer.Headers)...)
+	templates = append(templates, e.Installer.Uninstaller...)
+	templates = append(templates, e.Config...)
+	templates = append(templates, e.AddToPath...)
+	templates = append(templates, sortedValues(e.Env)...)
+	templates = append(templates, optionTemplates(e.Installer.options())...)
+
+	for _, steps := range [][]step{e.Installer.Activate, e.Installer.AfterUninstall, e.Installer.AfterUpgrade, e.Installer.BeforeUninstall, e.Installer.BeforeUpgrade} {
+		for _, s := range steps {
+			templates = append(templates, s.Command...)
+			templates = append(templates, s.File, s.Content, s.Remove)
+		}
+	}
+
+	for _, hooks := range [][]hook{e.Installer.PreInstall, e.Installer.PostInstall} {
+		for _, h := range hooks {
+			templates = append(templates, strings.Fields(h.Command)...)
+			templates = append(templates, h.Cmd, h.PowerShell)
+		}
+	}
+
+	if e.VersionSource != nil {
+		templates = append(templates, e.VersionSource.URL)
+	}
+
+	if e.Detect != nil {
+		templates = append(templates, e.Detect.File, e.Detect.Registry)
+		templates = append(templates, e.Detect.Command...)
+	}
+
+	context := e.templateContext(nil)
+	for _, s := range templates {
+		if _, err := expand(s, context); err != nil {
+			return fmt.Errorf("invalid template %q: %v", s, err)
+		}
+	}
+
+	// Only the command line of custom installers knows where the installer is
+	context = e.templateContext(map[string]string{"installer": ""})
+	for _, s := range append(append([]string(nil), e.arguments()...), e.Installer.InteractiveArguments...) {
+		if _, err := expand(s, context); err != nil {
+			return fmt.Errorf("invalid template %q: %v", s, err)
+		}
+	}
+
+	return nil
+}
+
+// sortedValues returns the values of the given map, sorted by key.
+func sortedValues(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ret []string
+	for _, k := range keys {
+		ret = append(ret, m[k])
+	}
+
+	return ret
+}
+
+// optionTemplates returns the strings found in the given installer options, except for the
+// arguments of custom installers, which are checked separately.
+func optionTemplates(options map[string]interface{}) []string {
+	var ret []string
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			ret = append(ret, v)
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]interface{}:
+			var keys []string
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				walk(k)
+				walk(v[k])
+			}
+		}
+	}
+
+	var keys []string
+	for k := range options {
+		if k != "arguments" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		walk(options[k])
+	}
+
+	return ret
+}
@@ -0,0 +1,92 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/just-install/just-install/pkg/cmd"
+	"github.com/just-install/just-install/pkg/detect"
+	"github.com/just-install/just-install/pkg/installer"
+)
+
+// Uninstall removes the package from this machine, performing the entry's uninstall steps before
+// and after running its uninstaller.
+func (e *RegistryEntry) Uninstall() error {
+	// Portable programs are removed by just-install itself
+	uninstall := e.uninstallPortable
+
+	if len(e.Installer.Uninstaller) > 0 || !e.isPortable() {
+		args, err := e.uninstallCommand()
+		if err != nil {
+			return err
+		}
+
+		uninstall = func() error { return cmd.RunWithEnv(e.installerEnv(), args...) }
+	}
+
+	if err := e.runSteps(e.Installer.BeforeUninstall); err != nil {
+		return fmt.Errorf("before uninstall: %v", err)
+	}
+
+	if err := uninstall(); err != nil {
+		return err
+	}
+
+	if err := e.runSteps(e.Installer.AfterUninstall); err != nil {
+		return fmt.Errorf("after uninstall: %v", err)
+	}
+
+	return nil
+}
+
+// uninstallCommand returns the command line that silently uninstalls the package: either the one
+// given by the entry or the one registered by the installer in the Uninstall registry hive.
+func (e *RegistryEntry) uninstallCommand() ([]string, error) {
+	if len(e.Installer.Uninstaller) > 0 {
+		var ret []string
+		for _, arg := range e.Installer.Uninstaller {
+			ret = append(ret, e.ExpandString(arg))
+		}
+
+		return ret, nil
+	}
+
+	// MSIX and AppX packages don't appear in the Uninstall registry hive
+	if kind := installer.InstallerType(e.kind()); kind == installer.AppX || kind == installer.MSIX {
+		if name, ok := e.Installer.options()["packageName"].(string); ok {
+			return installer.AppxUninstallCommand(e.ExpandString(name), scope), nil
+		}
+	}
+
+	if e.Detect == nil || e.Detect.Uninstall == "" {
+		return nil, errors.New("the registry entry has neither an uninstaller nor an uninstall detection rule")
+	}
+
+	if command, ok, _ := detect.UninstallString(e.Detect.Uninstall, true); ok && command != "" {
+		return installer.SplitCommandLine(command), nil
+	}
+
+	command, ok, err := detect.UninstallString(e.Detect.Uninstall, false)
+	if err != nil {
+		return nil, err
+	} else if !ok || command == "" {
+		return nil, fmt.Errorf("%v doesn't appear in the Uninstall registry hive", e.Detect.Uninstall)
+	}
+
+	return installer.UninstallCommand(command, installer.InstallerType(e.kind())), nil
+}
@@ -0,0 +1,53 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"log"
+)
+
+// JustUpgrade is like JustInstall, for packages that are already installed. The user configuration
+// declared by the entry is backed up before running the installer and restored afterwards, around
+// the entry's upgrade steps.
+func (e *RegistryEntry) JustUpgrade(force bool) error {
+	snapshot, err := e.snapshotConfig()
+	if err != nil {
+		return fmt.Errorf("cannot back up the configuration: %v", err)
+	}
+
+	if err := e.runSteps(e.Installer.BeforeUpgrade); err != nil {
+		return fmt.Errorf("before upgrade: %v", err)
+	}
+
+	if err := e.JustInstall(force); err != nil {
+		if snapshot != "" {
+			log.Println("The previous configuration is kept in", snapshot)
+		}
+
+		return err
+	}
+
+	if err := restoreSnapshot(snapshot); err != nil {
+		return fmt.Errorf("cannot restore the configuration, it is kept in %v: %v", snapshot, err)
+	}
+
+	if err := e.runSteps(e.Installer.AfterUpgrade); err != nil {
+		return fmt.Errorf("after upgrade: %v", err)
+	}
+
+	return nil
+}
@@ -2,40 +2,78 @@ package justinstall
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"hash/crc32"
-	"io"
 	"log"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/just-install/just-install/pkg/fetch"
 	dry "github.com/ungerik/go-dry"
-	pb "gopkg.in/cheggaaa/pb.v1"
 )
 
-// expandString expands any environment variable in the given string, with additional variables
-// coming from the given context.
+// expandString expands any environment variable and any variable given in the configuration file
+// or with SetVariables in the given string, with additional variables coming from the given
+// context. Errors are logged and leave the string as it is.
 func expandString(s string, context map[string]string) string {
+	ret, err := expand(s, context)
+	if err != nil {
+		log.Println("WARNING:", err)
+		return s
+	}
+
+	return ret
+}
+
+// expand is like expandString, but fails on malformed templates and on placeholders that are not
+// defined.
+func expand(s string, context map[string]string) (string, error) {
 	data := environMap()
 
+	for k, v := range userVariables() {
+		data[k] = v
+	}
+
 	// Merge the given context
 	for k, v := range context {
 		data[k] = v
 	}
 
+	tmpl, err := template.New("expand").Funcs(template.FuncMap{"secret": lookupSecret}).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", err
+	}
+
 	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// userVariables returns the variables of the configuration file, overridden by the ones given with
+// SetVariables.
+func userVariables() map[string]string {
+	ret := make(map[string]string)
+
+	for k, v := range cfg.Variables {
+		ret[k] = v
+	}
 
-	template.Must(template.New("expand").Parse(s)).Execute(&buf, data)
+	for k, v := range variables {
+		ret[k] = v
+	}
 
-	return buf.String()
+	return ret
 }
 
 // environMap returns the current environment variables as a map.
@@ -59,30 +97,30 @@ func environMap() map[string]string {
 	return ret
 }
 
-// Convenience wrapper over download3 which passes an empty ("") `ext` parameter.
-func downloadAutoExt(rawurl string, force bool) string {
-	return downloadExt(rawurl, "", force)
-}
-
 // Downloads a file over HTTP(S) to a temporary location. The temporary file has a name derived
 // from the CRC32 of the URL string with the original file extension attached (if any). If `ext`
 // is not the empty string, it will be appended to the destination file. The file is re-downloaded
 // only if the temporary file is missing or `force` is true.
 func downloadExt(rawurl string, ext string, force bool) string {
+	return maybeDownload(rawurl, tempFilePath(rawurl, ext), force)
+}
+
+// tempFilePath returns the path in the download cache that downloadExt uses for the given URL.
+func tempFilePath(rawurl string, ext string) string {
 	u, err := url.Parse(rawurl)
 	if err != nil {
 		log.Fatalf("Unable to parse the URL: %s", rawurl)
 	}
 
-	var base string
-
-	if ext != "" {
-		base = crc32s(rawurl) + ext
-	} else {
-		base = crc32s(rawurl) + filepath.Ext(u.Path)
+	// API URLs, like the ones of GitHub release assets, name the file in their fragment
+	if ext == "" {
+		ext = filepath.Ext(u.Path)
+	}
+	if ext == "" {
+		ext = filepath.Ext(u.Fragment)
 	}
 
-	return downloadTemp(rawurl, base, force)
+	return downloadCache.Path(crc32s(rawurl) + ext)
 }
 
 // Computes and returns the CRC32 of a string as an HEX string.
@@ -93,107 +131,180 @@ func crc32s(s string) string {
 	return fmt.Sprintf("%X", crc32.Sum32())
 }
 
-// downloadTemp downloads a file to the machine's temporary directory.
-func downloadTemp(rawurl string, filename string, force bool) string {
-	ret := filepath.Join(tempPath, filename)
+// maybeDownload is a wrapper for download that doesn't re-download an existing file unless
+// forced or changed on the server.
+func maybeDownload(rawurl string, destinationPath string, force bool) string {
+	var ret string
+	if !dry.FileExists(destinationPath) || force {
+		ret = download(rawurl, destinationPath)
+	} else {
+		ret = revalidate(rawurl, fetchOptions(destinationPath))
+	}
 
-	maybeDownload(rawurl, ret, force)
+	useCached(rawurl, ret)
 
 	return ret
 }
 
-// maybeDownload is a wrapper for download that doesn't re-download an existing file unless
-// forced.
-func maybeDownload(rawurl string, destinationPath string, force bool) {
-	if !dry.FileExists(destinationPath) || force {
-		download(rawurl, destinationPath)
+// revalidate downloads the given URL again if it changed since it was downloaded to the existing
+// destination file, according to the server. The existing file is used if the server cannot be
+// reached, so that cached files keep working offline. The destination is the one of the given
+// options, which are otherwise used as-is.
+func revalidate(rawurl string, options *fetch.Options) string {
+	destinationPath := options.Destination
+	if u, err := url.Parse(rawurl); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return destinationPath
 	}
-}
 
-// download a file with the HTTP/HTTPS protocol showing a progress bar. The destination file is
-// always overwritten.
-func download(rawurl string, destinationPath string) {
-	tempDestinationPath := destinationPath + ".tmp"
+	revalidation := *options
+	revalidation.Revalidate = true
+	revalidation.Retries = 0
 
-	destination, err := os.Create(tempDestinationPath)
+	ret, err := fetch.Fetch(rawurl, &revalidation)
 	if err != nil {
-		log.Fatalf("Unable to open the destination file: %s", tempDestinationPath)
+		log.Printf("WARNING: cannot check whether %v changed, using the cached file: %v", rawurl, err)
+		return destinationPath
 	}
-	defer destination.Close()
 
-	response, err := CustomGet(rawurl)
-	if err != nil {
-		log.Fatalf("Unable to open a connection to %s", rawurl)
+	return ret
+}
+
+// download fetches a file with any of the schemes supported by the fetch package, showing a
+// progress bar unless unattended, and returns its local path. Large downloads go through BITS if so
+// configured. The destination file is always overwritten.
+func download(rawurl string, destinationPath string) string {
+	ret, _ := downloadAny([]string{rawurl}, fetchOptions(destinationPath))
+	return ret
+}
+
+// downloadAny is download for a file available from several mirrors, tried in order, with the
+// given options instead of the configured ones. It also returns the details of the download, and
+// logs where the file came from when it was redirected.
+func downloadAny(rawurls []string, options *fetch.Options) (string, *fetch.FetchResult) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	ret, result, err := fetch.FetchAnyWithResultContext(ctx, rawurls, options)
+	if err != nil && ctx.Err() != nil {
+		log.Fatalln("Download interrupted")
+	} else if err != nil {
+		fatalDownloadError("", err)
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		log.Fatalf("Unexpected HTTP response code. Wanted 200 but got %d", response.StatusCode)
+	if len(result.Redirects) > 0 {
+		log.Printf("Downloaded from %v, redirected from %v", result.URL, strings.Join(result.Redirects, " -> "))
 	}
 
-	var progressBar *pb.ProgressBar
+	return ret, result
+}
 
-	contentLength, err := strconv.Atoi(response.Header.Get("Content-Length"))
-	if err == nil {
-		progressBar = pb.New(int(contentLength))
+// fatalDownloadError logs the error of a failed download, after the given message if any, and
+// advice on what to do about it when its cause is known, then exits.
+func fatalDownloadError(message string, err error) {
+	if message != "" {
+		log.Println(message, err)
 	} else {
-		progressBar = pb.New(0)
+		log.Println(err)
 	}
-	defer progressBar.Finish()
-
-	progressBar.ShowSpeed = true
-	progressBar.SetRefreshRate(time.Millisecond * 1000)
-	progressBar.SetUnits(pb.U_BYTES)
-	progressBar.Start()
 
-	writer := io.MultiWriter(destination, progressBar)
-
-	if _, err := io.Copy(writer, response.Body); err != nil {
-		log.Fatalf("Error downloading file: %s\n", err)
+	cause := fetch.Cause(err)
+	if _, ok := cause.(*fetch.ErrOffline); ok {
+		log.Fatalln("Copy the file to the download cache or to the --offline-mirror directory, or run without --offline.")
+	} else if _, ok := cause.(fetch.DiskSpaceError); ok {
+		log.Fatalln("Free up some space, or move the download cache to a larger volume with \"just-install cache move\".")
 	}
 
-	if err := destination.Close(); err != nil {
-		log.Fatalf("Cannot close destination file: %s\n", err)
+	switch cause {
+	case fetch.ErrNotFound:
+		log.Fatalln("The file is no longer at this address. Run \"just-install update\" to get the latest registry, and report the package if it still fails.")
+	case fetch.ErrForbidden:
+		log.Fatalln("The server refused access. If it requires credentials, set them with the \"headers\" or \"tokens\" settings of the configuration file.")
+	case fetch.ErrChecksumMismatch:
+		log.Fatalln("The download doesn't match the registry and was moved to the quarantine. The vendor may have replaced the file: run \"just-install update\" and report the package if it still fails.")
 	}
 
-	if err := os.Rename(tempDestinationPath, destinationPath); err != nil {
-		log.Fatalf("Cannot rename %s to %s (%s)\n", tempDestinationPath, destinationPath, err)
-	}
+	os.Exit(1)
 }
 
-func CustomGet(urlStr string, timeout ...time.Duration) (*http.Response, error) {
-	// FIXME(lvillani): Adding a variadic timeout argument allows us to keep backward compatibility
-	// with users of this API. This should be taken into account when designing the new fetch API.
+// interruptContext returns a context that is canceled when the user presses Ctrl-C, so that
+// downloads in progress can clean up after themselves, and the function that releases it.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
 
-	request, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
-		return nil, err
-	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-	// Codeplex
-	if strings.Contains(urlStr, "download-codeplex.sec.s-msft.com") {
-		request.Header.Set("User-Agent", "chocolatey command line")
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
 	}
+}
 
-	// AMD Catalyst
-	if strings.Contains(urlStr, "ati.com") {
-		request.Header.Set("Referer", "http://support.amd.com/")
+// envReference matches the ${NAME} references to environment variables of configured headers and
+// credentials.
+var envReference = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// expandHostHeaders returns the configured headers for each host, with the references to
+// environment variables replaced by their values, so that tokens don't have to be stored in the
+// configuration file.
+func expandHostHeaders(headers map[string]map[string]string) map[string]map[string]string {
+	ret := make(map[string]map[string]string)
+
+	for host, h := range headers {
+		ret[host] = make(map[string]string)
+		for name, value := range h {
+			ret[host][name] = expandEnvReferences(value)
+		}
 	}
 
-	// JRE/JDK from java.oracle.com
-	oracleURL, _ := url.Parse("http://download.oracle.com")
-	oracleEdeliveryURL, _ := url.Parse("https://edelivery.oracle.com")
-	oracleCookies := []*http.Cookie{{Name: "oraclelicense", Value: "accept-securebackup-cookie"}}
+	return ret
+}
 
-	jar, _ := cookiejar.New(nil)
-	jar.SetCookies(oracleURL, oracleCookies)
-	jar.SetCookies(oracleEdeliveryURL, oracleCookies)
+// expandEnvReferences replaces the ${NAME} references of the given setting with the value of the
+// environment variables they name.
+func expandEnvReferences(value string) string {
+	return envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		return os.Getenv(envReference.FindStringSubmatch(ref)[1])
+	})
+}
 
-	client := fetch.NewClient()
-	client.Jar = jar
-	if len(timeout) > 0 {
-		client.Timeout = timeout[0]
+// maxBytesPerSecond limits the rate of downloads from the origin, 0 means no limit.
+var maxBytesPerSecond int64
+
+// fetchOptions returns the options of downloads to the given destination, as configured.
+func fetchOptions(destinationPath string) *fetch.Options {
+	return &fetch.Options{
+		BITSMinSize:       int64(cfg.BITSMinSize) << 20,
+		Connections:       cfg.DownloadConnections,
+		Destination:       destinationPath,
+		Downloader:        cfg.Downloader,
+		MaxBytesPerSecond: maxBytesPerSecond,
+		Progress:          !unattended,
+		Retries:           downloadRetries(),
 	}
+}
 
-	return client.Do(request)
+// downloadRetries returns how many times failed downloads are retried, as configured.
+func downloadRetries() int {
+	switch {
+	case cfg.DownloadRetries < 0:
+		return 0
+	case cfg.DownloadRetries == 0:
+		return 3
+	default:
+		return cfg.DownloadRetries
+	}
+}
+
+// CustomGet performs a GET request for the given URL, taking care of the quirks of some vendors.
+func CustomGet(urlStr string, timeout ...time.Duration) (*http.Response, error) {
+	return fetch.Get(urlStr, timeout...)
 }
@@ -0,0 +1,191 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionSource describes where to find the current version of a product, which then replaces the
+// version of the registry entry in its templates: either the latest release of a GitHub
+// repository, a page searched with a regular expression, or a JSON document.
+type versionSource struct {
+	GitHub   string `json:"github"` // Repository, as owner/repo, whose latest release tag is the version
+	URL      string // Page or JSON document containing the version ...
+	Regex    string // ... the first capturing group matching a version string in the page ...
+	JSONPath string // ... or the path of the version in the document, like $.releases[0].version
+}
+
+// latestVersion returns the current version according to the source, for the given entry whose
+// templates are expanded in the URL. Versions read from pages and JSON documents are cached like
+// scraped ones, GitHub releases are cached by the release code.
+func (s *versionSource) latestVersion(e *RegistryEntry) (string, error) {
+	if s.GitHub != "" {
+		split := strings.Split(s.GitHub, "/")
+		if len(split) != 2 || split[0] == "" || split[1] == "" {
+			return "", fmt.Errorf("invalid GitHub repository %q, expected owner/repo", s.GitHub)
+		}
+
+		source := releaseSource{forge: forges[0], host: forges[0].defaultHost, owner: split[0], repo: split[1]}
+
+		release, err := source.latestRelease()
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimPrefix(release.Tag, "v"), nil
+	}
+
+	if s.URL == "" {
+		return "", errors.New("version sources need either a GitHub repository or a URL")
+	}
+
+	url := e.ExpandString(s.URL)
+
+	if s.Regex != "" {
+		rule := scrapeRule{URL: url, Regex: s.Regex}
+		return rule.latestVersion()
+	} else if s.JSONPath != "" {
+		return cachedVersion(crc32s(url+"\x00"+s.JSONPath), func() (string, error) {
+			return jsonVersion(url, s.JSONPath)
+		})
+	}
+
+	return "", errors.New("version sources with a URL need either a regex or a jsonPath")
+}
+
+// jsonVersion downloads the JSON document at the given URL and returns the string or number found
+// at the given path in it, without a leading "v".
+func jsonVersion(url string, path string) (string, error) {
+	response, err := CustomGet(url)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%v: unexpected status code %v", url, response.StatusCode)
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return "", fmt.Errorf("%v: %v", url, err)
+	}
+
+	value, err := evalJSONPath(document, path)
+	if err != nil {
+		return "", err
+	}
+
+	switch value := value.(type) {
+	case string:
+		if value != "" {
+			return strings.TrimPrefix(value, "v"), nil
+		}
+	case json.Number:
+		return value.String(), nil
+	}
+
+	return "", fmt.Errorf("%v: no version at %v", url, path)
+}
+
+// evalJSONPath returns the value at the given path in the given JSON document. Only the subset of
+// JSONPath selecting a single value is supported: $, .key, ['key'] and [index], where negative
+// indexes count from the end of arrays.
+func evalJSONPath(document interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q, it must start with $", path)
+	}
+
+	current := document
+	rest := path[1:]
+
+	for rest != "" {
+		var key string
+		var index int
+		isIndex := false
+
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q", path)
+			}
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, `["`):
+			end := strings.Index(rest[2:], string(rest[1])+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q", path)
+			}
+
+			key, rest = rest[2:2+end], rest[2+end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q", path)
+			}
+
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: %v", path, err)
+			}
+
+			index, isIndex, rest = n, true, rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q", path)
+		}
+
+		if isIndex {
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("JSONPath %q: not an array", path)
+			}
+
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("JSONPath %q: index out of range", path)
+			}
+
+			current = array[index]
+		} else {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("JSONPath %q: not an object", path)
+			}
+
+			if current, ok = object[key]; !ok {
+				return nil, fmt.Errorf("JSONPath %q: no %v key", path, key)
+			}
+		}
+	}
+
+	return current, nil
+}
@@ -0,0 +1,294 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	versions "github.com/just-install/just-install/pkg/version"
+	"github.com/just-install/just-install/pkg/winget"
+	dry "github.com/ungerik/go-dry"
+)
+
+// wingetManifestsURL is where the GitHub API lists the manifests of the winget-pkgs repository.
+const wingetManifestsURL = "https://api.github.com/repos/microsoft/winget-pkgs/contents/manifests/"
+
+// wingetArchs maps the architectures of just-install to the winget ones whose installers run on
+// them. The first one found gives the installer kind and options of the entry.
+var wingetArchs = []struct {
+	arch   string
+	winget []string
+}{
+	{"x86_64", []string{"x64"}},
+	{"x86", []string{"x86", "neutral"}},
+	{"arm64", []string{"arm64"}},
+}
+
+// ImportWinget converts a winget package into a registry entry, returning the name of the entry
+// along with it: the moniker of the package, or its name in lower case with dashes. The source is either a directory holding the manifests of a version of the
+// package, or a package identifier (e.g. Mozilla.Firefox) whose latest version is downloaded from
+// the winget-pkgs repository.
+func ImportWinget(source string) (string, RegistryEntry, error) {
+	var manifest *winget.Manifest
+	var err error
+
+	if dry.FileIsDir(source) {
+		manifest, err = winget.ReadDir(source)
+	} else {
+		manifest, err = fetchWingetManifest(source)
+	}
+	if err != nil {
+		return "", RegistryEntry{}, err
+	}
+
+	name := manifest.Moniker
+	if name == "" {
+		name = manifest.Name
+	}
+	if name == "" {
+		name = manifest.ID
+	}
+	name = strings.Join(strings.FieldsFunc(strings.ToLower(name), isWordSeparator), "-")
+
+	entry, err := wingetEntry(name, manifest)
+	if err != nil {
+		return "", RegistryEntry{}, fmt.Errorf("%v: %v", manifest.ID, err)
+	}
+
+	return name, entry, nil
+}
+
+// wingetEntry converts the manifest of a winget package into a registry entry. For each
+// architecture, machine-wide installers are preferred to per-user ones. Installers needing other
+// options than the first one, which a registry entry cannot express, are left out.
+func wingetEntry(name string, manifest *winget.Manifest) (RegistryEntry, error) {
+	ret := RegistryEntry{
+		Description: manifest.Description,
+		License:     manifest.License,
+		Tags:        manifest.Tags,
+		Version:     manifest.Version,
+	}
+
+	for _, a := range wingetArchs {
+		var chosen *winget.Installer
+		for i, installer := range manifest.Installers {
+			if dry.StringInSlice(installer.Architecture, a.winget) && (chosen == nil || chosen.Scope == "user" && installer.Scope != "user") {
+				chosen = &manifest.Installers[i]
+			}
+		}
+
+		if chosen == nil {
+			continue
+		}
+
+		kind, options, arguments, err := wingetKind(name, chosen)
+		if err != nil {
+			return ret, err
+		}
+
+		if ret.Installer.Kind == "" {
+			ret.Installer.Kind = kind
+			ret.Installer.Options = options
+			ret.Installer.Interactive = kind == "as-is"
+		} else if !sameJSON(options, ret.Installer.Options) {
+			log.Printf("WARNING: skipping the %v installer of %v, which needs different options than the others", a.arch, manifest.ID)
+			continue
+		}
+
+		archInstaller := archInstaller{URL: chosen.URL, Arguments: arguments, SHA256: strings.ToLower(chosen.SHA256)}
+		if kind != ret.Installer.Kind {
+			archInstaller.Kind = kind
+		}
+
+		switch a.arch {
+		case "x86":
+			ret.Installer.X86 = archInstaller
+		case "x86_64":
+			ret.Installer.X86_64 = archInstaller
+		case "arm64":
+			ret.Installer.Arm64 = archInstaller
+		}
+	}
+
+	if ret.Installer.Kind == "" {
+		return ret, errors.New("no installer for x86, x64 or arm64")
+	} else if len(ret.Installer.Options) == 0 {
+		ret.Installer.Options = nil
+	}
+
+	return ret, nil
+}
+
+// wingetKind returns the installer kind, options and custom arguments equivalent to the given
+// winget installer.
+func wingetKind(name string, installer *winget.Installer) (string, map[string]interface{}, []string, error) {
+	options := make(map[string]interface{})
+
+	installerType := installer.Type
+	if installerType == "zip" {
+		if len(installer.NestedFiles) == 0 {
+			return "", nil, nil, fmt.Errorf("the zip installer of %v has no nested installer", installer.URL)
+		}
+
+		installerType = installer.NestedType
+		if installerType != "portable" {
+			options["container"] = map[string]interface{}{"installer": installer.NestedFiles[0]}
+		}
+	}
+
+	switch installerType {
+	case "appx", "msix":
+		return installerType, options, nil, nil
+	case "burn", "exe":
+		if installer.Silent == "" {
+			return "as-is", options, nil, nil
+		}
+
+		return "custom", options, append([]string{"{{.installer}}"}, strings.Fields(installer.Silent)...), nil
+	case "inno":
+		return "innosetup", options, nil, nil
+	case "msi", "wix":
+		return "msi", options, nil, nil
+	case "nullsoft":
+		return "nsis", options, nil, nil
+	case "portable":
+		// winget puts the commands of portable programs on the PATH
+		options["addToPath"] = true
+
+		if installer.Type == "zip" {
+			if i := strings.LastIndexAny(installer.NestedFiles[0], `\/`); i > 0 {
+				options["addToPath"] = installer.NestedFiles[0][:i]
+			}
+
+			options["destination"] = `{{.installDir}}\` + name
+			return "zip", options, nil, nil
+		}
+
+		file := path.Base(installer.URL)
+		if !strings.HasSuffix(strings.ToLower(file), ".exe") {
+			file = name + ".exe"
+		}
+		options["destination"] = `{{.installDir}}\` + name + `\` + file
+
+		return "copy", options, nil, nil
+	}
+
+	return "", nil, nil, fmt.Errorf("unsupported installer type %v", installerType)
+}
+
+// gitHubContent is a file or a directory listed by the contents API of GitHub.
+type gitHubContent struct {
+	Name        string
+	Type        string // "file" or "dir"
+	DownloadURL string `json:"download_url"`
+}
+
+// fetchWingetManifest downloads the manifests of the latest version of the winget package with the
+// given identifier.
+func fetchWingetManifest(id string) (*winget.Manifest, error) {
+	if !strings.Contains(id, ".") {
+		return nil, fmt.Errorf("%v is neither a directory nor a winget package identifier", id)
+	}
+
+	packageURL := wingetManifestsURL + strings.ToLower(id[:1]) + "/" + strings.Replace(id, ".", "/", -1)
+
+	contents, err := gitHubContents(packageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other directories hold packages with a longer identifier, like Mozilla.Firefox.ESR
+	latest := ""
+	for _, content := range contents {
+		if content.Type == "dir" && content.Name[0] >= '0' && content.Name[0] <= '9' && (latest == "" || versions.Compare(content.Name, latest) > 0) {
+			latest = content.Name
+		}
+	}
+
+	if latest == "" {
+		return nil, fmt.Errorf("winget-pkgs has no version of %v", id)
+	}
+
+	contents, err = gitHubContents(packageURL + "/" + latest)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents [][]byte
+	for _, content := range contents {
+		if content.Type != "file" || !strings.HasSuffix(content.Name, ".yaml") {
+			continue
+		}
+
+		data, err := gitHubGet(content.DownloadURL)
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, data)
+	}
+
+	return winget.Parse(documents...)
+}
+
+// gitHubContents lists the directory at the given URL of the contents API of GitHub.
+func gitHubContents(apiURL string) ([]gitHubContent, error) {
+	data, err := gitHubGet(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []gitHubContent
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, fmt.Errorf("cannot parse the listing of %v: %v", apiURL, err)
+	}
+
+	return ret, nil
+}
+
+// gitHubGet downloads the given GitHub URL, authenticated with the GitHub token if there is one.
+func gitHubGet(rawurl string) ([]byte, error) {
+	request, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := gitHubToken(); token != "" {
+		forges[0].authorize(request, token)
+	}
+
+	response, err := fetch.NewClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%v: not found", rawurl)
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v: unexpected status code %v", rawurl, response.StatusCode)
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
@@ -0,0 +1,226 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+const (
+	typePTR = 12
+	typeSRV = 33
+	typeANY = 255
+
+	classIN = 1
+
+	// unicastResponse is the top bit of the question class, asking for a unicast response.
+	unicastResponse = 0x8000
+)
+
+var errMalformed = errors.New("malformed DNS message")
+
+// question is a question of a DNS message.
+type question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// record is a resource record of a DNS message. Only the fields we need are decoded.
+type record struct {
+	Name   string
+	Type   uint16
+	Target string // PTR and SRV records
+	Port   uint16 // SRV records
+}
+
+// message is a DNS message.
+type message struct {
+	ID        uint16
+	Response  bool
+	Questions []question
+	Answers   []record
+}
+
+// appendName appends a domain name in the uncompressed wire format.
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+
+	return append(b, 0)
+}
+
+// appendRecord appends a PTR or an SRV resource record.
+func appendRecord(b []byte, r record, ttl uint32) []byte {
+	b = appendName(b, r.Name)
+	b = appendUint16(b, r.Type)
+	b = appendUint16(b, classIN)
+	b = appendUint32(b, ttl)
+
+	var data []byte
+	if r.Type == typeSRV {
+		data = appendUint16(data, 0) // Priority
+		data = appendUint16(data, 0) // Weight
+		data = appendUint16(data, r.Port)
+	}
+	data = appendName(data, r.Target)
+
+	b = appendUint16(b, uint16(len(data)))
+
+	return append(b, data...)
+}
+
+// pack encodes the message in the DNS wire format.
+func (m *message) pack() []byte {
+	var flags uint16
+	if m.Response {
+		flags = 0x8400 // Response, authoritative answer
+	}
+
+	b := appendUint16(nil, m.ID)
+	b = appendUint16(b, flags)
+	b = appendUint16(b, uint16(len(m.Questions)))
+	b = appendUint16(b, uint16(len(m.Answers)))
+	b = appendUint16(b, 0) // Authority records
+	b = appendUint16(b, 0) // Additional records
+
+	for _, q := range m.Questions {
+		b = appendName(b, q.Name)
+		b = appendUint16(b, q.Type)
+		b = appendUint16(b, q.Class)
+	}
+
+	for _, r := range m.Answers {
+		b = appendRecord(b, r, 120)
+	}
+
+	return b
+}
+
+// readName decodes a possibly compressed domain name at the given offset, returning it along with
+// the offset of the following data.
+func readName(b []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+
+	for jumps := 0; ; {
+		if offset >= len(b) {
+			return "", 0, errMalformed
+		}
+
+		length := int(b[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(b) || jumps > 16 {
+				return "", 0, errMalformed
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(b[offset:]) & 0x3FFF)
+			jumps++
+		default:
+			if offset+1+length > len(b) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(b[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// unpack decodes a DNS message. Authority and additional records are ignored.
+func unpack(b []byte) (*message, error) {
+	if len(b) < 12 {
+		return nil, errMalformed
+	}
+
+	m := &message{
+		ID:       binary.BigEndian.Uint16(b),
+		Response: b[2]&0x80 != 0,
+	}
+	questions := int(binary.BigEndian.Uint16(b[4:]))
+	answers := int(binary.BigEndian.Uint16(b[6:]))
+	offset := 12
+
+	for i := 0; i < questions; i++ {
+		name, next, err := readName(b, offset)
+		if err != nil || next+4 > len(b) {
+			return nil, errMalformed
+		}
+
+		m.Questions = append(m.Questions, question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(b[next:]),
+			Class: binary.BigEndian.Uint16(b[next+2:]),
+		})
+		offset = next + 4
+	}
+
+	for i := 0; i < answers; i++ {
+		name, next, err := readName(b, offset)
+		if err != nil || next+10 > len(b) {
+			return nil, errMalformed
+		}
+
+		r := record{Name: name, Type: binary.BigEndian.Uint16(b[next:])}
+		length := int(binary.BigEndian.Uint16(b[next+8:]))
+		data := next + 10
+		if data+length > len(b) {
+			return nil, errMalformed
+		}
+
+		switch r.Type {
+		case typePTR:
+			r.Target, _, err = readName(b, data)
+		case typeSRV:
+			if length < 7 {
+				return nil, errMalformed
+			}
+			r.Port = binary.BigEndian.Uint16(b[data+4:])
+			r.Target, _, err = readName(b, data+6)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m.Answers = append(m.Answers, r)
+		offset = data + length
+	}
+
+	return m, nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
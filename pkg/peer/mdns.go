@@ -0,0 +1,149 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceName is the DNS-SD service type under which just-install instances advertise themselves.
+const serviceName = "_just-install._tcp.local."
+
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Peer is another just-install instance sharing its download cache.
+type Peer struct {
+	Instance string // Name the instance advertises itself with, usually the host name
+	Addr     string // Address ("host:port") of the instance's cache server
+}
+
+// Advertise answers discovery queries from other instances, telling them that the cache of the
+// named instance is served on the given TCP port, until the stop channel is closed.
+func Advertise(instance string, port int, stop <-chan struct{}) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsAddr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-stop
+		conn.Close()
+	}()
+
+	instanceName := instance + "." + serviceName
+	buf := make([]byte, 9000)
+
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		query, err := unpack(buf[:n])
+		if err != nil || query.Response || !asksForService(query) {
+			continue
+		}
+
+		response := message{
+			ID:        query.ID,
+			Response:  true,
+			Questions: []question{{Name: serviceName, Type: typePTR, Class: classIN}},
+			Answers: []record{
+				{Name: serviceName, Type: typePTR, Target: instanceName},
+				{Name: instanceName, Type: typeSRV, Target: instance + ".local.", Port: uint16(port)},
+			},
+		}
+
+		// Always answer directly to the querier, which then uses the source address of the
+		// response to reach us.
+		conn.WriteToUDP(response.pack(), from)
+	}
+}
+
+// asksForService returns whether the query asks for just-install instances.
+func asksForService(query *message) bool {
+	for _, q := range query.Questions {
+		if strings.EqualFold(q.Name, serviceName) && (q.Type == typePTR || q.Type == typeANY) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Discover looks for other instances on the local network, waiting for answers up to the given
+// timeout.
+func Discover(timeout time.Duration) ([]Peer, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query := message{
+		ID:        uint16(time.Now().UnixNano()),
+		Questions: []question{{Name: serviceName, Type: typePTR, Class: classIN | unicastResponse}},
+	}
+
+	if _, err := conn.WriteToUDP(query.pack(), mdnsAddr); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	var ret []Peer
+	seen := make(map[string]bool)
+	buf := make([]byte, 9000)
+
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// Most likely the deadline, which is how discovery normally ends
+			break
+		}
+
+		response, err := unpack(buf[:n])
+		if err != nil || !response.Response || response.ID != query.ID {
+			continue
+		}
+
+		for _, answer := range response.Answers {
+			if answer.Type != typeSRV || !strings.HasSuffix(strings.ToLower(answer.Name), serviceName) {
+				continue
+			}
+
+			peer := Peer{
+				Instance: strings.TrimSuffix(answer.Name[:len(answer.Name)-len(serviceName)], "."),
+				Addr:     net.JoinHostPort(from.IP.String(), strconv.Itoa(int(answer.Port))),
+			}
+
+			if !seen[peer.Addr] {
+				seen[peer.Addr] = true
+				ret = append(ret, peer)
+			}
+		}
+	}
+
+	return ret, nil
+}
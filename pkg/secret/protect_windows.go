@@ -0,0 +1,110 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package secret
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	cryptProtectUIForbidden  = 0x1
+	cryptProtectLocalMachine = 0x4
+)
+
+// administratorsOnly is a protected DACL granting full access to SYSTEM and the Administrators
+// group only, inherited by files and subdirectories.
+const administratorsOnly = "D:P(A;OICI;FA;;;SY)(A;OICI;FA;;;BA)"
+
+var (
+	crypt32  = syscall.NewLazyDLL("crypt32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+// dataBlob mirrors the DATA_BLOB structure.
+type dataBlob struct {
+	Size uint32
+	Data *byte
+}
+
+func newBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+
+	return &dataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+// bytes copies the contents of a blob allocated by Windows and frees it.
+func (b *dataBlob) bytes() []byte {
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(b.Data)))
+
+	if b.Size == 0 {
+		return nil
+	}
+
+	ret := make([]byte, b.Size)
+	copy(ret, (*[1 << 30]byte)(unsafe.Pointer(b.Data))[:b.Size:b.Size])
+
+	return ret
+}
+
+// protect encrypts data with DPAPI, using the machine key so that secrets stored once can be used
+// by any account running just-install on this machine.
+func protect(data []byte) ([]byte, error) {
+	var out dataBlob
+
+	ret, _, err := procCryptProtectData.Call(uintptr(unsafe.Pointer(newBlob(data))), 0, 0, 0, 0, cryptProtectUIForbidden|cryptProtectLocalMachine, uintptr(unsafe.Pointer(&out)))
+	if ret == 0 {
+		return nil, err
+	}
+
+	return out.bytes(), nil
+}
+
+// unprotect decrypts data encrypted by protect.
+func unprotect(data []byte) ([]byte, error) {
+	var out dataBlob
+
+	ret, _, err := procCryptUnprotectData.Call(uintptr(unsafe.Pointer(newBlob(data))), 0, 0, 0, 0, cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if ret == 0 {
+		return nil, err
+	}
+
+	return out.bytes(), nil
+}
+
+// restrict replaces the permissions of the given file or directory with administratorsOnly, since
+// anyone able to read the store can decrypt it with the machine key.
+func restrict(path string) error {
+	sd, err := windows.SecurityDescriptorFromString(administratorsOnly)
+	if err != nil {
+		return err
+	}
+
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+}
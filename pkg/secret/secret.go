@@ -0,0 +1,134 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Store is the on-disk store of secrets. Values are encrypted so that they can only be decrypted
+// on this machine, and the store and its directory can only be read by administrators.
+type Store struct {
+	Secrets map[string][]byte
+
+	path string
+}
+
+// Load reads the secret store from the given path. A missing file is not an error, an empty store
+// is returned instead.
+func Load(path string) (*Store, error) {
+	ret := &Store{Secrets: make(map[string][]byte), path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+
+	if ret.Secrets == nil {
+		ret.Secrets = make(map[string][]byte)
+	}
+
+	return ret, nil
+}
+
+// Save writes the secret store back to the path it was loaded from. Its directory, which should
+// not hold anything else, is restricted to administrators first.
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if err := restrict(dir); err != nil {
+		return fmt.Errorf("cannot restrict access to %v: %v", dir, err)
+	}
+
+	tempPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+
+	if err := restrict(tempPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("cannot restrict access to %v: %v", s.path, err)
+	}
+
+	return os.Rename(tempPath, s.path)
+}
+
+// Get returns the decrypted value of the named secret.
+func (s *Store) Get(name string) (string, error) {
+	encrypted, ok := s.Secrets[name]
+	if !ok {
+		return "", fmt.Errorf("no such secret: %v", name)
+	}
+
+	value, err := unprotect(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt secret %v: %v", name, err)
+	}
+
+	return string(value), nil
+}
+
+// Set encrypts and stores the value of the named secret.
+func (s *Store) Set(name string, value string) error {
+	encrypted, err := protect([]byte(value))
+	if err != nil {
+		return fmt.Errorf("cannot encrypt secret %v: %v", name, err)
+	}
+
+	s.Secrets[name] = encrypted
+
+	return nil
+}
+
+// Delete removes the named secret, reporting whether it existed.
+func (s *Store) Delete(name string) bool {
+	_, ok := s.Secrets[name]
+	delete(s.Secrets, name)
+
+	return ok
+}
+
+// Names returns the names of the stored secrets, sorted alphabetically.
+func (s *Store) Names() []string {
+	var ret []string
+
+	for name := range s.Secrets {
+		ret = append(ret, name)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
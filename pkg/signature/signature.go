@@ -0,0 +1,141 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package signature
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Info describes a file and the certificate it is signed with, if any.
+type Info struct {
+	Path       string
+	SHA256     string
+	Status     string // Authenticode signature status (e.g. Valid, NotSigned)
+	Subject    string // Subject of the signer certificate
+	Issuer     string // Issuer of the signer certificate
+	Thumbprint string // Thumbprint of the signer certificate
+}
+
+// Inspect returns information about the given files.
+func Inspect(paths []string) ([]Info, error) {
+	var ret []Info
+
+	for _, path := range paths {
+		hash, err := fileSHA256(path)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, Info{Path: path, SHA256: hash})
+	}
+
+	if err := readSigners(ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Verify checks that the given file has a valid Authenticode signature (with WinVerifyTrust) and,
+// unless publisher is empty, that the common name of its signer certificate is the given one.
+// Revocation is not checked, so that cached files can be verified offline.
+func Verify(path string, publisher string) error {
+	if err := verifyTrust(path); err != nil {
+		return fmt.Errorf("invalid Authenticode signature on %v: %v", path, err)
+	}
+
+	if publisher == "" {
+		return nil
+	}
+
+	infos := []Info{{Path: path}}
+	if err := readSigners(infos); err != nil {
+		return err
+	}
+
+	if name := commonName(infos[0].Subject); !strings.EqualFold(name, publisher) {
+		return fmt.Errorf("%v is signed by %q, not by %q", path, name, publisher)
+	}
+
+	return nil
+}
+
+// commonName returns the CN attribute of a distinguished name like `CN="Example, Inc.", O=...`, as
+// formatted by Windows.
+func commonName(dn string) string {
+	for len(dn) > 0 {
+		dn = strings.TrimLeft(dn, " ")
+
+		i := strings.Index(dn, "=")
+		if i < 0 {
+			return ""
+		}
+
+		key := strings.TrimSpace(dn[:i])
+		dn = dn[i+1:]
+
+		var value string
+		if strings.HasPrefix(dn, `"`) {
+			// Quotes are doubled inside quoted values
+			end := 1
+			for end < len(dn) && (dn[end] != '"' || strings.HasPrefix(dn[end:], `""`)) {
+				if dn[end] == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(dn) {
+				return ""
+			}
+
+			value, dn = strings.Replace(dn[1:end], `""`, `"`, -1), dn[end+1:]
+			if i := strings.Index(dn, ","); i >= 0 {
+				dn = dn[i+1:]
+			} else {
+				dn = ""
+			}
+		} else if i := strings.Index(dn, ","); i >= 0 {
+			value, dn = dn[:i], dn[i+1:]
+		} else {
+			value, dn = dn, ""
+		}
+
+		if strings.EqualFold(key, "CN") {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,100 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// verifyTrust checks the Authenticode signature of the given file with WinVerifyTrust, without
+// any user interface.
+func verifyTrust(path string) error {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	data := &windows.WinTrustData{
+		Size:             uint32(unsafe.Sizeof(windows.WinTrustData{})),
+		UIChoice:         windows.WTD_UI_NONE,
+		RevocationChecks: windows.WTD_REVOKE_NONE,
+		UnionChoice:      windows.WTD_CHOICE_FILE,
+		StateAction:      windows.WTD_STATEACTION_VERIFY,
+		FileOrCatalogOrBlobOrSgnrOrCert: unsafe.Pointer(&windows.WinTrustFileInfo{
+			Size:     uint32(unsafe.Sizeof(windows.WinTrustFileInfo{})),
+			FilePath: path16,
+		}),
+	}
+
+	err = windows.WinVerifyTrustEx(windows.InvalidHWND, &windows.WINTRUST_ACTION_GENERIC_VERIFY_V2, data)
+
+	data.StateAction = windows.WTD_STATEACTION_CLOSE
+	windows.WinVerifyTrustEx(windows.InvalidHWND, &windows.WINTRUST_ACTION_GENERIC_VERIFY_V2, data)
+
+	return err
+}
+
+// readSigners fills in the signature details of the given files, asking PowerShell for all of them
+// at once.
+func readSigners(infos []Info) error {
+	if len(infos) == 0 {
+		return nil
+	}
+
+	var quoted []string
+	for _, info := range infos {
+		quoted = append(quoted, "'"+strings.Replace(info.Path, "'", "''", -1)+"'")
+	}
+
+	script := "$ErrorActionPreference = 'Stop'; @(Get-AuthenticodeSignature -LiteralPath " + strings.Join(quoted, ",") + " | " +
+		"ForEach-Object { @{ Status = $_.Status.ToString(); Subject = $_.SignerCertificate.Subject; " +
+		"Issuer = $_.SignerCertificate.Issuer; Thumbprint = $_.SignerCertificate.Thumbprint } }) | ConvertTo-Json -Compress"
+
+	output, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		return fmt.Errorf("cannot read the file signatures: %v", err)
+	}
+
+	// A single object is not wrapped in an array by ConvertTo-Json
+	var signers []Info
+	trimmed := strings.TrimSpace(string(output))
+	if strings.HasPrefix(trimmed, "{") {
+		trimmed = "[" + trimmed + "]"
+	}
+
+	if err := json.Unmarshal([]byte(trimmed), &signers); err != nil {
+		return fmt.Errorf("cannot parse the file signatures: %v", err)
+	}
+
+	if len(signers) != len(infos) {
+		return fmt.Errorf("expected %d signatures, got %d", len(infos), len(signers))
+	}
+
+	for i, signer := range signers {
+		infos[i].Status = signer.Status
+		infos[i].Subject = signer.Subject
+		infos[i].Issuer = signer.Issuer
+		infos[i].Thumbprint = signer.Thumbprint
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Package records a single package installed by just-install.
+type Package struct {
+	Arch        string
+	Channel     string `json:",omitempty"`
+	InstalledAt time.Time
+	Version     string
+}
+
+// License records the acceptance of the license agreement of a package.
+type License struct {
+	AcceptedAt time.Time
+	EULA       string
+}
+
+// Environment records the changes a package made to the persistent environment, so that they can
+// be undone when it is uninstalled.
+type Environment struct {
+	Machine   bool                // Whether they were made for the machine or for the current user
+	Path      []string            `json:",omitempty"` // Directories added to PATH
+	Variables map[string]Variable `json:",omitempty"`
+}
+
+// Variable records a persistent environment variable set by a package.
+type Variable struct {
+	Previous *string `json:",omitempty"` // Before the package set it, nil if it was not set
+	Value    string
+}
+
+// State is the on-disk database of packages installed by just-install.
+type State struct {
+	Environment map[string]Environment `json:",omitempty"`
+	Licenses    map[string]License     `json:",omitempty"`
+	Packages    map[string]Package
+
+	path string
+}
+
+// Load reads the state database from the given path. A missing file is not an error, an empty
+// state is returned instead.
+func Load(path string) (*State, error) {
+	ret := &State{Environment: make(map[string]Environment), Licenses: make(map[string]License), Packages: make(map[string]Package), path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+
+	if ret.Environment == nil {
+		ret.Environment = make(map[string]Environment)
+	}
+
+	if ret.Licenses == nil {
+		ret.Licenses = make(map[string]License)
+	}
+
+	if ret.Packages == nil {
+		ret.Packages = make(map[string]Package)
+	}
+
+	return ret, nil
+}
+
+// Save writes the state database back to the path it was loaded from.
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	tempPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, s.path)
+}
+
+// Installed returns the recorded information about the given package, if any.
+func (s *State) Installed(name string) (Package, bool) {
+	pkg, ok := s.Packages[name]
+	return pkg, ok
+}
+
+// RecordInstall records that the given version of a package, from the given channel, has just been
+// installed.
+func (s *State) RecordInstall(name string, version string, arch string, channel string) {
+	s.Packages[name] = Package{Arch: arch, Channel: channel, InstalledAt: time.Now(), Version: version}
+}
+
+// RecordUninstall records that a package has just been uninstalled.
+func (s *State) RecordUninstall(name string) {
+	delete(s.Packages, name)
+}
+
+// AcceptedLicense returns whether the given license agreement of a package has been accepted.
+func (s *State) AcceptedLicense(name string, eula string) bool {
+	license, ok := s.Licenses[name]
+	return ok && license.EULA == eula
+}
+
+// RecordLicenseAcceptance records that the given license agreement of a package has just been
+// accepted. Acceptances outlive uninstallations.
+func (s *State) RecordLicenseAcceptance(name string, eula string) {
+	s.Licenses[name] = License{AcceptedAt: time.Now(), EULA: eula}
+}
+
+// EnvironmentChanges returns the recorded changes of a package to the persistent environment.
+func (s *State) EnvironmentChanges(name string) (Environment, bool) {
+	env, ok := s.Environment[name]
+	return env, ok
+}
+
+// RecordEnvironmentChanges records the changes of a package to the persistent environment,
+// replacing the previous record.
+func (s *State) RecordEnvironmentChanges(name string, env Environment) {
+	if len(env.Path) == 0 && len(env.Variables) == 0 {
+		delete(s.Environment, name)
+		return
+	}
+
+	s.Environment[name] = env
+}
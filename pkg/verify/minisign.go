@@ -0,0 +1,141 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package verify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ed25519"
+)
+
+const trustedCommentPrefix = "trusted comment: "
+
+// minisignKey is a minisign public key: the "Ed" algorithm, the key ID and the Ed25519 key.
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// parseMinisignKey parses the base64 line of a minisign public key, skipping comments.
+func parseMinisignKey(s string) (minisignKey, error) {
+	var data []byte
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return minisignKey{}, fmt.Errorf("malformed minisign public key: %v", err)
+		}
+
+		data = decoded
+		break
+	}
+
+	if len(data) != 2+8+ed25519.PublicKeySize || string(data[:2]) != "Ed" {
+		return minisignKey{}, errors.New("malformed minisign public key")
+	}
+
+	return minisignKey{id: data[2:10], key: ed25519.PublicKey(data[10:])}, nil
+}
+
+// checkMinisign verifies a minisign signature file, made either over the file itself ("Ed") or over
+// its BLAKE2b-512 hash ("ED"), along with the global signature covering its trusted comment.
+func checkMinisign(path string, signature []byte, publicKey string) error {
+	key, err := parseMinisignKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.Replace(string(signature), "\r\n", "\n", -1), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], trustedCommentPrefix) {
+		return errors.New("malformed minisign signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+
+	if !bytes.Equal(sig[2:10], key.id) {
+		return fmt.Errorf("signed by key %X, not by the trusted key %X", reverse(sig[2:10]), reverse(key.id))
+	}
+
+	var message []byte
+	switch string(sig[:2]) {
+	case "Ed":
+		message, err = ioutil.ReadFile(path)
+	case "ED":
+		message, err = blake2bFile(path)
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %q", sig[:2])
+	}
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key.key, message, sig[10:]) {
+		return fmt.Errorf("invalid signature for %v", path)
+	}
+
+	trustedComment := strings.TrimPrefix(lines[2], trustedCommentPrefix)
+	if !ed25519.Verify(key.key, append(sig[10:], trustedComment...), globalSig) {
+		return errors.New("invalid trusted comment in the minisign signature")
+	}
+
+	return nil
+}
+
+// blake2bFile returns the BLAKE2b-512 hash of the given file.
+func blake2bFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash, _ := blake2b.New512(nil)
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// reverse returns the given key ID in the order minisign prints it (little-endian).
+func reverse(id []byte) []byte {
+	ret := make([]byte, len(id))
+	for i, b := range id {
+		ret[len(id)-1-i] = b
+	}
+
+	return ret
+}
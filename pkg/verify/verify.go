@@ -0,0 +1,37 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package verify
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// Detached checks that the signature in the file at signaturePath was made over the contents of the
+// file at path by the given public key. The key is either a minisign public key, with or without
+// its "untrusted comment" line, or an ASCII-armored OpenPGP key block.
+func Detached(path string, signaturePath string, publicKey string) error {
+	signature, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(publicKey, "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+		return checkOpenPGP(path, signature, publicKey)
+	}
+
+	return checkMinisign(path, signature, publicKey)
+}
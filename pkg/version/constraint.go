@@ -0,0 +1,127 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a set of conditions a version must satisfy, such as ">=20 <21".
+type Constraint struct {
+	raw     string
+	clauses []clause
+}
+
+type clause struct {
+	operator string
+	version  string
+}
+
+// operators are sorted so that longer operators are matched first.
+var operators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// ParseConstraint parses a constraint made of space-separated clauses, all of which must be
+// satisfied. Each clause is a comparison operator (>=, >, <=, <, =, !=) followed by a version; a
+// version without an operator must match exactly. The empty string and "*" match any version.
+// Other forms, like comma-separated clauses, caret and tilde ranges or wildcards such as "1.x",
+// are refused rather than matching the wrong versions.
+func ParseConstraint(s string) (*Constraint, error) {
+	ret := &Constraint{raw: strings.TrimSpace(s)}
+
+	for _, field := range strings.Fields(s) {
+		if field == "*" {
+			continue
+		}
+
+		c := clause{operator: "=", version: field}
+		for _, op := range operators {
+			if strings.HasPrefix(field, op) {
+				c = clause{operator: op, version: strings.TrimPrefix(field, op)}
+				break
+			}
+		}
+
+		if c.version == "" {
+			return nil, fmt.Errorf("invalid version constraint %q: missing version after %v", s, c.operator)
+		}
+
+		if strings.ContainsAny(c.version, ",^~*<>=") || hasWildcard(c.version) {
+			return nil, fmt.Errorf("invalid version constraint %q: unsupported clause %v, use space-separated comparisons like \">=1.2 <2\"", s, field)
+		}
+
+		if c.operator == "==" {
+			c.operator = "="
+		}
+
+		ret.clauses = append(ret.clauses, c)
+	}
+
+	return ret, nil
+}
+
+// hasWildcard returns whether a version has an "x" component, as in "1.x" or "1.2.X".
+func hasWildcard(v string) bool {
+	for _, component := range strings.Split(v, ".") {
+		if component == "x" || component == "X" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check returns whether the given version satisfies the constraint.
+func (c *Constraint) Check(v string) bool {
+	for _, clause := range c.clauses {
+		result := Compare(v, clause.version)
+
+		var ok bool
+		switch clause.operator {
+		case ">=":
+			ok = result >= 0
+		case ">":
+			ok = result > 0
+		case "<=":
+			ok = result <= 0
+		case "<":
+			ok = result < 0
+		case "!=":
+			ok = result != 0
+		default:
+			ok = result == 0
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsAny returns whether the constraint matches any version.
+func (c *Constraint) IsAny() bool {
+	return len(c.clauses) == 0
+}
+
+func (c *Constraint) String() string {
+	if c.IsAny() {
+		return "*"
+	}
+
+	return c.raw
+}
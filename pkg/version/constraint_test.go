@@ -0,0 +1,97 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package version
+
+import "testing"
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "1.0", true},
+		{"*", "1.0", true},
+		{"1.2", "1.2", true},
+		{"1.2", "1.2.0", true},
+		{"1.2", "1.2.1", false},
+		{"=1.2", "1.2", true},
+		{"==1.2", "1.2", true},
+		{"!=1.2", "1.2", false},
+		{"!=1.2", "1.3", true},
+		{">=20", "20.0.1", true},
+		{">=20", "19.9", false},
+		{">20", "20", false},
+		{">20", "20.0.1", true},
+		{"<21", "20.99", true},
+		{"<21", "21", false},
+		{"<=21", "21", true},
+		{">=20 <21", "20.5", true},
+		{">=20 <21", "21.0", false},
+		{">=20 <21", "19", false},
+		{">=1.0 <2.0", "2.0", false},
+		{">=1.0.0-rc.1", "1.0.0", true},
+		{"<1.0.0", "1.0.0-rc.1", true},
+		{" >=1.0   <2 ", "1.5", true},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q): %v", test.constraint, err)
+			continue
+		}
+
+		if got := c.Check(test.version); got != test.want {
+			t.Errorf("ParseConstraint(%q).Check(%q) = %v, want %v", test.constraint, test.version, got, test.want)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, constraint := range []string{">=", "<", ">=1.0,<2.0", "^1.2", "~1.2", "~>1.2", "1.x", "1.2.X", "1.*", ">=1.0 <=", "=>1.0", "<>1.0"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded, want an error", constraint)
+		}
+	}
+}
+
+func TestConstraintString(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+		any        bool
+	}{
+		{"", "*", true},
+		{"*", "*", true},
+		{" >=20 <21 ", ">=20 <21", false},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): %v", test.constraint, err)
+		}
+
+		if got := c.String(); got != test.want {
+			t.Errorf("ParseConstraint(%q).String() = %q, want %q", test.constraint, got, test.want)
+		}
+
+		if got := c.IsAny(); got != test.any {
+			t.Errorf("ParseConstraint(%q).IsAny() = %v, want %v", test.constraint, got, test.any)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package version
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Compare compares two version strings, returning -1, 0 or +1 depending on whether a is older,
+// equal or newer than b. It understands the version formats commonly found in the wild:
+//
+//   - semantic versions, where pre-releases sort before releases ("1.0.0-rc.1" < "1.0.0") and
+//     build metadata is ignored ("1.0.0+build5" == "1.0.0");
+//   - four-part Windows versions ("10.0.19041.1");
+//   - date-based versions ("2020.01.15", "2020-01-15");
+//   - a leading "v" ("v1.2" == "1.2");
+//   - pre-releases glued to the version ("3.9.0b1" < "3.9.0rc1" < "3.9.0");
+//   - bare letter suffixes marking later revisions, as in "1.1.1b" > "1.1.1a" > "1.1.1".
+//
+// Missing numeric components count as zero, so "1.2" == "1.2.0.0".
+func Compare(a string, b string) int {
+	aTokens := tokenize(a)
+	bTokens := tokenize(b)
+
+	for i := 0; i < len(aTokens) || i < len(bTokens); i++ {
+		aToken, bToken := zeroToken, zeroToken
+		if i < len(aTokens) {
+			aToken = aTokens[i]
+		}
+		if i < len(bTokens) {
+			bToken = bTokens[i]
+		}
+
+		if result := aToken.compare(bToken); result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+// Equal returns whether two version strings denote the same version.
+func Equal(a string, b string) bool {
+	return Compare(a, b) == 0
+}
+
+// tokenKind orders the kinds of tokens among each other: a pre-release marker sorts before
+// anything, including a missing component.
+type tokenKind int
+
+const (
+	preRelease tokenKind = iota
+	number
+	suffix
+)
+
+type token struct {
+	kind  tokenKind
+	value string // Digits without leading zeros for numbers, lower case text otherwise
+	rank  int    // Only for pre-release markers
+}
+
+// zeroToken stands in for missing components.
+var zeroToken = token{kind: number, value: ""}
+
+var preReleaseRanks = map[string]int{
+	"dev":      0,
+	"snapshot": 0,
+	"alpha":    1,
+	"a":        1,
+	"beta":     2,
+	"b":        2,
+	"pre":      3,
+	"preview":  3,
+	"c":        4,
+	"rc":       4,
+}
+
+// tokenize splits a version into numeric and alphabetic components, discarding separators.
+func tokenize(v string) []token {
+	v = strings.ToLower(strings.TrimSpace(v))
+	v = strings.TrimPrefix(v, "v")
+
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i] // Build metadata doesn't affect precedence
+	}
+
+	var ret []token
+	runes := []rune(v)
+
+	for i := 0; i < len(runes); {
+		start := i
+
+		switch {
+		case unicode.IsDigit(runes[i]):
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+
+			ret = append(ret, token{kind: number, value: strings.TrimLeft(string(runes[start:i]), "0")})
+		case unicode.IsLetter(runes[i]):
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				i++
+			}
+
+			text := string(runes[start:i])
+
+			// A single letter glued to a number ("1.1.1b") is a revision, not a pre-release, unless
+			// a number follows it ("3.9.0b1").
+			attached := start > 0 && unicode.IsDigit(runes[start-1]) && len(text) == 1 &&
+				(i == len(runes) || !unicode.IsDigit(runes[i]))
+			if rank, ok := preReleaseRanks[text]; ok && !attached {
+				ret = append(ret, token{kind: preRelease, value: text, rank: rank})
+			} else {
+				ret = append(ret, token{kind: suffix, value: text})
+			}
+		default:
+			i++ // Separator
+		}
+	}
+
+	return ret
+}
+
+func (t token) compare(other token) int {
+	// A revision suffix is newer than a missing (or zero) component, older than any other number
+	if t.kind == suffix && other.kind == number {
+		if other.value == "" {
+			return 1
+		}
+		return -1
+	} else if t.kind == number && other.kind == suffix {
+		return -other.compare(t)
+	}
+
+	if t.kind != other.kind {
+		if t.kind < other.kind {
+			return -1
+		}
+		return 1
+	}
+
+	switch t.kind {
+	case number:
+		return compareDigits(t.value, other.value)
+	case preRelease:
+		if t.rank != other.rank {
+			if t.rank < other.rank {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return strings.Compare(t.value, other.value)
+}
+
+// compareDigits compares two strings of digits without leading zeros, of arbitrary length.
+func compareDigits(a string, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
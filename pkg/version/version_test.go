@@ -0,0 +1,84 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.2", "1.2.0.0", 0},
+		{"1.2.0.1", "1.2", 1},
+		{"v1.2", "1.2", 0},
+		{"V1.2", "1.2", 0},
+		{"01.002", "1.2", 0},
+		{"123456789012345678901234567890", "123456789012345678901234567891", -1},
+		{"10.0.19041.1", "10.0.19041.2", -1},
+		{"10.0.19042.1", "10.0.19041.999", 1},
+		{"2020.01.15", "2020.1.15", 0},
+		{"2020-01-15", "2020.01.16", -1},
+		{"2021.01.01", "2020.12.31", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-beta", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.2", -1},
+		{"1.0.0-dev", "1.0.0-alpha", -1},
+		{"1.0.0-preview", "1.0.0-rc", -1},
+		{"1.0.0-rc.1", "0.9", 1},
+		{"1.0.0+build5", "1.0.0", 0},
+		{"1.0.0+build5", "1.0.0+build6", 0},
+		{"1.1.1b", "1.1.1a", 1},
+		{"1.1.1a", "1.1.1", 1},
+		{"1.1.1a", "1.1.2", -1},
+		{"1.1.1b", "1.1.1.1", -1},
+		{"3.9.0b1", "3.9.0", -1},
+		{"3.9.0a1", "3.9.0b1", -1},
+		{"3.9.0b1", "3.9.0b2", -1},
+		{"3.9.0b2", "3.9.0rc1", -1},
+		{"3.9.0rc1", "3.9.0", -1},
+		{"3.9.0", "3.9.1a1", -1},
+		{"1.0a1", "1.0", -1},
+		{"1.0a1", "0.9", 1},
+		{" 1.2 ", "1.2", 0},
+		{"", "0", 0},
+	}
+
+	for _, test := range tests {
+		if got := Compare(test.a, test.b); got != test.want {
+			t.Errorf("Compare(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+
+		if got := Compare(test.b, test.a); got != -test.want {
+			t.Errorf("Compare(%q, %q) = %v, want %v", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !Equal("v1.2.0", "1.2") {
+		t.Error(`Equal("v1.2.0", "1.2") = false, want true`)
+	}
+
+	if Equal("1.2", "1.2.1") {
+		t.Error(`Equal("1.2", "1.2.1") = true, want false`)
+	}
+}
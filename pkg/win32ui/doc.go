@@ -0,0 +1,23 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package win32ui drives the user interface of installers that lack a silent mode, by waiting for
+// windows with a given title and sending them keystrokes or button clicks.
+//
+// It only knows about classic Win32 controls: windows are found with EnumWindows and
+// EnumChildWindows, buttons are clicked with BM_CLICK and keys are typed with keybd_event. It does
+// not use UI Automation, so installers drawing their own controls (WPF, DirectUI, Qt, Electron and
+// the like) cannot be driven by clicking buttons, only by typing keys into their window.
+package win32ui
@@ -0,0 +1,178 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package win32ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a step waits for its window to appear when it doesn't specify a
+// timeout of its own.
+const DefaultTimeout = 60 * time.Second
+
+// pollInterval is how often we look for windows and buttons while waiting for them to appear.
+const pollInterval = 500 * time.Millisecond
+
+// ErrStopped is returned by Run when it is stopped before all steps have been performed.
+var ErrStopped = errors.New("Win32 automation stopped before completion")
+
+var errUnsupported = errors.New("Win32 automation is only supported on Windows")
+
+// Step is a single Win32 automation step. It waits for a top-level window whose title contains
+// Window, then clicks the button labeled Click and/or types Keys into it.
+type Step struct {
+	Window  string
+	Click   string // Optional
+	Keys    string // Optional, special keys are written as {ENTER}, {TAB}, etc.
+	Timeout int    // Optional, in seconds
+}
+
+func (s Step) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultTimeout
+	}
+
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// Run performs the given steps in order. Closing the stop channel aborts automation early, for
+// example because the installer process has already exited.
+func Run(steps []Step, stop <-chan struct{}) error {
+	if !supported {
+		return errUnsupported
+	}
+
+	for _, step := range steps {
+		if step.Window == "" {
+			return errors.New("Win32 automation step without a window title")
+		}
+
+		keys, err := parseKeys(step.Keys)
+		if err != nil {
+			return err
+		}
+
+		window, err := waitFor(step.timeout(), stop, func() (uintptr, bool) {
+			return findWindow(step.Window)
+		})
+		if err != nil {
+			return fmt.Errorf("waiting for window %q: %v", step.Window, err)
+		}
+
+		if step.Click != "" {
+			button, err := waitFor(step.timeout(), stop, func() (uintptr, bool) {
+				return findButton(window, step.Click)
+			})
+			if err != nil {
+				return fmt.Errorf("waiting for button %q in window %q: %v", step.Click, step.Window, err)
+			}
+
+			if err := click(button); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) > 0 {
+			if err := sendKeys(window, keys); err != nil {
+				return err
+			}
+		}
+
+		// Give the installer some time to react before moving to the next step
+		time.Sleep(pollInterval)
+	}
+
+	return nil
+}
+
+// waitFor polls find until it succeeds, the timeout expires or stop is closed.
+func waitFor(timeout time.Duration, stop <-chan struct{}, find func() (uintptr, bool)) (uintptr, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if handle, ok := find(); ok {
+			return handle, nil
+		}
+
+		if time.Now().After(deadline) {
+			return 0, errors.New("timed out")
+		}
+
+		select {
+		case <-stop:
+			return 0, ErrStopped
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// key is either a virtual key code or a character to be typed.
+type key struct {
+	virtualKey byte
+	char       rune
+}
+
+var specialKeys = map[string]byte{
+	"BACKSPACE": 0x08,
+	"TAB":       0x09,
+	"ENTER":     0x0D,
+	"ESC":       0x1B,
+	"SPACE":     0x20,
+	"LEFT":      0x25,
+	"UP":        0x26,
+	"RIGHT":     0x27,
+	"DOWN":      0x28,
+}
+
+// parseKeys parses a string of keys to send, in which special keys are written between braces
+// (e.g. "{TAB}{TAB}{ENTER}").
+func parseKeys(s string) ([]key, error) {
+	var ret []key
+
+	for len(s) > 0 {
+		if s[0] == '{' {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated special key in %q", s)
+			}
+
+			name := strings.ToUpper(s[1:end])
+			virtualKey, ok := specialKeys[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown special key {%v}", name)
+			}
+
+			ret = append(ret, key{virtualKey: virtualKey})
+			s = s[end+1:]
+			continue
+		}
+
+		r := []rune(s)[0]
+		ret = append(ret, key{char: r})
+		s = s[len(string(r)):]
+	}
+
+	return ret, nil
+}
+
+// normalizeCaption strips accelerator markers from a control caption, so that "&Next >" matches
+// "Next >".
+func normalizeCaption(s string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Replace(s, "&", "", -1)))
+}
@@ -0,0 +1,154 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package win32ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const supported = true
+
+const (
+	bmClick         = 0x00F5
+	keyEventKeyUp   = 0x0002
+	virtualKeyShift = 0x10
+)
+
+var (
+	user32 = syscall.NewLazyDLL("user32.dll")
+
+	procEnumChildWindows    = user32.NewProc("EnumChildWindows")
+	procEnumWindows         = user32.NewProc("EnumWindows")
+	procGetWindowTextW      = user32.NewProc("GetWindowTextW")
+	procIsWindowEnabled     = user32.NewProc("IsWindowEnabled")
+	procIsWindowVisible     = user32.NewProc("IsWindowVisible")
+	procKeybdEvent          = user32.NewProc("keybd_event")
+	procSendMessageW        = user32.NewProc("SendMessageW")
+	procSetForegroundWindow = user32.NewProc("SetForegroundWindow")
+	procVkKeyScanW          = user32.NewProc("VkKeyScanW")
+)
+
+// Windows callbacks are a limited resource, so we create a single one and let it match against
+// package-level state protected by a mutex.
+var (
+	enumMutex    sync.Mutex
+	enumMatch    func(hwnd uintptr) bool
+	enumResult   uintptr
+	enumCallback = syscall.NewCallback(func(hwnd uintptr, lparam uintptr) uintptr {
+		if enumMatch(hwnd) {
+			enumResult = hwnd
+			return 0 // Stop enumerating
+		}
+
+		return 1
+	})
+)
+
+func enumerate(proc *syscall.LazyProc, parent uintptr, match func(hwnd uintptr) bool) (uintptr, bool) {
+	enumMutex.Lock()
+	defer enumMutex.Unlock()
+
+	enumMatch = match
+	enumResult = 0
+
+	if parent == 0 {
+		proc.Call(enumCallback, 0)
+	} else {
+		proc.Call(parent, enumCallback, 0)
+	}
+
+	return enumResult, enumResult != 0
+}
+
+func windowText(hwnd uintptr) string {
+	var buf [512]uint16
+
+	n, _, _ := procGetWindowTextW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+
+	return syscall.UTF16ToString(buf[:n])
+}
+
+func isVisible(hwnd uintptr) bool {
+	ret, _, _ := procIsWindowVisible.Call(hwnd)
+	return ret != 0
+}
+
+func findWindow(title string) (uintptr, bool) {
+	title = strings.ToLower(title)
+
+	return enumerate(procEnumWindows, 0, func(hwnd uintptr) bool {
+		return isVisible(hwnd) && strings.Contains(strings.ToLower(windowText(hwnd)), title)
+	})
+}
+
+func findButton(window uintptr, caption string) (uintptr, bool) {
+	caption = normalizeCaption(caption)
+
+	return enumerate(procEnumChildWindows, window, func(hwnd uintptr) bool {
+		if !isVisible(hwnd) {
+			return false
+		}
+
+		if enabled, _, _ := procIsWindowEnabled.Call(hwnd); enabled == 0 {
+			return false
+		}
+
+		return normalizeCaption(windowText(hwnd)) == caption
+	})
+}
+
+func click(button uintptr) error {
+	procSendMessageW.Call(button, bmClick, 0, 0)
+	return nil
+}
+
+func sendKeys(window uintptr, keys []key) error {
+	procSetForegroundWindow.Call(window)
+	time.Sleep(pollInterval)
+
+	for _, k := range keys {
+		virtualKey := k.virtualKey
+		shift := false
+
+		if k.char != 0 {
+			ret, _, _ := procVkKeyScanW.Call(uintptr(k.char))
+			if int16(ret) == -1 {
+				return fmt.Errorf("cannot type character %q", k.char)
+			}
+
+			virtualKey = byte(ret & 0xFF)
+			shift = ret&0x100 != 0
+		}
+
+		if shift {
+			procKeybdEvent.Call(virtualKeyShift, 0, 0, 0)
+		}
+
+		procKeybdEvent.Call(uintptr(virtualKey), 0, 0, 0)
+		procKeybdEvent.Call(uintptr(virtualKey), 0, keyEventKeyUp, 0)
+
+		if shift {
+			procKeybdEvent.Call(virtualKeyShift, 0, keyEventKeyUp, 0)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package winget
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest is a winget package, merged from its version, default locale and installer manifests.
+type Manifest struct {
+	ID          string // Like Mozilla.Firefox
+	Version     string
+	Name        string
+	Moniker     string // Optional, a short name like firefox
+	Publisher   string
+	Description string // The short description
+	License     string
+	Homepage    string // Optional
+	Tags        []string
+	Installers  []Installer
+}
+
+// Installer is one of the installers of a winget package, defaults given at the top level of the
+// installer manifest included.
+type Installer struct {
+	Architecture string   // x86, x64, arm, arm64 or neutral
+	Type         string   // Like msi, nullsoft or zip
+	NestedType   string   // Optional, type of the installer within zip installers
+	NestedFiles  []string // Optional, paths of the installers within zip installers
+	Scope        string   // Optional, user or machine
+	URL          string
+	SHA256       string
+	Silent       string // Optional, the switches of a silent installation
+}
+
+// ReadDir reads the manifests of a package version from the given directory, like
+// manifests/m/Mozilla/Firefox/120.0 in the winget-pkgs repository.
+func ReadDir(dir string) (*Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var documents [][]byte
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, data)
+	}
+
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("no manifest in %v", dir)
+	}
+
+	return Parse(documents...)
+}
+
+// Parse merges the given manifests of a package version, either a singleton manifest or the
+// version, locale and installer ones. Locale manifests other than the default one are ignored.
+func Parse(documents ...[]byte) (*Manifest, error) {
+	ret := &Manifest{}
+
+	for _, data := range documents {
+		value, err := parseYAML(data)
+		if err != nil {
+			return nil, err
+		}
+
+		document, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("a manifest must be a mapping")
+		}
+
+		if id := str(document["PackageIdentifier"]); id != "" {
+			ret.ID = id
+		}
+		if version := str(document["PackageVersion"]); version != "" {
+			ret.Version = version
+		}
+
+		switch str(document["ManifestType"]) {
+		case "singleton":
+			ret.readLocale(document)
+			ret.readInstallers(document)
+		case "defaultLocale":
+			ret.readLocale(document)
+		case "installer":
+			ret.readInstallers(document)
+		}
+	}
+
+	if ret.ID == "" || ret.Version == "" {
+		return nil, errors.New("the manifests have no PackageIdentifier or PackageVersion")
+	} else if len(ret.Installers) == 0 {
+		return nil, fmt.Errorf("%v has no installer manifest", ret.ID)
+	}
+
+	return ret, nil
+}
+
+func (m *Manifest) readLocale(document map[string]interface{}) {
+	m.Name = str(document["PackageName"])
+	m.Moniker = str(document["Moniker"])
+	m.Publisher = str(document["Publisher"])
+	m.Description = str(document["ShortDescription"])
+	m.License = str(document["License"])
+	m.Homepage = str(document["PackageUrl"])
+	m.Tags = strs(document["Tags"])
+}
+
+// readInstallers reads the installers of an installer manifest, whose top-level keys give the
+// defaults of every installer.
+func (m *Manifest) readInstallers(document map[string]interface{}) {
+	defaults := newInstaller(document, Installer{})
+
+	items, _ := document["Installers"].([]interface{})
+	for _, item := range items {
+		if installer, ok := item.(map[string]interface{}); ok {
+			m.Installers = append(m.Installers, newInstaller(installer, defaults))
+		}
+	}
+}
+
+func newInstaller(values map[string]interface{}, defaults Installer) Installer {
+	ret := defaults
+
+	set := func(field *string, key string) {
+		if value := str(values[key]); value != "" {
+			*field = value
+		}
+	}
+
+	set(&ret.Architecture, "Architecture")
+	set(&ret.Type, "InstallerType")
+	set(&ret.NestedType, "NestedInstallerType")
+	set(&ret.Scope, "Scope")
+	set(&ret.URL, "InstallerUrl")
+	set(&ret.SHA256, "InstallerSha256")
+
+	if switches, ok := values["InstallerSwitches"].(map[string]interface{}); ok {
+		if silent := str(switches["Silent"]); silent != "" {
+			ret.Silent = silent
+		}
+	}
+
+	if files, ok := values["NestedInstallerFiles"].([]interface{}); ok {
+		ret.NestedFiles = nil
+		for _, file := range files {
+			if file, ok := file.(map[string]interface{}); ok {
+				ret.NestedFiles = append(ret.NestedFiles, str(file["RelativeFilePath"]))
+			}
+		}
+	}
+
+	return ret
+}
+
+func str(value interface{}) string {
+	s, _ := value.(string)
+	return strings.TrimSpace(s)
+}
+
+func strs(value interface{}) []string {
+	var ret []string
+
+	items, _ := value.([]interface{})
+	for _, item := range items {
+		if s := str(item); s != "" {
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
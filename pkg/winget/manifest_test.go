@@ -0,0 +1,150 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package winget
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadDir(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want Manifest
+	}{
+		{filepath.Join("Mozilla.Firefox", "120.0"), Manifest{
+			ID:          "Mozilla.Firefox",
+			Version:     "120.0",
+			Name:        "Mozilla Firefox",
+			Moniker:     "firefox",
+			Publisher:   "Mozilla",
+			Description: "Mozilla Firefox is free and open source software, built by a community of thousands from all over the world.",
+			License:     "MPL-2.0",
+			Homepage:    "https://www.mozilla.org/firefox/",
+			Tags:        []string{"browser", "gecko", "internet", "quantum", "spidermonkey", "web", "web-browser"},
+			Installers: []Installer{
+				{
+					Architecture: "x86",
+					Type:         "nullsoft",
+					Scope:        "machine",
+					URL:          "https://download-installer.cdn.mozilla.net/pub/firefox/releases/120.0/win32/en-US/Firefox%20Setup%20120.0.exe",
+					SHA256:       "4A4C08D1D3E6E4A1A3E3F5F8C0B5D6E7F8091A2B3C4D5E6F708192A3B4C5D6E7",
+					Silent:       "/S /PreventRebootRequired=true",
+				},
+				{
+					Architecture: "x64",
+					Type:         "nullsoft",
+					Scope:        "machine",
+					URL:          "https://download-installer.cdn.mozilla.net/pub/firefox/releases/120.0/win64/en-US/Firefox%20Setup%20120.0.exe",
+					SHA256:       "9F8E7D6C5B4A39281706F5E4D3C2B1A09F8E7D6C5B4A39281706F5E4D3C2B1A0",
+					Silent:       "/S /PreventRebootRequired=true",
+				},
+				{
+					Architecture: "arm64",
+					Type:         "nullsoft",
+					Scope:        "machine",
+					URL:          "https://download-installer.cdn.mozilla.net/pub/firefox/releases/120.0/win64-aarch64/en-US/Firefox%20Setup%20120.0.exe",
+					SHA256:       "0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF",
+					Silent:       "/S /PreventRebootRequired=true",
+				},
+			},
+		}},
+		{filepath.Join("BurntSushi.ripgrep.MSVC", "14.0.3"), Manifest{
+			ID:          "BurntSushi.ripgrep.MSVC",
+			Version:     "14.0.3",
+			Name:        "RipGrep MSVC",
+			Moniker:     "ripgrep",
+			Publisher:   "Andrew Gallant",
+			Description: "ripgrep recursively searches directories for a regex pattern while respecting your gitignore",
+			License:     "MIT or Unlicense",
+			Homepage:    "https://github.com/BurntSushi/ripgrep",
+			Tags:        []string{"grep", "regex", "rust", "search"},
+			Installers: []Installer{
+				{
+					Architecture: "x86",
+					Type:         "zip",
+					NestedType:   "portable",
+					NestedFiles:  []string{`ripgrep-14.0.3-i686-pc-windows-msvc\rg.exe`},
+					URL:          "https://github.com/BurntSushi/ripgrep/releases/download/14.0.3/ripgrep-14.0.3-i686-pc-windows-msvc.zip",
+					SHA256:       "1D0A3A7C7E6D3E33E0B1D0F8AAB2D5C6E4F1A2B3C4D5E6F708192A3B4C5D6E7F",
+				},
+				{
+					Architecture: "x64",
+					Type:         "zip",
+					NestedType:   "portable",
+					NestedFiles:  []string{`ripgrep-14.0.3-x86_64-pc-windows-msvc\rg.exe`},
+					URL:          "https://github.com/BurntSushi/ripgrep/releases/download/14.0.3/ripgrep-14.0.3-x86_64-pc-windows-msvc.zip",
+					SHA256:       "A8F7E6D5C4B3A29180F7E6D5C4B3A29180F7E6D5C4B3A29180F7E6D5C4B3A291",
+				},
+			},
+		}},
+		{filepath.Join("7zip.7zip", "23.01"), Manifest{
+			ID:          "7zip.7zip",
+			Version:     "23.01",
+			Name:        "7-Zip",
+			Moniker:     "7zip",
+			Publisher:   "Igor Pavlov",
+			Description: "7-Zip is a file archiver with a high compression ratio.\nIt's free software.",
+			License:     "GNU LGPL",
+			Homepage:    "https://www.7-zip.org/",
+			Tags:        []string{"7z", "archiver", "compression"},
+			Installers: []Installer{
+				{
+					Architecture: "x64",
+					Type:         "msi",
+					Scope:        "machine",
+					URL:          "https://www.7-zip.org/a/7z2301-x64.msi",
+					SHA256:       "0BA639B6DACDF573D847C911BD147C6384381A54DAC082B1E8C77BC73D58958B",
+					Silent:       "/quiet /norestart",
+				},
+				{
+					Architecture: "x86",
+					Type:         "msi",
+					Scope:        "machine",
+					URL:          "https://www.7-zip.org/a/7z2301.msi",
+					SHA256:       "6E5F9D9F1CDCC2C8F5D5E1B9A3F0A3C3AA6FB2D9F19D0E07B1D54C10A2B6A4C3",
+				},
+			},
+		}},
+	}
+
+	for _, test := range tests {
+		got, err := ReadDir(filepath.Join("testdata", test.dir))
+		if err != nil {
+			t.Errorf("ReadDir(%q) failed: %v", test.dir, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("ReadDir(%q) = %+v, want %+v", test.dir, *got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"PackageIdentifier: Foo.Bar\nManifestType: version\n",
+		"PackageIdentifier: Foo.Bar\nPackageVersion: 1.0\nManifestType: installer\n",
+		"- not a mapping\n",
+	}
+
+	for _, test := range tests {
+		if _, err := Parse([]byte(test)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", test)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package winget
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// line is a line of a YAML document that is neither blank nor a comment.
+type line struct {
+	indent int
+	text   string // Without the indentation
+	number int    // Index among all the lines of the document, for block scalars
+}
+
+// parser reads the subset of YAML used by winget manifests: block mappings and sequences, plain,
+// quoted and block scalars, and flow sequences of scalars. All scalars are returned as strings,
+// mappings as map[string]interface{} and sequences as []interface{}. Other constructs, such as
+// anchors, aliases, tags and flow mappings, are refused rather than misread.
+type parser struct {
+	raw   []string
+	lines []line
+	pos   int
+}
+
+func parseYAML(data []byte) (interface{}, error) {
+	text := strings.TrimPrefix(strings.Replace(string(data), "\r\n", "\n", -1), "\ufeff")
+
+	p := &parser{raw: strings.Split(text, "\n")}
+
+	for i, raw := range p.raw {
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "%") || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if strings.HasPrefix(raw[indent:], "\t") {
+			return nil, fmt.Errorf("line %v: tabs cannot indent YAML", i+1)
+		}
+
+		p.lines = append(p.lines, line{indent: indent, text: strings.TrimRight(raw[indent:], " \t"), number: i})
+	}
+
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	ret, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %v: unexpected indentation", p.lines[p.pos].number+1)
+	}
+
+	return ret, nil
+}
+
+// block parses the mapping or the sequence starting at the current line.
+func (p *parser) block(indent int) (interface{}, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+
+	return p.mapping(indent)
+}
+
+func (p *parser) sequence(indent int) ([]interface{}, error) {
+	ret := []interface{}{}
+
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !isSequenceItem(l.text) {
+			break
+		}
+
+		item := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+
+		if item == "" {
+			p.pos++
+
+			value, err := p.nested(indent, false)
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, value)
+			continue
+		}
+
+		if _, _, ok := splitKey(item); ok || isSequenceItem(item) {
+			// A mapping or a sequence starting on the line of the item, continued below it
+			offset := indent + len(l.text) - len(item)
+			p.lines[p.pos] = line{indent: offset, text: item, number: l.number}
+
+			value, err := p.block(offset)
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, value)
+			continue
+		}
+
+		p.pos++
+
+		value, err := p.continued(item, indent)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", l.number+1, err)
+		}
+
+		ret = append(ret, value)
+	}
+
+	return ret, nil
+}
+
+func (p *parser) mapping(indent int) (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && isSequenceItem(l.text)) {
+			break
+		} else if l.indent > indent {
+			return nil, fmt.Errorf("line %v: unexpected indentation", l.number+1)
+		}
+
+		key, value, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %v: expected a key", l.number+1)
+		}
+
+		p.pos++
+
+		var err error
+		switch {
+		case key == "<<":
+			err = errors.New("merge keys are not supported")
+		case value == "":
+			ret[key], err = p.nested(indent, true)
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			ret[key] = p.blockScalar(value, l.number, indent)
+		default:
+			ret[key], err = p.continued(value, indent)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", l.number+1, err)
+		}
+	}
+
+	return ret, nil
+}
+
+// nested parses the value of a key or of a sequence item written on the following lines, if any.
+// Sequences can be indented like the key they belong to.
+func (p *parser) nested(indent int, sameIndentSequence bool) (interface{}, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+
+	next := p.lines[p.pos]
+	if next.indent > indent || (sameIndentSequence && next.indent == indent && isSequenceItem(next.text)) {
+		if _, _, ok := splitKey(next.text); !ok && !isSequenceItem(next.text) {
+			// A scalar starting on the next line
+			p.pos++
+
+			value, err := p.continued(next.text, indent)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", next.number+1, err)
+			}
+
+			return value, nil
+		}
+
+		return p.block(next.indent)
+	}
+
+	return nil, nil
+}
+
+// continued reads a scalar along with the lines continuing it, which are more indented than its
+// key. The continuation of a plain scalar cannot look like a key, which would be a nested mapping
+// given after a value.
+func (p *parser) continued(value string, indent int) (interface{}, error) {
+	plain := !strings.HasPrefix(value, `"`) && !strings.HasPrefix(value, "'")
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		l := p.lines[p.pos]
+		if _, _, ok := splitKey(l.text); ok && plain {
+			return nil, fmt.Errorf("line %v: unexpected key after a value", l.number+1)
+		}
+
+		value += " " + l.text
+		p.pos++
+	}
+
+	return scalar(value)
+}
+
+// blockScalar reads the literal (|) or folded (>) scalar following the line of its key.
+func (p *parser) blockScalar(header string, number int, indent int) string {
+	var lines []string
+	last := number
+	contentIndent := -1
+
+	for i := number + 1; i < len(p.raw); i++ {
+		raw := strings.TrimRight(p.raw[i], " \t")
+		if raw == "" {
+			lines = append(lines, "")
+			continue
+		}
+
+		n := len(raw) - len(strings.TrimLeft(raw, " "))
+		if n <= indent {
+			break
+		}
+
+		if contentIndent < 0 || n < contentIndent {
+			contentIndent = n
+		}
+
+		lines = append(lines, raw[contentIndent:])
+		last = i
+	}
+
+	lines = lines[:last-number]
+
+	for p.pos < len(p.lines) && p.lines[p.pos].number <= last {
+		p.pos++
+	}
+
+	separator := "\n"
+	if strings.HasPrefix(header, ">") {
+		separator = " "
+	}
+
+	ret := strings.Join(lines, separator)
+	if !strings.HasSuffix(header, "-") && ret != "" {
+		ret += "\n"
+	}
+
+	return ret
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitKey splits a "key: value" line, the value being the empty string if it is given on the
+// following lines.
+func splitKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.Index(text[1:], text[:1])
+		if end < 0 || !strings.HasPrefix(text[end+2:], ":") {
+			return "", "", false
+		}
+
+		return text[1 : end+1], withoutComment(text[end+3:]), true
+	} else if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+
+	i := strings.Index(text, ": ")
+	if i < 0 && strings.HasSuffix(text, ":") {
+		i = len(text) - 1
+	}
+
+	if i <= 0 {
+		return "", "", false
+	}
+
+	return text[:i], withoutComment(text[i+1:]), true
+}
+
+// withoutComment returns a value that is only a comment as the empty string, and the others
+// trimmed.
+func withoutComment(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "#") {
+		return ""
+	}
+
+	return value
+}
+
+// scalar returns the value of a plain, quoted or flow sequence scalar, without its comment.
+func scalar(value string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(value, `"`), strings.HasPrefix(value, "'"):
+		s, n, err := quoted(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if rest := withoutComment(value[n:]); rest != "" {
+			return nil, fmt.Errorf("unexpected %v after %v", rest, value[:n])
+		}
+
+		return s, nil
+	case strings.HasPrefix(value, "["):
+		return flowSequence(value)
+	case strings.HasPrefix(value, "{"):
+		return nil, fmt.Errorf("flow mappings are not supported: %v", value)
+	case strings.HasPrefix(value, "&"), strings.HasPrefix(value, "*"):
+		return nil, fmt.Errorf("anchors and aliases are not supported: %v", value)
+	case strings.HasPrefix(value, "!"):
+		return nil, fmt.Errorf("tags are not supported: %v", value)
+	case strings.HasPrefix(value, "|"), strings.HasPrefix(value, ">"):
+		return nil, fmt.Errorf("block scalars are only supported as values of keys: %v", value)
+	case strings.HasPrefix(value, "@"), strings.HasPrefix(value, "`"):
+		return nil, fmt.Errorf("plain scalars cannot start with %v", value[:1])
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "~" || value == "null" {
+		return nil, nil
+	}
+
+	return value, nil
+}
+
+// quoted reads the single or double quoted scalar at the start of value, returning its contents and
+// its length in value.
+func quoted(value string) (string, int, error) {
+	var ret strings.Builder
+
+	if value[0] == '\'' {
+		for i := 1; i < len(value); i++ {
+			if value[i] != '\'' {
+				ret.WriteByte(value[i])
+			} else if i+1 < len(value) && value[i+1] == '\'' {
+				ret.WriteByte('\'')
+				i++
+			} else {
+				return ret.String(), i + 1, nil
+			}
+		}
+
+		return "", 0, fmt.Errorf("unterminated string %v", value)
+	}
+
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			return ret.String(), i + 1, nil
+		case '\\':
+			n, err := unescape(&ret, value[i+1:])
+			if err != nil {
+				return "", 0, fmt.Errorf("%v in %v", err, value)
+			}
+
+			i += n
+		default:
+			ret.WriteByte(value[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string %v", value)
+}
+
+// yamlEscapes maps the single-character escapes of double quoted YAML scalars to what they stand
+// for. They differ from those of Go: "\'" is not one, while "\/", "\e", "\ " and others are.
+var yamlEscapes = map[byte]string{
+	'0':  "\x00",
+	'a':  "\a",
+	'b':  "\b",
+	't':  "\t",
+	'\t': "\t",
+	'n':  "\n",
+	'v':  "\v",
+	'f':  "\f",
+	'r':  "\r",
+	'e':  "\x1b",
+	' ':  " ",
+	'"':  `"`,
+	'/':  "/",
+	'\\': "\\",
+	'N':  "\u0085",
+	'_':  "\u00a0",
+	'L':  "\u2028",
+	'P':  "\u2029",
+}
+
+// unescape writes the character escaped by the given escape sequence, without its backslash, and
+// returns its length.
+func unescape(ret *strings.Builder, escape string) (int, error) {
+	if escape == "" {
+		return 0, errors.New("unterminated escape sequence")
+	}
+
+	if s, ok := yamlEscapes[escape[0]]; ok {
+		ret.WriteString(s)
+		return 1, nil
+	}
+
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[escape[0]]
+	if digits == 0 || len(escape) < 1+digits {
+		return 0, fmt.Errorf("invalid escape sequence \\%v", escape[:1])
+	}
+
+	code, err := strconv.ParseUint(escape[1:1+digits], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, fmt.Errorf("invalid escape sequence \\%v", escape[:1+digits])
+	}
+
+	ret.WriteRune(rune(code))
+
+	return 1 + digits, nil
+}
+
+// flowSequence reads a flow sequence of scalars, like [a, "b, c"].
+func flowSequence(value string) ([]interface{}, error) {
+	ret := []interface{}{}
+
+	for i := 1; i < len(value); {
+		for i < len(value) && value[i] == ' ' {
+			i++
+		}
+
+		if i >= len(value) {
+			break
+		}
+
+		switch value[i] {
+		case ']':
+			if rest := withoutComment(value[i+1:]); rest != "" {
+				return nil, fmt.Errorf("unexpected %v after sequence %v", rest, value[:i+1])
+			}
+
+			return ret, nil
+		case ',':
+			i++
+			continue
+		case '[', '{':
+			return nil, fmt.Errorf("nested flow collections are not supported: %v", value)
+		case '"', '\'':
+			s, n, err := quoted(value[i:])
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, s)
+			i += n
+			continue
+		}
+
+		end := strings.IndexAny(value[i:], ",]")
+		if end < 0 {
+			break
+		}
+
+		item, err := scalar(strings.TrimSpace(value[i : i+end]))
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, item)
+		i += end
+	}
+
+	return nil, fmt.Errorf("unterminated sequence %v", value)
+}
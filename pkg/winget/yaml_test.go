@@ -0,0 +1,94 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package winget
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want interface{}
+	}{
+		{"", nil},
+		{"# Only a comment\n", nil},
+		{"\ufeffKey: Value\r\n", map[string]interface{}{"Key": "Value"}},
+		{"---\nKey: Value\n...\n", map[string]interface{}{"Key": "Value"}},
+		{"Key: Value # comment\n", map[string]interface{}{"Key": "Value"}},
+		{"Key: a#b\n", map[string]interface{}{"Key": "a#b"}},
+		{"Key: https://example.com/a:b\n", map[string]interface{}{"Key": "https://example.com/a:b"}},
+		{"Key: ~\nOther: null\n", map[string]interface{}{"Key": nil, "Other": nil}},
+		{"Key: # comment\n  Nested: 1\n", map[string]interface{}{"Key": map[string]interface{}{"Nested": "1"}}},
+		{"Key: a long\n  value\n", map[string]interface{}{"Key": "a long value"}},
+		{`"Quoted Key": 1` + "\n", map[string]interface{}{"Quoted Key": "1"}},
+		{`Key: "a \"b\" \\ \/ \t \x41 \u00e9 \U0001F600 \e \N \_"`, map[string]interface{}{"Key": "a \"b\" \\ / \t A é 😀 \x1b \u0085 \u00a0"}},
+		{`Key: "C:\\Program Files\\Foo" # comment`, map[string]interface{}{"Key": `C:\Program Files\Foo`}},
+		{`Key: 'it''s \n not escaped'`, map[string]interface{}{"Key": `it's \n not escaped`}},
+		{"Key: [a, 'b, c', \"d]\", e]\n", map[string]interface{}{"Key": []interface{}{"a", "b, c", "d]", "e"}}},
+		{"Key: []\n", map[string]interface{}{"Key": []interface{}{}}},
+		{"Key: |\n  line 1\n\n  line 2\nOther: 1\n", map[string]interface{}{"Key": "line 1\n\nline 2\n", "Other": "1"}},
+		{"Key: |-\n  line 1\n    # not a comment\n", map[string]interface{}{"Key": "line 1\n  # not a comment"}},
+		{"Key: >-\n  folded\n  text\n", map[string]interface{}{"Key": "folded text"}},
+		{"List:\n- a\n- b\n", map[string]interface{}{"List": []interface{}{"a", "b"}}},
+		{"List:\n  - a\n  -\n    b\n", map[string]interface{}{"List": []interface{}{"a", "b"}}},
+		{"List:\n- A: 1\n  B: 2\n- A: 3\n", map[string]interface{}{"List": []interface{}{
+			map[string]interface{}{"A": "1", "B": "2"},
+			map[string]interface{}{"A": "3"},
+		}}},
+		{"- - a\n  - b\n- c\n", []interface{}{[]interface{}{"a", "b"}, "c"}},
+	}
+
+	for _, test := range tests {
+		got, err := parseYAML([]byte(test.yaml))
+		if err != nil {
+			t.Errorf("parseYAML(%q) failed: %v", test.yaml, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseYAML(%q) = %#v, want %#v", test.yaml, got, test.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []string{
+		"Key: &anchor Value\n",
+		"Key: *alias\n",
+		"Base: &base\n  A: 1\nOther:\n  <<: *base\n",
+		"Key: {A: 1, B: 2}\n",
+		"- {A: 1}\n",
+		"Key: [a, {b: 1}]\n",
+		"Key: [a, [b]]\n",
+		"Key: !!str 1\n",
+		"Key: \"unterminated\n",
+		"Key: 'unterminated\n",
+		"Key: [a, b\n",
+		`Key: "\q"`,
+		`Key: "\x4"`,
+		`Key: "a" b`,
+		"Key:\n\t- a\n",
+		"Key: 1\n  Nested: 2\n",
+		"Key\n",
+		"- |\n  block\n",
+		"Key: @reserved\n",
+	}
+
+	for _, test := range tests {
+		if got, err := parseYAML([]byte(test)); err == nil {
+			t.Errorf("parseYAML(%q) = %#v, want an error", test, got)
+		}
+	}
+}